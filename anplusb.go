@@ -0,0 +1,178 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseAnPlusB parses input with the An+B microsyntax of :nth-child() and
+// returns A and B. The keywords odd and even stand for 2n+1 and 2n.
+func ParseAnPlusB(input string) (int, int, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return 0, 0, &diagnostics[i]
+		}
+	}
+	return ParseAnPlusBValues(list)
+}
+
+// ParseAnPlusBValues parses the An+B microsyntax from component values as
+// described in CSS Syntax Level 3, section 6. The tokens are the ones the
+// scanner produces for the notation: 2n+1 is a dimension followed by a
+// signed number, -n-1 and n-2 are identifiers. A '+' must not be followed
+// by whitespace, and only one sign is allowed before B.
+func ParseAnPlusBValues(list []ComponentValue) (int, int, error) {
+	pos := skipSpace(list, 0)
+	if pos == len(list) {
+		return 0, 0, &Diagnostic{Severity: SeverityError, Code: CodeInvalidAnPlusB, Message: "empty An+B"}
+	}
+	c := &list[pos]
+	plus := false
+	if c.IsDelim('+') {
+		if pos+1 == len(list) || !isToken(&list[pos+1], Ident) {
+			return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "expected n directly after '+'")
+		}
+		plus = true
+		pos++
+		c = &list[pos]
+	}
+	var a int
+	// rest is the part of the identifier or unit after the n.
+	var rest string
+	switch {
+	case isToken(c, Number) && !plus:
+		if !c.Token.Integer {
+			return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "expected integer, found "+c.Token.Value)
+		}
+		b, err := anPlusBInt(c)
+		if err != nil {
+			return 0, 0, err
+		}
+		return 0, b, anPlusBEnd(list, pos+1)
+	case isToken(c, Dimension) && !plus:
+		unit := c.Token.LowerUnit()
+		if !c.Token.Integer || !strings.HasPrefix(unit, "n") {
+			return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "invalid An+B "+c.Token.Value)
+		}
+		var err error
+		if a, err = anPlusBInt(c); err != nil {
+			return 0, 0, err
+		}
+		rest = unit[1:]
+	case isToken(c, Ident):
+		v := strings.ToLower(c.Token.Value)
+		if !plus {
+			switch v {
+			case "odd":
+				return 2, 1, anPlusBEnd(list, pos+1)
+			case "even":
+				return 2, 0, anPlusBEnd(list, pos+1)
+			}
+		}
+		a = 1
+		if !plus && strings.HasPrefix(v, "-") {
+			a = -1
+			v = v[1:]
+		}
+		if !strings.HasPrefix(v, "n") {
+			return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "invalid An+B "+c.Token.Value)
+		}
+		rest = v[1:]
+	default:
+		return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "expected An+B, found "+describe(c))
+	}
+	pos++
+
+	switch {
+	case rest == "":
+		// n, optionally followed by a signed integer or by a sign and a
+		// signless integer.
+		next := skipSpace(list, pos)
+		if next == len(list) {
+			return a, 0, nil
+		}
+		c = &list[next]
+		switch {
+		case isToken(c, Number) && c.Token.Integer && c.Token.Signed:
+			b, err := anPlusBInt(c)
+			if err != nil {
+				return 0, 0, err
+			}
+			return a, b, anPlusBEnd(list, next+1)
+		case c.IsDelim('+') || c.IsDelim('-'):
+			b, err := signlessInteger(list, next+1, c)
+			if c.IsDelim('-') {
+				b = -b
+			}
+			return a, b, err
+		}
+		return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "expected sign or signed integer, found "+describe(c))
+	case rest == "-":
+		// n- followed by a signless integer.
+		b, err := signlessInteger(list, pos, c)
+		return a, -b, err
+	case rest[0] == '-' && isDigits(rest[1:]):
+		// n-<digits> in one token.
+		b, err := strconv.Atoi(rest[1:])
+		if err != nil {
+			return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "integer out of range")
+		}
+		return a, -b, anPlusBEnd(list, pos)
+	}
+	return 0, 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "invalid An+B "+c.Token.Value)
+}
+
+// signlessInteger returns the signless integer after whitespace at pos,
+// which must be the last value in list. prev is the value before it.
+func signlessInteger(list []ComponentValue, pos int, prev *ComponentValue) (int, error) {
+	pos = skipSpace(list, pos)
+	if pos == len(list) {
+		return 0, syntaxError(prev.Span(), CodeInvalidAnPlusB, "missing integer after "+describe(prev))
+	}
+	c := &list[pos]
+	if !isToken(c, Number) || !c.Token.Integer || c.Token.Signed {
+		return 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "expected integer without sign, found "+describe(c))
+	}
+	b, err := anPlusBInt(c)
+	if err != nil {
+		return 0, err
+	}
+	return b, anPlusBEnd(list, pos+1)
+}
+
+// anPlusBInt returns the value of the integer or dimension c, which must
+// fit into an int.
+func anPlusBInt(c *ComponentValue) (int, error) {
+	if n := c.Token.Num; n < math.MinInt || n >= -math.MinInt {
+		return 0, syntaxError(c.Span(), CodeInvalidAnPlusB, "integer out of range")
+	}
+	return int(c.Token.Num), nil
+}
+
+// anPlusBEnd checks that only whitespace follows pos.
+func anPlusBEnd(list []ComponentValue, pos int) error {
+	if pos = skipSpace(list, pos); pos < len(list) {
+		return syntaxError(list[pos].Span(), CodeInvalidAnPlusB, "unexpected "+describe(&list[pos])+" after An+B")
+	}
+	return nil
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
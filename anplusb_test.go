@@ -0,0 +1,114 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "testing"
+
+func TestParseAnPlusB(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		a, b  int
+	}{
+		{"odd", 2, 1},
+		{"EVEN", 2, 0},
+		{"5", 0, 5},
+		{"+5", 0, 5},
+		{"-5", 0, -5},
+		{"2n", 2, 0},
+		{"+2n", 2, 0},
+		{"-3n", -3, 0},
+		{"-2N", -2, 0},
+		{"n", 1, 0},
+		{"+n", 1, 0},
+		{"-n", -1, 0},
+		{"2n-1", 2, -1},
+		{"n-2", 1, -2},
+		{"+n-2", 1, -2},
+		{"-n-12", -1, -12},
+		{"2n+1", 2, 1},
+		{"n+1", 1, 1},
+		{"-n+3", -1, 3},
+		{"2n +1", 2, 1},
+		{"2n -1", 2, -1},
+		{"2n + 1", 2, 1},
+		{"2n - 1", 2, -1},
+		{"n - 1", 1, -1},
+		{"-n + 6", -1, 6},
+		{"2n- 1", 2, -1},
+		{"n- 1", 1, -1},
+		{"-n- 1", -1, -1},
+		{"  3n + 0  ", 3, 0},
+		{"0n+0", 0, 0},
+		{"\\6e-1", 1, -1},
+	} {
+		a, b, err := ParseAnPlusB(test.input)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if a != test.a || b != test.b {
+			t.Errorf("For %q: expected (%d, %d), got (%d, %d)", test.input, test.a, test.b, a, b)
+		}
+	}
+}
+
+func TestParseAnPlusBErrors(t *testing.T) {
+	for _, test := range []struct {
+		input   string
+		column  int
+		message string
+	}{
+		{"", 0, "empty An+B"},
+		{"2n +- 1", 5, "expected integer without sign, found '-'"},
+		{"2n + -1", 6, "expected integer without sign, found number"},
+		{"2n 1", 4, "expected sign or signed integer, found number"},
+		{"2n +", 4, "missing integer after '+'"},
+		{"+ n", 1, "expected n directly after '+'"},
+		{"+-n", 2, "invalid An+B -n"},
+		{"+ 5", 1, "expected n directly after '+'"},
+		{"1.5n", 1, "invalid An+B 1.5n"},
+		{"2.0", 1, "expected integer, found 2.0"},
+		{"2m", 1, "invalid An+B 2m"},
+		{"n-", 1, "missing integer after ident"},
+		{"n-a", 1, "invalid An+B n-a"},
+		{"odd 1", 5, "unexpected number after An+B"},
+		{"2n+1 x", 6, "unexpected ident after An+B"},
+		{"'2n'", 1, "expected An+B, found string"},
+		{"99999999999999999999n", 1, "integer out of range"},
+		{"-99999999999999999999", 1, "integer out of range"},
+		{"n+99999999999999999999", 2, "integer out of range"},
+		{"n - 99999999999999999999", 5, "integer out of range"},
+		{"n-99999999999999999999", 1, "integer out of range"},
+	} {
+		_, _, err := ParseAnPlusB(test.input)
+		d, ok := err.(*Diagnostic)
+		if !ok {
+			t.Errorf("For %q: expected a diagnostic, got %v", test.input, err)
+			continue
+		}
+		if d.Code != CodeInvalidAnPlusB || d.Column != test.column || d.Message != test.message {
+			t.Errorf("For %q: expected column %d %q, got %s", test.input, test.column, test.message, d)
+		}
+	}
+}
+
+func TestNthSelectorArguments(t *testing.T) {
+	list, err := ParseSelectorList(":nth-child(-n + 3 of .a), :nth-last-of-type(odd)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range [][2]int{{-1, 3}, {2, 1}} {
+		s := list[i].Compounds[0].Selectors[0]
+		if s.A != expected[0] || s.B != expected[1] {
+			t.Errorf("For %v: expected %v, got (%d, %d)", s, expected, s.A, s.B)
+		}
+	}
+	for _, input := range []string{":nth-child(2n +- 1)", ":nth-child()", ":nth-of-type(2 of .a)", ":nth-child(2 of)"} {
+		if _, err := ParseSelectorList(input); err == nil {
+			t.Errorf("For %q: expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Values and Units Level 4: math functions
+// --------------------------------------------------------------------
+
+// CalcType is the type of the value of a math expression.
+type CalcType int
+
+const (
+	// CalcNumber is a number without a unit.
+	CalcNumber CalcType = iota
+	// CalcLength is a length in px.
+	CalcLength
+	// CalcAngle is an angle in degrees.
+	CalcAngle
+	// CalcTime is a time in seconds.
+	CalcTime
+	// CalcFrequency is a frequency in Hz.
+	CalcFrequency
+	// CalcResolution is a resolution in dppx.
+	CalcResolution
+	// CalcPercentage is a percentage that has not been resolved.
+	CalcPercentage
+)
+
+var calcTypeNames = [...]string{"number", "length", "angle", "time", "frequency", "resolution", "percentage"}
+
+// calcUnits are the units in which the values of a CalcType are given.
+var calcUnits = [...]string{"", "px", "deg", "s", "hz", "dppx", "%"}
+
+func (t CalcType) String() string {
+	if t < 0 || int(t) >= len(calcTypeNames) {
+		return fmt.Sprintf("CalcType(%d)", int(t))
+	}
+	return calcTypeNames[t]
+}
+
+// CalcValue is the value of a math expression. Dimensions are converted to
+// the canonical unit of their type: px, deg, s, Hz or dppx.
+type CalcValue struct {
+	Value float64
+	Type  CalcType
+}
+
+// String returns the value in CSS syntax, such as 12px or 50%.
+func (v CalcValue) String() string {
+	return formatNumber(v.Value) + calcUnits[v.Type]
+}
+
+// angle returns the value of an angle dimension in degrees.
+func angle(c *ComponentValue) (float64, bool) {
+	if !isToken(c, Dimension) {
+		return 0, false
+	}
+	unit, ok := ParseUnit(c.Token.LowerUnit())
+	if !ok || unit.Type() != CalcAngle {
+		return 0, false
+	}
+	v, _ := Length{c.Token.Num, unit}.Canonical()
+	return v.Value, true
+}
+
+// CalcContext resolves the parts of a math expression that depend on where
+// it is used. All fields may be nil.
+type CalcContext struct {
+	// Percentage returns the value of p percent, for example a length
+	// relative to the containing block. If it is nil or returns false,
+	// the value has the type CalcPercentage, and an expression that
+	// combines it with another type fails with CodeUnresolvedPercentage.
+	Percentage func(p float64) (CalcValue, bool)
+	// Unit returns the value of a dimension with a unit that is not
+	// absolute, such as em or vw. The unit is lowercased.
+	Unit func(v float64, unit string) (CalcValue, bool)
+	// Keyword returns the value of an identifier other than the constants
+	// e, pi, infinity, -infinity and NaN. The name is lowercased.
+	Keyword func(name string) (CalcValue, bool)
+}
+
+// EvalCalc evaluates a numeric value such as calc(100% - 2em) with the
+// context ctx, which may be nil. The value may also be a number,
+// percentage or dimension on its own. The math functions of CSS Values 4
+// are supported: calc(), min(), max(), clamp(), round(), mod(), rem(),
+// abs(), sign(), the trigonometric and exponential functions and the
+// constants e, pi, infinity, -infinity and NaN. The operands are type
+// checked as in CSS Values 3.
+//
+// Without a percentage basis in ctx, an expression that mixes percentages
+// with other types, such as calc(50% - 2pt), can not be evaluated. The
+// error is then a *Diagnostic with the code CodeUnresolvedPercentage, so
+// that callers can tell it from an invalid expression and evaluate it
+// again once the basis is known.
+func EvalCalc(input string, ctx *CalcContext) (CalcValue, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return CalcValue{}, &diagnostics[i]
+		}
+	}
+	return EvalCalcValues(list, ctx)
+}
+
+// EvalCalcValues evaluates a numeric value given as component values such
+// as the value of a declaration.
+func EvalCalcValues(list []ComponentValue, ctx *CalcContext) (CalcValue, error) {
+	values := significant(list)
+	switch {
+	case len(values) == 0:
+		return CalcValue{}, syntaxError(Span{}, CodeInvalidCalc, "missing value")
+	case len(values) > 1:
+		return CalcValue{}, syntaxError(values[1].Span(), CodeInvalidCalc, "unexpected "+describe(values[1])+" after value")
+	}
+	if ctx == nil {
+		ctx = &CalcContext{}
+	}
+	v, err := calcEval{ctx}.value(values[0])
+	if err != nil {
+		return CalcValue{}, err
+	}
+	return v, nil
+}
+
+// mathFunctions are the names of the supported math functions.
+var mathFunctions = map[string]bool{
+	"calc": true, "min": true, "max": true, "clamp": true,
+	"round": true, "mod": true, "rem": true, "abs": true, "sign": true,
+	"sin": true, "cos": true, "tan": true, "asin": true, "acos": true,
+	"atan": true, "atan2": true, "pow": true, "sqrt": true, "hypot": true,
+	"log": true, "exp": true,
+}
+
+// isMathFunction reports whether c is a math function such as calc().
+func isMathFunction(c *ComponentValue) bool {
+	return c.Kind == FunctionBlock && mathFunctions[strings.ToLower(c.Name())]
+}
+
+// mathName returns the name of the math function or parenthesized
+// expression c for error messages.
+func mathName(c *ComponentValue) string {
+	if c.Kind == FunctionBlock {
+		return c.Name() + "()"
+	}
+	return "parentheses"
+}
+
+// mismatch returns the error for values of the types a and b that can not
+// be combined. If one of them is an unresolved percentage, the expression
+// may be valid once the percentage is resolved.
+func mismatch(span Span, msg string, a, b CalcType) *Diagnostic {
+	if a == CalcPercentage || b == CalcPercentage {
+		return syntaxError(span, CodeUnresolvedPercentage, msg+" without a percentage basis")
+	}
+	return syntaxError(span, CodeInvalidCalc, msg)
+}
+
+// calcEval evaluates math expressions.
+type calcEval struct {
+	ctx *CalcContext
+}
+
+// sum evaluates a calc-sum: products joined by '+' and '-', which must
+// be surrounded by whitespace. All products must have the same type.
+func (e calcEval) sum(fn *ComponentValue, list []ComponentValue) (CalcValue, *Diagnostic) {
+	list = trimWhitespace(list)
+	list = list[skipSpace(list, 0):]
+	if len(list) == 0 {
+		return CalcValue{}, syntaxError(fn.Span(), CodeInvalidCalc, "empty expression in "+mathName(fn))
+	}
+	var res CalcValue
+	var op *ComponentValue
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !list[i].IsDelim('+') && !list[i].IsDelim('-') {
+			continue
+		}
+		if i < len(list) && (i == 0 || i == len(list)-1 || !list[i-1].IsWhitespace() || !list[i+1].IsWhitespace()) {
+			return CalcValue{}, syntaxError(list[i].Span(), CodeInvalidCalc, "'"+list[i].Token.Value+"' must be surrounded by whitespace")
+		}
+		v, err := e.product(fn, list[start:i])
+		if err != nil {
+			return CalcValue{}, err
+		}
+		switch {
+		case op == nil:
+			res = v
+		case v.Type != res.Type:
+			return CalcValue{}, mismatch(op.Span(), "can not add "+res.Type.String()+" and "+v.Type.String(), res.Type, v.Type)
+		case op.IsDelim('+'):
+			res.Value += v.Value
+		default:
+			res.Value -= v.Value
+		}
+		if i < len(list) {
+			op = &list[i]
+		}
+		start = i + 1
+	}
+	return res, nil
+}
+
+// product evaluates a calc-product: values joined by '*' and '/'. At most
+// one factor may have a type other than number, and a divisor must be a
+// number.
+func (e calcEval) product(fn *ComponentValue, list []ComponentValue) (CalcValue, *Diagnostic) {
+	values := significant(list)
+	if len(values) == 0 {
+		return CalcValue{}, syntaxError(fn.Span(), CodeInvalidCalc, "missing operand in "+mathName(fn))
+	}
+	res, err := e.value(values[0])
+	if err != nil {
+		return CalcValue{}, err
+	}
+	for i := 1; i < len(values); i += 2 {
+		op := values[i]
+		if !op.IsDelim('*') && !op.IsDelim('/') {
+			return CalcValue{}, syntaxError(op.Span(), CodeInvalidCalc, "expected operator, found "+describe(op))
+		}
+		if i+1 == len(values) {
+			return CalcValue{}, syntaxError(op.Span(), CodeInvalidCalc, "missing operand after '"+op.Token.Value+"'")
+		}
+		v, err := e.value(values[i+1])
+		if err != nil {
+			return CalcValue{}, err
+		}
+		switch {
+		case op.IsDelim('/') && v.Type != CalcNumber:
+			return CalcValue{}, syntaxError(op.Span(), CodeInvalidCalc, "can not divide by "+v.Type.String())
+		case op.IsDelim('/'):
+			res.Value /= v.Value
+		case res.Type != CalcNumber && v.Type != CalcNumber:
+			return CalcValue{}, syntaxError(op.Span(), CodeInvalidCalc, "can not multiply "+res.Type.String()+" by "+v.Type.String())
+		default:
+			if res.Type == CalcNumber {
+				res.Type = v.Type
+			}
+			res.Value *= v.Value
+		}
+	}
+	return res, nil
+}
+
+// value evaluates a single operand.
+func (e calcEval) value(c *ComponentValue) (CalcValue, *Diagnostic) {
+	switch {
+	case isToken(c, Number):
+		return CalcValue{c.Token.Num, CalcNumber}, nil
+	case isToken(c, Percentage):
+		if e.ctx.Percentage != nil {
+			if v, ok := e.ctx.Percentage(c.Token.Num); ok {
+				return v, nil
+			}
+		}
+		return CalcValue{c.Token.Num, CalcPercentage}, nil
+	case isToken(c, Dimension):
+		if unit, ok := ParseUnit(c.Token.LowerUnit()); ok {
+			if v, ok := (Length{c.Token.Num, unit}).Canonical(); ok {
+				return v, nil
+			}
+		}
+		if e.ctx.Unit != nil {
+			if v, ok := e.ctx.Unit(c.Token.Num, c.Token.LowerUnit()); ok {
+				return v, nil
+			}
+		}
+		return CalcValue{}, syntaxError(c.Span(), CodeInvalidCalc, "can not resolve unit "+c.Token.Unit)
+	case isToken(c, Ident):
+		name := strings.ToLower(c.Token.Value)
+		switch name {
+		case "e":
+			return CalcValue{math.E, CalcNumber}, nil
+		case "pi":
+			return CalcValue{math.Pi, CalcNumber}, nil
+		case "infinity":
+			return CalcValue{math.Inf(1), CalcNumber}, nil
+		case "-infinity":
+			return CalcValue{math.Inf(-1), CalcNumber}, nil
+		case "nan":
+			return CalcValue{math.NaN(), CalcNumber}, nil
+		}
+		if e.ctx.Keyword != nil {
+			if v, ok := e.ctx.Keyword(name); ok {
+				return v, nil
+			}
+		}
+		return CalcValue{}, syntaxError(c.Span(), CodeInvalidCalc, "unknown keyword "+c.Token.Value)
+	case c.IsBlock('('):
+		return e.sum(c, c.Values)
+	case isMathFunction(c):
+		return e.function(c)
+	}
+	return CalcValue{}, syntaxError(c.Span(), CodeInvalidCalc, "unexpected "+describe(c)+" in math expression")
+}
+
+// roundingStrategies are the rounding strategies of round().
+var roundingStrategies = map[string]func(float64) float64{
+	"nearest": func(x float64) float64 { return math.Floor(x + 0.5) },
+	"up":      math.Ceil,
+	"down":    math.Floor,
+	"to-zero": math.Trunc,
+}
+
+// numberFunctions are the math functions from a number to a number.
+var numberFunctions = map[string]func(float64) float64{
+	"sqrt": math.Sqrt,
+	"exp":  math.Exp,
+	"asin": math.Asin,
+	"acos": math.Acos,
+	"atan": math.Atan,
+	"sin":  math.Sin,
+	"cos":  math.Cos,
+	"tan":  math.Tan,
+}
+
+// mathArgs are the evaluated arguments of a math function.
+type mathArgs struct {
+	fn     *ComponentValue
+	values []CalcValue
+	// none is set for the arguments given as none, which only clamp()
+	// allows.
+	none []bool
+}
+
+// check returns an error unless there are from lo to hi arguments (hi < 0
+// for no limit) that all have the same type, which is t unless t is -1.
+func (a mathArgs) check(lo, hi int, t CalcType) *Diagnostic {
+	n := len(a.values)
+	if n < lo || hi >= 0 && n > hi {
+		var need string
+		switch {
+		case hi < 0:
+			need = fmt.Sprintf("at least %d", lo)
+		case lo == hi:
+			need = fmt.Sprint(lo)
+		default:
+			need = fmt.Sprintf("%d to %d", lo, hi)
+		}
+		word := " arguments"
+		if lo == 1 && hi == 1 {
+			word = " argument"
+		}
+		return syntaxError(a.fn.Span(), CodeInvalidCalc, a.fn.Name()+"() needs "+need+word)
+	}
+	for i, v := range a.values {
+		if a.none[i] {
+			continue
+		}
+		if t < 0 {
+			t = v.Type
+		}
+		if v.Type != t {
+			return mismatch(a.fn.Span(), fmt.Sprintf("%s() needs %s arguments, found %s", a.fn.Name(), t, v.Type), t, v.Type)
+		}
+	}
+	return nil
+}
+
+// function evaluates the math function fn.
+func (e calcEval) function(fn *ComponentValue) (CalcValue, *Diagnostic) {
+	name := strings.ToLower(fn.Name())
+	if name == "calc" {
+		return e.sum(fn, fn.Values)
+	}
+	items := splitCommas(fn.Values)
+	round := roundingStrategies["nearest"]
+	if name == "round" {
+		if r := roundingStrategies[singleIdent(items[0])]; r != nil {
+			round, items = r, items[1:]
+		}
+	}
+	args := mathArgs{fn: fn, values: make([]CalcValue, len(items)), none: make([]bool, len(items))}
+	for i, item := range items {
+		if name == "clamp" && i != 1 && singleIdent(item) == "none" {
+			args.none[i] = true
+			continue
+		}
+		v, err := e.sum(fn, item)
+		if err != nil {
+			return CalcValue{}, err
+		}
+		args.values[i] = v
+	}
+	const same = CalcType(-1)
+	v := args.values
+	switch name {
+	case "min", "max":
+		if err := args.check(1, -1, same); err != nil {
+			return CalcValue{}, err
+		}
+		res := v[0]
+		for _, x := range v[1:] {
+			if name == "min" {
+				res.Value = math.Min(res.Value, x.Value)
+			} else {
+				res.Value = math.Max(res.Value, x.Value)
+			}
+		}
+		return res, nil
+	case "clamp":
+		if err := args.check(3, 3, same); err != nil {
+			return CalcValue{}, err
+		}
+		res := v[1]
+		if !args.none[2] {
+			res.Value = math.Min(res.Value, v[2].Value)
+		}
+		if !args.none[0] {
+			res.Value = math.Max(res.Value, v[0].Value)
+		}
+		return res, nil
+	case "round", "mod", "rem":
+		if name == "round" && len(v) == 1 && v[0].Type == CalcNumber {
+			// The step defaults to 1 for numbers.
+			args.values, args.none = append(v, CalcValue{1, CalcNumber}), append(args.none, false)
+			v = args.values
+		}
+		if err := args.check(2, 2, same); err != nil {
+			return CalcValue{}, err
+		}
+		res, step := v[0], v[1].Value
+		switch name {
+		case "round":
+			res.Value = round(res.Value/step) * step
+		case "mod":
+			res.Value -= step * math.Floor(res.Value/step)
+		default:
+			res.Value = math.Mod(res.Value, step)
+		}
+		if step == 0 {
+			res.Value = math.NaN()
+		}
+		return res, nil
+	case "abs", "sign":
+		if err := args.check(1, 1, same); err != nil {
+			return CalcValue{}, err
+		}
+		res := v[0]
+		if name == "abs" {
+			res.Value = math.Abs(res.Value)
+			return res, nil
+		}
+		switch {
+		case res.Value > 0:
+			res.Value = 1
+		case res.Value < 0:
+			res.Value = -1
+		}
+		return CalcValue{res.Value, CalcNumber}, nil
+	case "sin", "cos", "tan":
+		// The argument is an angle or a number of radians.
+		if len(v) == 1 && v[0].Type == CalcAngle {
+			v[0] = CalcValue{v[0].Value * math.Pi / 180, CalcNumber}
+		}
+		if err := args.check(1, 1, CalcNumber); err != nil {
+			return CalcValue{}, err
+		}
+		return CalcValue{numberFunctions[name](v[0].Value), CalcNumber}, nil
+	case "asin", "acos", "atan":
+		if err := args.check(1, 1, CalcNumber); err != nil {
+			return CalcValue{}, err
+		}
+		return CalcValue{numberFunctions[name](v[0].Value) * 180 / math.Pi, CalcAngle}, nil
+	case "atan2":
+		if err := args.check(2, 2, same); err != nil {
+			return CalcValue{}, err
+		}
+		return CalcValue{math.Atan2(v[0].Value, v[1].Value) * 180 / math.Pi, CalcAngle}, nil
+	case "sqrt", "exp":
+		if err := args.check(1, 1, CalcNumber); err != nil {
+			return CalcValue{}, err
+		}
+		return CalcValue{numberFunctions[name](v[0].Value), CalcNumber}, nil
+	case "pow":
+		if err := args.check(2, 2, CalcNumber); err != nil {
+			return CalcValue{}, err
+		}
+		return CalcValue{math.Pow(v[0].Value, v[1].Value), CalcNumber}, nil
+	case "log":
+		if err := args.check(1, 2, CalcNumber); err != nil {
+			return CalcValue{}, err
+		}
+		res := math.Log(v[0].Value)
+		if len(v) == 2 {
+			res /= math.Log(v[1].Value)
+		}
+		return CalcValue{res, CalcNumber}, nil
+	}
+	// hypot
+	if err := args.check(1, -1, same); err != nil {
+		return CalcValue{}, err
+	}
+	res := CalcValue{0, v[0].Type}
+	for _, x := range v {
+		res.Value = math.Hypot(res.Value, x.Value)
+	}
+	return res, nil
+}
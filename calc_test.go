@@ -0,0 +1,168 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvalCalc(t *testing.T) {
+	ctx := &CalcContext{
+		Percentage: func(p float64) (CalcValue, bool) {
+			return CalcValue{p * 4, CalcLength}, true
+		},
+		Unit: func(v float64, unit string) (CalcValue, bool) {
+			return CalcValue{v * 10, CalcLength}, unit == "em"
+		},
+		Keyword: func(name string) (CalcValue, bool) {
+			return CalcValue{10, CalcNumber}, name == "x"
+		},
+	}
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"12", "12"},
+		{"1in", "96px"},
+		{"calc(1 + 2 * 3)", "7"},
+		{"calc((1 + 2) * 3)", "9"},
+		{"calc(10 / 4 - 1)", "1.5"},
+		{"calc(1 - -2)", "3"},
+		{"CALC(x * x)", "100"},
+		{"calc(calc(x / 2) + 1)", "6"},
+		{"calc(e * 0)", "0"},
+		{"calc(100% - 2em)", "380px"},
+		{"calc(2 * 3pt)", "8px"},
+		{"calc(2.54cm + 6pc)", "192px"},
+		{"calc(90deg + 0.5turn)", "270deg"},
+		{"calc(1s - 500ms)", "0.5s"},
+		{"calc(2khz)", "2000hz"},
+		{"calc(192dpi)", "2dppx"},
+		{"min(10px, 1em, 2px + 3px)", "5px"},
+		{"max(1, 5, 3)", "5"},
+		{"clamp(10px, 50%, 100px)", "100px"},
+		{"clamp(10px, 1px, none)", "10px"},
+		{"clamp(none, 1em, 5px)", "5px"},
+		{"round(2.5)", "3"},
+		{"round(-2.5)", "-2"},
+		{"round(up, 11px, 5px)", "15px"},
+		{"round(down, 14px, 5px)", "10px"},
+		{"round(to-zero, -14px, 5px)", "-10px"},
+		{"mod(-7, 3)", "2"},
+		{"rem(-7, 3)", "-1"},
+		{"mod(7px, -3px)", "-2px"},
+		{"abs(-3em)", "30px"},
+		{"sign(-3em)", "-1"},
+		{"sin(90deg)", "1"},
+		{"cos(0)", "1"},
+		{"tan(0.25turn - 90deg)", "0"},
+		{"asin(1)", "90deg"},
+		{"atan2(1px, 1px)", "45deg"},
+		{"pow(2, 10)", "1024"},
+		{"sqrt(16)", "4"},
+		{"hypot(3px, 4px)", "5px"},
+		{"log(8, 2)", "3"},
+		{"exp(0)", "1"},
+		{"calc(1px * round(2.4))", "2px"},
+	} {
+		v, err := EvalCalc(test.input, ctx)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if got := v.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+
+	for input, expected := range map[string]float64{
+		"calc(pi)":        math.Pi,
+		"calc(1 / 0)":     math.Inf(1),
+		"calc(-infinity)": math.Inf(-1),
+	} {
+		v, err := EvalCalc(input, nil)
+		if err != nil || v.Value != expected {
+			t.Errorf("For %q: expected %v, got %v (%v)", input, expected, v.Value, err)
+		}
+	}
+	for _, input := range []string{"calc(NaN)", "mod(1, 0)", "sqrt(-1)"} {
+		if v, err := EvalCalc(input, nil); err != nil || !math.IsNaN(v.Value) {
+			t.Errorf("For %q: expected NaN, got %v (%v)", input, v.Value, err)
+		}
+	}
+	if v, err := EvalCalc("calc(50% * 2)", nil); err != nil || v != (CalcValue{100, CalcPercentage}) {
+		t.Errorf("Expected an unresolved percentage, got %v (%v)", v, err)
+	}
+}
+
+func TestEvalCalcErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"", "line 0, column 0: error: missing value"},
+		{"1 2", "line 1, column 3: error: unexpected number after value"},
+		{"calc()", "line 1, column 1: error: empty expression in calc()"},
+		{"calc(1+ 2)", "line 1, column 7: error: '+' must be surrounded by whitespace"},
+		{"calc(1 +2)", "line 1, column 8: error: expected operator, found number"},
+		{"calc(1 + )", "line 1, column 8: error: '+' must be surrounded by whitespace"},
+		{"calc(1 + ())", "line 1, column 10: error: empty expression in parentheses"},
+		{"calc(2 *)", "line 1, column 8: error: missing operand after '*'"},
+		{"calc(1 + + 2)", "line 1, column 1: error: missing operand in calc()"},
+		{"calc(x)", "line 1, column 6: error: unknown keyword x"},
+		{"calc(1em)", "line 1, column 6: error: can not resolve unit em"},
+		{"calc(\"a\")", "line 1, column 6: error: unexpected string in math expression"},
+		{"calc(1px + 1)", "line 1, column 10: error: can not add length and number"},
+		{"calc(1px * 2px)", "line 1, column 10: error: can not multiply length by length"},
+		{"calc(1 / 2px)", "line 1, column 8: error: can not divide by length"},
+		{"min()", "line 1, column 1: error: empty expression in min()"},
+		{"min(1px, 2deg)", "line 1, column 1: error: min() needs length arguments, found angle"},
+		{"clamp(1px, 2px)", "line 1, column 1: error: clamp() needs 3 arguments"},
+		{"clamp(1px, none, 2px)", "line 1, column 12: error: unknown keyword none"},
+		{"round(1px)", "line 1, column 1: error: round() needs 2 arguments"},
+		{"round(sideways, 1, 2)", "line 1, column 7: error: unknown keyword sideways"},
+		{"abs(1, 2)", "line 1, column 1: error: abs() needs 1 argument"},
+		{"sin(1px)", "line 1, column 1: error: sin() needs number arguments, found length"},
+		{"sqrt(4px)", "line 1, column 1: error: sqrt() needs number arguments, found length"},
+		{"log(1, 2, 3)", "line 1, column 1: error: log() needs 1 to 2 arguments"},
+		{"hypot(1, 2px)", "line 1, column 1: error: hypot() needs number arguments, found length"},
+	} {
+		_, err := EvalCalc(test.input, nil)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeInvalidCalc {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+}
+
+func TestEvalCalcUnresolvedPercentage(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"calc(50% - 2pt)", "line 1, column 10: error: can not add percentage and length without a percentage basis"},
+		{"calc(1px + 10%)", "line 1, column 10: error: can not add length and percentage without a percentage basis"},
+		{"min(50%, 2pt)", "line 1, column 1: error: min() needs percentage arguments, found length without a percentage basis"},
+	} {
+		_, err := EvalCalc(test.input, nil)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeUnresolvedPercentage {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+	ctx := &CalcContext{Percentage: func(p float64) (CalcValue, bool) {
+		return CalcValue{p * 2, CalcLength}, true
+	}}
+	if v, err := EvalCalc("calc(50% - 6pt)", ctx); err != nil || v.String() != "92px" {
+		t.Errorf("Expected 92px, got %v (%v)", v, err)
+	}
+}
@@ -0,0 +1,932 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Color Module Level 4 and 5: color values
+// --------------------------------------------------------------------
+
+// ColorSpace is the color space of the components of a Color.
+type ColorSpace int
+
+const (
+	// ColorSRGB is sRGB with components from 0 to 1. Hex colors, named
+	// colors and rgb() are in this space.
+	ColorSRGB ColorSpace = iota
+	// ColorSRGBLinear is sRGB without the transfer function.
+	ColorSRGBLinear
+	// ColorDisplayP3, ColorA98RGB, ColorProPhotoRGB and ColorRec2020 are
+	// the predefined RGB spaces of color() with components from 0 to 1.
+	ColorDisplayP3
+	ColorA98RGB
+	ColorProPhotoRGB
+	ColorRec2020
+	// ColorXYZD50 and ColorXYZD65 are CIE XYZ relative to the D50 and D65
+	// white points, with Y from 0 to 1.
+	ColorXYZD50
+	ColorXYZD65
+	// ColorHSL has the hue in degrees and saturation and lightness from 0
+	// to 100.
+	ColorHSL
+	// ColorHWB has the hue in degrees and whiteness and blackness from 0
+	// to 100.
+	ColorHWB
+	// ColorLab is CIE Lab with lightness from 0 to 100.
+	ColorLab
+	// ColorLCH is CIE LCH with lightness from 0 to 100 and the hue in
+	// degrees.
+	ColorLCH
+	// ColorOklab is Oklab with lightness from 0 to 1.
+	ColorOklab
+	// ColorOklch is Oklch with lightness from 0 to 1 and the hue in
+	// degrees.
+	ColorOklch
+	// ColorDeviceCMYK are device dependent cyan, magenta, yellow and
+	// black from 0 to 1.
+	ColorDeviceCMYK
+)
+
+var colorSpaceNames = [...]string{
+	ColorSRGB:        "srgb",
+	ColorSRGBLinear:  "srgb-linear",
+	ColorDisplayP3:   "display-p3",
+	ColorA98RGB:      "a98-rgb",
+	ColorProPhotoRGB: "prophoto-rgb",
+	ColorRec2020:     "rec2020",
+	ColorXYZD50:      "xyz-d50",
+	ColorXYZD65:      "xyz-d65",
+	ColorHSL:         "hsl",
+	ColorHWB:         "hwb",
+	ColorLab:         "lab",
+	ColorLCH:         "lch",
+	ColorOklab:       "oklab",
+	ColorOklch:       "oklch",
+	ColorDeviceCMYK:  "device-cmyk",
+}
+
+// String returns the name of the color space as used in color() and
+// color-mix().
+func (s ColorSpace) String() string {
+	return colorSpaceNames[s]
+}
+
+// Color is a parsed CSS color. The zero value is transparent black.
+type Color struct {
+	// Space is the color space of Components.
+	Space ColorSpace
+	// Components are the color components in the order of the function
+	// that specifies them, such as L, C and H for ColorLCH. The fourth
+	// component is only used by ColorDeviceCMYK. A missing component
+	// (none) is NaN.
+	Components [4]float64
+	// Alpha is the opacity from 0 to 1, or NaN if it is missing.
+	Alpha float64
+	// CurrentColor is set for the currentcolor keyword, whose value
+	// depends on the element. Resolve replaces it.
+	CurrentColor bool
+	// Mix is set for a color-mix() of colors that depend on currentcolor,
+	// which can only be computed by Resolve.
+	Mix *ColorMix
+	// Relative is set for a relative color whose origin color depends on
+	// currentcolor. It is the color function, which Resolve evaluates.
+	Relative *ComponentValue
+}
+
+// dependsOnCurrentColor reports whether the value of c is only known when
+// currentcolor is resolved.
+func (c Color) dependsOnCurrentColor() bool {
+	return c.CurrentColor || c.Mix != nil || c.Relative != nil
+}
+
+// HueInterpolation is the hue interpolation method of color-mix().
+type HueInterpolation int
+
+const (
+	// HueShorter takes the shorter arc between two hues. It is the
+	// default.
+	HueShorter HueInterpolation = iota
+	HueLonger
+	HueIncreasing
+	HueDecreasing
+)
+
+var hueInterpolationNames = [...]string{"shorter", "longer", "increasing", "decreasing"}
+
+// ColorMix is a color-mix() function.
+type ColorMix struct {
+	// Space is the interpolation color space.
+	Space ColorSpace
+	// Hue is the hue interpolation method for ColorHSL, ColorHWB,
+	// ColorLCH and ColorOklch.
+	Hue HueInterpolation
+	// Colors are the colors to mix.
+	Colors [2]Color
+	// Percentages are the percentages of the colors. Omitted percentages
+	// are filled in and percentages adding up to more than 100 are
+	// scaled down. If they add up to less than 100, the result is made
+	// transparent by the same factor.
+	Percentages [2]float64
+}
+
+// ParseColor parses input as a CSS color: a hex color, a named color,
+// transparent, currentcolor, rgb(), rgba(), hsl(), hsla(), hwb(), lab(),
+// lch(), oklab(), oklch(), color() with the predefined color spaces,
+// device-cmyk() or color-mix(). The functions other than device-cmyk()
+// accept the relative syntax of CSS Color 5, such as
+// oklch(from #f00 l c calc(h + 180)). light-dark() and the system colors
+// are not supported.
+func ParseColor(input string) (Color, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return Color{}, &diagnostics[i]
+		}
+	}
+	return ParseColorValues(list)
+}
+
+// ParseColorValues parses a color from component values such as the
+// value of a declaration.
+func ParseColorValues(list []ComponentValue) (Color, error) {
+	values := significant(list)
+	switch {
+	case len(values) == 0:
+		return Color{}, syntaxError(Span{}, CodeInvalidColor, "missing color")
+	case len(values) > 1:
+		return Color{}, syntaxError(values[1].Span(), CodeInvalidColor, "unexpected "+describe(values[1])+" after color")
+	}
+	col, err := parseColor(values[0])
+	if err != nil {
+		return Color{}, err
+	}
+	return col, nil
+}
+
+// parseColor parses a single component value as a color.
+func parseColor(c *ComponentValue) (Color, *Diagnostic) {
+	switch {
+	case isToken(c, Hash):
+		return hexColor(c)
+	case isToken(c, Ident):
+		name := strings.ToLower(c.Token.Value)
+		switch name {
+		case "currentcolor":
+			return Color{CurrentColor: true, Alpha: 1}, nil
+		case "transparent":
+			return Color{}, nil
+		}
+		if v, ok := namedColors[name]; ok {
+			return rgbColor(v), nil
+		}
+		return Color{}, syntaxError(c.Span(), CodeInvalidColor, "unknown color "+c.Token.Value)
+	case c.Kind == FunctionBlock:
+		if strings.EqualFold(c.Name(), "color-mix") {
+			return parseColorMix(c)
+		}
+		f := newColorFunction(c)
+		if len(f.args) > 0 && isIdent(f.args[0], "from") {
+			return f.relative(nil)
+		}
+		return f.parse()
+	}
+	return Color{}, syntaxError(c.Span(), CodeInvalidColor, "expected color, found "+describe(c))
+}
+
+// rgbColor returns the opaque sRGB color 0xRRGGBB.
+func rgbColor(v uint32) Color {
+	return Color{
+		Components: [4]float64{float64(v>>16) / 255, float64(v>>8&0xFF) / 255, float64(v&0xFF) / 255},
+		Alpha:      1,
+	}
+}
+
+// hexColor parses a hex color with 3, 4, 6 or 8 digits.
+func hexColor(c *ComponentValue) (Color, *Diagnostic) {
+	s := c.Token.Value
+	invalid := syntaxError(c.Span(), CodeInvalidColor, "invalid hex color #"+s)
+	for i := 0; i < len(s); i++ {
+		if !isHexChar(s[i]) {
+			return Color{}, invalid
+		}
+	}
+	var digits []uint64
+	switch len(s) {
+	case 3, 4:
+		for i := 0; i < len(s); i++ {
+			d, _ := strconv.ParseUint(s[i:i+1], 16, 8)
+			digits = append(digits, d*17)
+		}
+	case 6, 8:
+		for i := 0; i < len(s); i += 2 {
+			d, _ := strconv.ParseUint(s[i:i+2], 16, 8)
+			digits = append(digits, d)
+		}
+	default:
+		return Color{}, invalid
+	}
+	col := Color{Alpha: 1}
+	for i, d := range digits {
+		if i == 3 {
+			col.Alpha = float64(d) / 255
+		} else {
+			col.Components[i] = float64(d) / 255
+		}
+	}
+	return col, nil
+}
+
+// colorFunction is a color function such as rgb() or lab() being parsed.
+type colorFunction struct {
+	fn *ComponentValue
+	// name is the lowercased function name.
+	name string
+	// args are the arguments without whitespace. For a relative color they
+	// start after the origin color.
+	args []*ComponentValue
+	// channels are the values of the channel keywords of a relative color
+	// and nil for an absolute color.
+	channels map[string]float64
+}
+
+func newColorFunction(fn *ComponentValue) *colorFunction {
+	return &colorFunction{fn: fn, name: strings.ToLower(fn.Name()), args: significant(fn.Values)}
+}
+
+// parse parses the arguments of the color function.
+func (f *colorFunction) parse() (Color, *Diagnostic) {
+	switch f.name {
+	case "rgb", "rgba":
+		return f.rgb()
+	case "hsl", "hsla":
+		return f.hsl()
+	case "hwb":
+		return f.hwb()
+	case "lab", "lch", "oklab", "oklch":
+		return f.lab(functionSpaces[f.name])
+	case "color":
+		return f.color()
+	case "device-cmyk":
+		return f.deviceCMYK()
+	}
+	return Color{}, syntaxError(f.fn.Span(), CodeInvalidColor, "unknown color function "+f.fn.Name()+"()")
+}
+
+// functionSpaces are the color spaces of the color functions that allow
+// relative colors, except for color().
+var functionSpaces = map[string]ColorSpace{
+	"rgb":   ColorSRGB,
+	"rgba":  ColorSRGB,
+	"hsl":   ColorHSL,
+	"hsla":  ColorHSL,
+	"hwb":   ColorHWB,
+	"lab":   ColorLab,
+	"lch":   ColorLCH,
+	"oklab": ColorOklab,
+	"oklch": ColorOklch,
+}
+
+// channelNames returns the channel keywords of a relative color in space.
+func channelNames(space ColorSpace) [3]string {
+	switch space {
+	case ColorHSL:
+		return [3]string{"h", "s", "l"}
+	case ColorHWB:
+		return [3]string{"h", "w", "b"}
+	case ColorLab, ColorOklab:
+		return [3]string{"l", "a", "b"}
+	case ColorLCH, ColorOklch:
+		return [3]string{"l", "c", "h"}
+	case ColorXYZD50, ColorXYZD65:
+		return [3]string{"x", "y", "z"}
+	}
+	return [3]string{"r", "g", "b"}
+}
+
+// relative parses a relative color such as rgb(from red r g calc(b / 2)).
+// The channel keywords stand for the components of the origin color
+// converted to the color space of the function, in the units of its
+// number arguments. current replaces currentcolor in the origin color; if
+// it is nil, a relative color with such an origin is kept in
+// Color.Relative.
+func (f *colorFunction) relative(current *Color) (Color, *Diagnostic) {
+	if len(f.args) < 2 {
+		return Color{}, syntaxError(f.fn.Span(), CodeInvalidColor, "missing origin color in "+f.fn.Name()+"()")
+	}
+	origin, err := parseColor(f.args[1])
+	if err != nil {
+		return Color{}, err
+	}
+	deferred := origin.dependsOnCurrentColor()
+	if deferred && current != nil {
+		origin, deferred = origin.Resolve(*current), false
+	}
+	f.args = f.args[2:]
+	space, ok := functionSpaces[f.name]
+	if f.name == "color" && len(f.args) > 0 && isToken(f.args[0], Ident) {
+		space, ok = predefinedSpaces[strings.ToLower(f.args[0].Token.Value)]
+		if !ok {
+			return f.parse() // reports the unknown color space
+		}
+	}
+	if !ok {
+		return Color{}, syntaxError(f.fn.Span(), CodeInvalidColor, f.fn.Name()+"() does not allow relative colors")
+	}
+	v := origin.Convert(space)
+	scale := 1.0
+	if f.name == "rgb" || f.name == "rgba" {
+		scale = 255
+	}
+	f.channels = map[string]float64{"alpha": zeroIfNaN(v.Alpha)}
+	for i, name := range channelNames(space) {
+		f.channels[name] = zeroIfNaN(v.Components[i]) * scale
+	}
+	col, err := f.parse()
+	if err != nil {
+		return Color{}, err
+	}
+	if deferred {
+		return Color{Space: col.Space, Alpha: 1, Relative: f.fn}, nil
+	}
+	return col, nil
+}
+
+// zeroIfNaN returns v, or 0 if v is NaN.
+func zeroIfNaN(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// colorArgs are the arguments of a color function.
+type colorArgs struct {
+	// values are the components.
+	values []*ComponentValue
+	// alpha is the alpha value, or nil.
+	alpha *ComponentValue
+	// legacy is set for the comma separated syntax.
+	legacy bool
+}
+
+// arguments splits the arguments into n components and the alpha value
+// after '/'. If legacy is set, the comma separated syntax with the alpha
+// value as an additional argument is accepted for absolute colors too.
+func (f *colorFunction) arguments(n int, legacy bool) (colorArgs, *Diagnostic) {
+	var res colorArgs
+	args := f.args
+	for _, arg := range args {
+		if arg.IsDelim(',') {
+			res.legacy = true
+			break
+		}
+	}
+	if res.legacy && (!legacy || f.channels != nil) {
+		return res, syntaxError(f.fn.Span(), CodeInvalidColor, f.fn.Name()+"() does not allow commas")
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case res.legacy && i%2 == 1:
+			if !arg.IsDelim(',') {
+				return res, syntaxError(arg.Span(), CodeInvalidColor, "expected ',', found "+describe(arg))
+			}
+			if i == len(args)-1 {
+				return res, syntaxError(arg.Span(), CodeInvalidColor, "unexpected ',' at the end of "+f.fn.Name()+"()")
+			}
+		case arg.IsDelim(',') || arg.IsDelim('/') && res.legacy:
+			return res, f.unexpected(arg)
+		case arg.IsDelim('/'):
+			if i+2 != len(args) {
+				return res, syntaxError(arg.Span(), CodeInvalidColor, "expected a single alpha value after '/'")
+			}
+			res.alpha = args[i+1]
+			i++
+		default:
+			res.values = append(res.values, arg)
+		}
+	}
+	if res.legacy && len(res.values) == n+1 {
+		res.alpha = res.values[n]
+		res.values = res.values[:n]
+	}
+	if len(res.values) != n {
+		return res, syntaxError(f.fn.Span(), CodeInvalidColor, fmt.Sprintf("%s() needs %d components", f.fn.Name(), n))
+	}
+	return res, nil
+}
+
+// unexpected returns the error for the unexpected argument c.
+func (f *colorFunction) unexpected(c *ComponentValue) *Diagnostic {
+	return syntaxError(c.Span(), CodeInvalidColor, "unexpected "+describe(c)+" in "+f.fn.Name()+"()")
+}
+
+// number returns the value of a number or percentage argument where 100%
+// is percent. none is NaN. If percent is 0, percentages are not allowed.
+func (f *colorFunction) number(c *ComponentValue, percent float64) (float64, *Diagnostic) {
+	switch {
+	case isToken(c, Number):
+		return c.Token.Num, nil
+	case isToken(c, Percentage) && percent != 0:
+		return c.Token.Num * percent / 100, nil
+	case isIdent(c, "none"):
+		return math.NaN(), nil
+	}
+	return f.channel(c, percent, false)
+}
+
+// hue returns a hue argument in degrees. none is NaN.
+func (f *colorFunction) hue(c *ComponentValue) (float64, *Diagnostic) {
+	switch {
+	case isToken(c, Number):
+		return c.Token.Num, nil
+	case isIdent(c, "none"):
+		return math.NaN(), nil
+	}
+	if deg, ok := angle(c); ok {
+		return deg, nil
+	}
+	return f.channel(c, 0, true)
+}
+
+// channel returns the value of a channel keyword or a math function. The
+// math function may result in a percentage of percent if that is not 0,
+// and in an angle if hue is set.
+func (f *colorFunction) channel(c *ComponentValue, percent float64, hue bool) (float64, *Diagnostic) {
+	if isToken(c, Ident) {
+		if v, ok := f.channels[strings.ToLower(c.Token.Value)]; ok {
+			return v, nil
+		}
+	}
+	if !isMathFunction(c) {
+		return 0, f.unexpected(c)
+	}
+	ctx := &CalcContext{Keyword: func(name string) (CalcValue, bool) {
+		v, ok := f.channels[name]
+		return CalcValue{v, CalcNumber}, ok
+	}}
+	v, err := calcEval{ctx}.value(c)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case v.Type == CalcNumber:
+		return v.Value, nil
+	case v.Type == CalcPercentage && percent != 0:
+		return v.Value * percent / 100, nil
+	case v.Type == CalcAngle && hue:
+		return v.Value, nil
+	}
+	return 0, syntaxError(c.Span(), CodeInvalidColor, "unexpected "+v.Type.String()+" in "+f.fn.Name()+"()")
+}
+
+// alpha sets the alpha value of col from args. It is 1 if no alpha value
+// is given.
+func (f *colorFunction) alpha(args colorArgs, col *Color) *Diagnostic {
+	col.Alpha = 1
+	if args.alpha == nil {
+		return nil
+	}
+	if args.legacy && isIdent(args.alpha, "none") {
+		return f.unexpected(args.alpha)
+	}
+	v, err := f.number(args.alpha, 1)
+	if err != nil {
+		return err
+	}
+	col.Alpha = clamp(v, 0, 1)
+	return nil
+}
+
+// clamp limits x to the range from lo to hi. NaN is returned as is.
+func clamp(x, lo, hi float64) float64 {
+	if x < lo {
+		return lo
+	}
+	if x > hi {
+		return hi
+	}
+	return x
+}
+
+// rgb parses rgb() and rgba(). The legacy syntax requires all components
+// to be numbers or all to be percentages.
+func (f *colorFunction) rgb() (Color, *Diagnostic) {
+	args, err := f.arguments(3, true)
+	if err != nil {
+		return Color{}, err
+	}
+	col := Color{Space: ColorSRGB}
+	for i, arg := range args.values {
+		if args.legacy && (isIdent(arg, "none") || isToken(arg, Percentage) != isToken(args.values[0], Percentage)) {
+			return Color{}, f.unexpected(arg)
+		}
+		v, err := f.number(arg, 255)
+		if err != nil {
+			return Color{}, err
+		}
+		col.Components[i] = clamp(v, 0, 255) / 255
+	}
+	return col, f.alpha(args, &col)
+}
+
+// hsl parses hsl() and hsla(). The legacy syntax requires saturation and
+// lightness to be percentages.
+func (f *colorFunction) hsl() (Color, *Diagnostic) {
+	args, err := f.arguments(3, true)
+	if err != nil {
+		return Color{}, err
+	}
+	col := Color{Space: ColorHSL}
+	for i, arg := range args.values {
+		if args.legacy && (isIdent(arg, "none") || i > 0 && !isToken(arg, Percentage)) {
+			return Color{}, f.unexpected(arg)
+		}
+		var v float64
+		if i == 0 {
+			v, err = f.hue(arg)
+		} else {
+			v, err = f.number(arg, 100)
+		}
+		if err != nil {
+			return Color{}, err
+		}
+		col.Components[i] = v
+	}
+	col.Components[1] = max(col.Components[1], 0)
+	return col, f.alpha(args, &col)
+}
+
+// hwb parses hwb().
+func (f *colorFunction) hwb() (Color, *Diagnostic) {
+	args, err := f.arguments(3, false)
+	if err != nil {
+		return Color{}, err
+	}
+	col := Color{Space: ColorHWB}
+	for i, arg := range args.values {
+		var v float64
+		if i == 0 {
+			v, err = f.hue(arg)
+		} else {
+			v, err = f.number(arg, 100)
+		}
+		if err != nil {
+			return Color{}, err
+		}
+		col.Components[i] = v
+	}
+	return col, f.alpha(args, &col)
+}
+
+// labScales are the values of 100% for the components of lab(), lch(),
+// oklab() and oklch(). A zero stands for the hue.
+var labScales = map[ColorSpace][3]float64{
+	ColorLab:   {100, 125, 125},
+	ColorLCH:   {100, 150, 0},
+	ColorOklab: {1, 0.4, 0.4},
+	ColorOklch: {1, 0.4, 0},
+}
+
+// lab parses lab(), lch(), oklab() and oklch(). The lightness is clamped
+// to its range and the chroma to zero and above.
+func (f *colorFunction) lab(space ColorSpace) (Color, *Diagnostic) {
+	args, err := f.arguments(3, false)
+	if err != nil {
+		return Color{}, err
+	}
+	col := Color{Space: space}
+	scales := labScales[space]
+	for i, arg := range args.values {
+		var v float64
+		if scales[i] == 0 {
+			v, err = f.hue(arg)
+		} else {
+			v, err = f.number(arg, scales[i])
+		}
+		if err != nil {
+			return Color{}, err
+		}
+		col.Components[i] = v
+	}
+	col.Components[0] = clamp(col.Components[0], 0, scales[0])
+	if space == ColorLCH || space == ColorOklch {
+		col.Components[1] = max(col.Components[1], 0)
+	}
+	return col, f.alpha(args, &col)
+}
+
+// predefinedSpaces are the color spaces of color().
+var predefinedSpaces = map[string]ColorSpace{
+	"srgb":         ColorSRGB,
+	"srgb-linear":  ColorSRGBLinear,
+	"display-p3":   ColorDisplayP3,
+	"a98-rgb":      ColorA98RGB,
+	"prophoto-rgb": ColorProPhotoRGB,
+	"rec2020":      ColorRec2020,
+	"xyz":          ColorXYZD65,
+	"xyz-d50":      ColorXYZD50,
+	"xyz-d65":      ColorXYZD65,
+}
+
+// color parses color() with a predefined color space.
+func (f *colorFunction) color() (Color, *Diagnostic) {
+	args, err := f.arguments(4, false)
+	if err != nil {
+		return Color{}, err
+	}
+	name := args.values[0]
+	if !isToken(name, Ident) {
+		return Color{}, syntaxError(name.Span(), CodeInvalidColor, "expected color space, found "+describe(name))
+	}
+	space, ok := predefinedSpaces[strings.ToLower(name.Token.Value)]
+	if !ok {
+		return Color{}, syntaxError(name.Span(), CodeInvalidColor, "unknown color space "+name.Token.Value+" in color()")
+	}
+	col := Color{Space: space}
+	for i, arg := range args.values[1:] {
+		v, err := f.number(arg, 1)
+		if err != nil {
+			return Color{}, err
+		}
+		col.Components[i] = v
+	}
+	return col, f.alpha(args, &col)
+}
+
+// deviceCMYK parses device-cmyk().
+func (f *colorFunction) deviceCMYK() (Color, *Diagnostic) {
+	args, err := f.arguments(4, true)
+	if err != nil {
+		return Color{}, err
+	}
+	col := Color{Space: ColorDeviceCMYK}
+	for i, arg := range args.values {
+		if args.legacy && isIdent(arg, "none") {
+			return Color{}, f.unexpected(arg)
+		}
+		v, err := f.number(arg, 1)
+		if err != nil {
+			return Color{}, err
+		}
+		col.Components[i] = clamp(v, 0, 1)
+	}
+	return col, f.alpha(args, &col)
+}
+
+// mixSpaces are the interpolation color spaces of color-mix().
+var mixSpaces = map[string]ColorSpace{
+	"srgb":         ColorSRGB,
+	"srgb-linear":  ColorSRGBLinear,
+	"display-p3":   ColorDisplayP3,
+	"a98-rgb":      ColorA98RGB,
+	"prophoto-rgb": ColorProPhotoRGB,
+	"rec2020":      ColorRec2020,
+	"xyz":          ColorXYZD65,
+	"xyz-d50":      ColorXYZD50,
+	"xyz-d65":      ColorXYZD65,
+	"lab":          ColorLab,
+	"oklab":        ColorOklab,
+	"hsl":          ColorHSL,
+	"hwb":          ColorHWB,
+	"lch":          ColorLCH,
+	"oklch":        ColorOklch,
+}
+
+// parseColorMix parses color-mix(in <space> [<hue> hue], <color> <p>?,
+// <color> <p>?). Mixes of colors that depend on currentcolor are kept in
+// Color.Mix, all others are computed.
+func parseColorMix(fn *ComponentValue) (Color, *Diagnostic) {
+	items := splitCommas(fn.Values)
+	if len(items) != 3 {
+		return Color{}, syntaxError(fn.Span(), CodeInvalidColor, "color-mix() needs an interpolation method and two colors")
+	}
+	mix := &ColorMix{}
+	method := significant(items[0])
+	if len(method) < 2 || !isIdent(method[0], "in") || !isToken(method[1], Ident) {
+		return Color{}, syntaxError(fn.Span(), CodeInvalidColor, "expected 'in <color space>' in color-mix()")
+	}
+	space, ok := mixSpaces[strings.ToLower(method[1].Token.Value)]
+	if !ok {
+		return Color{}, syntaxError(method[1].Span(), CodeInvalidColor, "unknown color space "+method[1].Token.Value+" in color-mix()")
+	}
+	mix.Space = space
+	switch rest := method[2:]; {
+	case len(rest) == 0:
+	case len(rest) == 2 && hueIndex(space) >= 0 && isToken(rest[0], Ident) && isIdent(rest[1], "hue"):
+		i := 0
+		for i < len(hueInterpolationNames) && !strings.EqualFold(rest[0].Token.Value, hueInterpolationNames[i]) {
+			i++
+		}
+		if i == len(hueInterpolationNames) {
+			return Color{}, syntaxError(rest[0].Span(), CodeInvalidColor, "unknown hue interpolation method "+rest[0].Token.Value)
+		}
+		mix.Hue = HueInterpolation(i)
+	default:
+		return Color{}, newColorFunction(fn).unexpected(rest[0])
+	}
+	given := [2]bool{}
+	for i, item := range items[1:] {
+		values := significant(item)
+		var percentage *ComponentValue
+		switch {
+		case len(values) == 2 && isToken(values[0], Percentage):
+			percentage, values = values[0], values[1:]
+		case len(values) == 2 && isToken(values[1], Percentage):
+			percentage, values = values[1], values[:1]
+		}
+		if percentage != nil {
+			p := percentage.Token.Num
+			if p < 0 || p > 100 {
+				return Color{}, syntaxError(percentage.Span(), CodeInvalidColor, "percentage in color-mix() must be between 0% and 100%")
+			}
+			mix.Percentages[i], given[i] = p, true
+		}
+		if len(values) != 1 {
+			return Color{}, syntaxError(fn.Span(), CodeInvalidColor, "expected a color and an optional percentage in color-mix()")
+		}
+		col, err := parseColor(values[0])
+		if err != nil {
+			return Color{}, err
+		}
+		mix.Colors[i] = col
+	}
+	switch {
+	case !given[0] && !given[1]:
+		mix.Percentages = [2]float64{50, 50}
+	case !given[0]:
+		mix.Percentages[0] = 100 - mix.Percentages[1]
+	case !given[1]:
+		mix.Percentages[1] = 100 - mix.Percentages[0]
+	}
+	sum := mix.Percentages[0] + mix.Percentages[1]
+	if sum == 0 {
+		return Color{}, syntaxError(fn.Span(), CodeInvalidColor, "percentages in color-mix() add up to zero")
+	}
+	if sum > 100 {
+		mix.Percentages[0] *= 100 / sum
+		mix.Percentages[1] *= 100 / sum
+	}
+	for _, col := range mix.Colors {
+		if col.dependsOnCurrentColor() {
+			return Color{Space: space, Alpha: 1, Mix: mix}, nil
+		}
+	}
+	return mix.mix(), nil
+}
+
+// Resolve returns c with currentcolor replaced by current, which must not
+// depend on currentcolor itself. Mixes and relative colors with
+// currentcolor are computed.
+func (c Color) Resolve(current Color) Color {
+	switch {
+	case c.CurrentColor:
+		return current
+	case c.Mix != nil:
+		mix := *c.Mix
+		for i := range mix.Colors {
+			mix.Colors[i] = mix.Colors[i].Resolve(current)
+		}
+		return mix.mix()
+	case c.Relative != nil:
+		// The function has been checked when it was parsed.
+		col, _ := newColorFunction(c.Relative).relative(&current)
+		return col
+	}
+	return c
+}
+
+// String returns the CSS representation of the color. Colors in sRGB,
+// HSL and HWB are serialized as rgb() or rgba() with components clamped
+// to the sRGB gamut; the others keep their color space.
+func (c Color) String() string {
+	switch {
+	case c.CurrentColor:
+		return "currentcolor"
+	case c.Mix != nil:
+		return c.Mix.String()
+	case c.Relative != nil:
+		var sb strings.Builder
+		c.Relative.Emit(&sb)
+		return sb.String()
+	}
+	alpha := ""
+	if c.Alpha != 1 {
+		alpha = " / " + formatComponent(c.Alpha)
+	}
+	v := c.Components
+	switch c.Space {
+	case ColorSRGB, ColorHSL, ColorHWB:
+		rgb := c.Convert(ColorSRGB).Components
+		var parts []string
+		for _, x := range rgb[:3] {
+			if math.IsNaN(x) {
+				x = 0
+			}
+			parts = append(parts, strconv.Itoa(int(math.Round(clamp(x, 0, 1)*255))))
+		}
+		a := c.Alpha
+		if math.IsNaN(a) {
+			a = 0
+		}
+		if a == 1 {
+			return "rgb(" + strings.Join(parts, ", ") + ")"
+		}
+		return "rgba(" + strings.Join(parts, ", ") + ", " + formatComponent(a) + ")"
+	case ColorLab, ColorLCH, ColorOklab, ColorOklch:
+		return fmt.Sprintf("%s(%s %s %s%s)", c.Space, formatComponent(v[0]), formatComponent(v[1]), formatComponent(v[2]), alpha)
+	case ColorDeviceCMYK:
+		return fmt.Sprintf("device-cmyk(%s %s %s %s%s)", formatComponent(v[0]), formatComponent(v[1]), formatComponent(v[2]), formatComponent(v[3]), alpha)
+	}
+	return fmt.Sprintf("color(%s %s %s %s%s)", c.Space, formatComponent(v[0]), formatComponent(v[1]), formatComponent(v[2]), alpha)
+}
+
+// String returns the CSS representation of the color-mix() function.
+func (m *ColorMix) String() string {
+	var sb strings.Builder
+	sb.WriteString("color-mix(in " + m.Space.String())
+	if m.Hue != HueShorter {
+		sb.WriteString(" " + hueInterpolationNames[m.Hue] + " hue")
+	}
+	for i, col := range m.Colors {
+		sb.WriteString(", " + col.String() + " " + formatComponent(m.Percentages[i]) + "%")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// formatComponent formats a color component with at most six decimal
+// places. NaN is none.
+func formatComponent(v float64) string {
+	if math.IsNaN(v) {
+		return "none"
+	}
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0 // no negative zero
+	}
+	return formatNumber(v)
+}
+
+// namedColors are the named colors of CSS Color 4 as 0xRRGGBB.
+var namedColors = map[string]uint32{
+	"aliceblue": 0xf0f8ff, "antiquewhite": 0xfaebd7, "aqua": 0x00ffff,
+	"aquamarine": 0x7fffd4, "azure": 0xf0ffff, "beige": 0xf5f5dc,
+	"bisque": 0xffe4c4, "black": 0x000000, "blanchedalmond": 0xffebcd,
+	"blue": 0x0000ff, "blueviolet": 0x8a2be2, "brown": 0xa52a2a,
+	"burlywood": 0xdeb887, "cadetblue": 0x5f9ea0, "chartreuse": 0x7fff00,
+	"chocolate": 0xd2691e, "coral": 0xff7f50, "cornflowerblue": 0x6495ed,
+	"cornsilk": 0xfff8dc, "crimson": 0xdc143c, "cyan": 0x00ffff,
+	"darkblue": 0x00008b, "darkcyan": 0x008b8b, "darkgoldenrod": 0xb8860b,
+	"darkgray": 0xa9a9a9, "darkgreen": 0x006400, "darkgrey": 0xa9a9a9,
+	"darkkhaki": 0xbdb76b, "darkmagenta": 0x8b008b, "darkolivegreen": 0x556b2f,
+	"darkorange": 0xff8c00, "darkorchid": 0x9932cc, "darkred": 0x8b0000,
+	"darksalmon": 0xe9967a, "darkseagreen": 0x8fbc8f, "darkslateblue": 0x483d8b,
+	"darkslategray": 0x2f4f4f, "darkslategrey": 0x2f4f4f, "darkturquoise": 0x00ced1,
+	"darkviolet": 0x9400d3, "deeppink": 0xff1493, "deepskyblue": 0x00bfff,
+	"dimgray": 0x696969, "dimgrey": 0x696969, "dodgerblue": 0x1e90ff,
+	"firebrick": 0xb22222, "floralwhite": 0xfffaf0, "forestgreen": 0x228b22,
+	"fuchsia": 0xff00ff, "gainsboro": 0xdcdcdc, "ghostwhite": 0xf8f8ff,
+	"gold": 0xffd700, "goldenrod": 0xdaa520, "gray": 0x808080,
+	"green": 0x008000, "greenyellow": 0xadff2f, "grey": 0x808080,
+	"honeydew": 0xf0fff0, "hotpink": 0xff69b4, "indianred": 0xcd5c5c,
+	"indigo": 0x4b0082, "ivory": 0xfffff0, "khaki": 0xf0e68c,
+	"lavender": 0xe6e6fa, "lavenderblush": 0xfff0f5, "lawngreen": 0x7cfc00,
+	"lemonchiffon": 0xfffacd, "lightblue": 0xadd8e6, "lightcoral": 0xf08080,
+	"lightcyan": 0xe0ffff, "lightgoldenrodyellow": 0xfafad2, "lightgray": 0xd3d3d3,
+	"lightgreen": 0x90ee90, "lightgrey": 0xd3d3d3, "lightpink": 0xffb6c1,
+	"lightsalmon": 0xffa07a, "lightseagreen": 0x20b2aa, "lightskyblue": 0x87cefa,
+	"lightslategray": 0x778899, "lightslategrey": 0x778899, "lightsteelblue": 0xb0c4de,
+	"lightyellow": 0xffffe0, "lime": 0x00ff00, "limegreen": 0x32cd32,
+	"linen": 0xfaf0e6, "magenta": 0xff00ff, "maroon": 0x800000,
+	"mediumaquamarine": 0x66cdaa, "mediumblue": 0x0000cd, "mediumorchid": 0xba55d3,
+	"mediumpurple": 0x9370db, "mediumseagreen": 0x3cb371, "mediumslateblue": 0x7b68ee,
+	"mediumspringgreen": 0x00fa9a, "mediumturquoise": 0x48d1cc, "mediumvioletred": 0xc71585,
+	"midnightblue": 0x191970, "mintcream": 0xf5fffa, "mistyrose": 0xffe4e1,
+	"moccasin": 0xffe4b5, "navajowhite": 0xffdead, "navy": 0x000080,
+	"oldlace": 0xfdf5e6, "olive": 0x808000, "olivedrab": 0x6b8e23,
+	"orange": 0xffa500, "orangered": 0xff4500, "orchid": 0xda70d6,
+	"palegoldenrod": 0xeee8aa, "palegreen": 0x98fb98, "paleturquoise": 0xafeeee,
+	"palevioletred": 0xdb7093, "papayawhip": 0xffefd5, "peachpuff": 0xffdab9,
+	"peru": 0xcd853f, "pink": 0xffc0cb, "plum": 0xdda0dd,
+	"powderblue": 0xb0e0e6, "purple": 0x800080, "rebeccapurple": 0x663399,
+	"red": 0xff0000, "rosybrown": 0xbc8f8f, "royalblue": 0x4169e1,
+	"saddlebrown": 0x8b4513, "salmon": 0xfa8072, "sandybrown": 0xf4a460,
+	"seagreen": 0x2e8b57, "seashell": 0xfff5ee, "sienna": 0xa0522d,
+	"silver": 0xc0c0c0, "skyblue": 0x87ceeb, "slateblue": 0x6a5acd,
+	"slategray": 0x708090, "slategrey": 0x708090, "snow": 0xfffafa,
+	"springgreen": 0x00ff7f, "steelblue": 0x4682b4, "tan": 0xd2b48c,
+	"teal": 0x008080, "thistle": 0xd8bfd8, "tomato": 0xff6347,
+	"turquoise": 0x40e0d0, "violet": 0xee82ee, "wheat": 0xf5deb3,
+	"white": 0xffffff, "whitesmoke": 0xf5f5f5, "yellow": 0xffff00,
+	"yellowgreen": 0x9acd32,
+}
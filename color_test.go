@@ -0,0 +1,307 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"red", "rgb(255, 0, 0)"},
+		{"RebeccaPurple", "rgb(102, 51, 153)"},
+		{"transparent", "rgba(0, 0, 0, 0)"},
+		{"currentColor", "currentcolor"},
+		{"#f00", "rgb(255, 0, 0)"},
+		{"#0f08", "rgba(0, 255, 0, 0.533333)"},
+		{"#00FF7F", "rgb(0, 255, 127)"},
+		{"#1e90ff80", "rgba(30, 144, 255, 0.501961)"},
+		{"rgb(255, 128, 0)", "rgb(255, 128, 0)"},
+		{"rgba(10%, 20%, 30%, .5)", "rgba(26, 51, 77, 0.5)"},
+		{"rgb(300 -20 0 / 150%)", "rgb(255, 0, 0)"},
+		{"rgb(255 50% none / 25%)", "rgba(255, 128, 0, 0.25)"},
+		{"RGBA(0 0 0)", "rgb(0, 0, 0)"},
+		{"hsl(120, 100%, 25%)", "rgb(0, 128, 0)"},
+		{"hsla(0.5turn 50 50 / 0.5)", "rgba(64, 191, 191, 0.5)"},
+		{"hsl(-120deg 100% 50%)", "rgb(0, 0, 255)"},
+		{"hwb(0 0% 0%)", "rgb(255, 0, 0)"},
+		{"hwb(90 60% 60%)", "rgb(128, 128, 128)"},
+		{"lab(54.29 80.8 69.89)", "lab(54.29 80.8 69.89)"},
+		{"lab(120% 100% -50% / 0.3)", "lab(100 125 -62.5 / 0.3)"},
+		{"lch(50% 150% 1.5708rad)", "lch(50 225 90.00021)"},
+		{"lch(50 -10 none)", "lch(50 0 none)"},
+		{"oklab(62.8% 0.22 0.13)", "oklab(0.628 0.22 0.13)"},
+		{"oklch(0.7 50% 200grad / none)", "oklch(0.7 0.2 180 / none)"},
+		{"color(display-p3 1 0.5 0)", "color(display-p3 1 0.5 0)"},
+		{"color(srgb-linear 50% none 1)", "color(srgb-linear 0.5 none 1)"},
+		{"color(xyz 0.4 0.2 0.02 / 50%)", "color(xyz-d65 0.4 0.2 0.02 / 0.5)"},
+		{"color(prophoto-rgb 1 1 1)", "color(prophoto-rgb 1 1 1)"},
+		{"device-cmyk(0 0.5 100% 0.2)", "device-cmyk(0 0.5 1 0.2)"},
+		{"device-cmyk(0, 0.5, 1, 0.2)", "device-cmyk(0 0.5 1 0.2)"},
+		{"color-mix(in srgb, red, blue)", "rgb(128, 0, 128)"},
+		{"color-mix(in srgb, red 75%, blue)", "rgb(191, 0, 64)"},
+		{"color-mix(in srgb, 75% red, blue)", "rgb(191, 0, 64)"},
+		{"color-mix(in srgb, red 80%, blue 80%)", "rgb(128, 0, 128)"},
+		{"color-mix(in srgb, red 20%, blue 20%)", "rgba(128, 0, 128, 0.4)"},
+		{"color-mix(in srgb, transparent, blue)", "rgba(0, 0, 255, 0.5)"},
+		{"color-mix(in hsl, hsl(10 50% 50%), hsl(350 50% 50%))", "rgb(191, 64, 64)"},
+		{"color-mix(in hsl increasing hue, hsl(10 50% 50%), hsl(350 50% 50%))", "rgb(64, 191, 191)"},
+		{"color-mix(in hsl longer hue, hsl(10 50% 50%), hsl(350 50% 50%))", "rgb(64, 191, 191)"},
+		{"color-mix(in hsl decreasing hue, hsl(10 50% 50%), hsl(350 50% 50%))", "rgb(191, 64, 64)"},
+		{"color-mix(in lch, lch(50 10 none), lch(70 30 120))", "lch(60 20 120)"},
+		{"color-mix(in srgb, currentcolor 30%, red)", "color-mix(in srgb, currentcolor 30%, rgb(255, 0, 0) 70%)"},
+		{"color-mix(in oklch longer hue, color-mix(in srgb, currentcolor, red), blue)", "color-mix(in oklch longer hue, color-mix(in srgb, currentcolor 50%, rgb(255, 0, 0) 50%) 50%, rgb(0, 0, 255) 50%)"},
+		{"rgb(calc(50%) min(255, 300) 0)", "rgb(128, 255, 0)"},
+		{"hsl(calc(90deg + 30deg) 100% 50%)", "rgb(0, 255, 0)"},
+		{"rgb(from red r g calc(b * 0.5))", "rgb(255, 0, 0)"},
+		{"rgb(from #336699 calc(r * 2) g b / 50%)", "rgba(102, 102, 153, 0.5)"},
+		{"rgba(FROM rgb(10 20 30 / 0.4) b g r / alpha)", "rgba(30, 20, 10, 0.4)"},
+		{"rgb(from hsl(none 50% 50%) r g b)", "rgb(191, 64, 64)"},
+		{"hsl(from red calc(h + 120) s l)", "rgb(0, 255, 0)"},
+		{"hwb(from red h calc(w + 50) b)", "rgb(255, 128, 128)"},
+		{"oklch(from #f00 l c calc(h + 180))", "oklch(0.627955 0.257683 209.23388)"},
+		{"lab(from lch(50 30 90) l a b)", "lab(50 0 30)"},
+		{"lch(from white l c h)", "lch(100 0 0)"},
+		{"color(from red display-p3 r g b)", "color(display-p3 0.917488 0.200287 0.138561)"},
+		{"color(from red xyz x y z / calc(alpha / 4))", "color(xyz-d65 0.412391 0.212639 0.019331 / 0.25)"},
+		{"rgb(from currentcolor r g b / 0.5)", "rgb(from currentcolor r g b / 0.5)"},
+	} {
+		col, err := ParseColor(test.input)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if got := col.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+	if len(namedColors) != 148 {
+		t.Errorf("Expected 148 named colors, got %d", len(namedColors))
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"", "line 0, column 0: error: missing color"},
+		{"red blue", "line 1, column 5: error: unexpected ident after color"},
+		{"12px", "line 1, column 1: error: expected color, found dimension"},
+		{"bluish", "line 1, column 1: error: unknown color bluish"},
+		{"#12345", "line 1, column 1: error: invalid hex color #12345"},
+		{"#ggg", "line 1, column 1: error: invalid hex color #ggg"},
+		{"foo(1 2 3)", "line 1, column 1: error: unknown color function foo()"},
+		{"rgb(1 2)", "line 1, column 1: error: rgb() needs 3 components"},
+		{"rgb(1, 2 3)", "line 1, column 10: error: expected ',', found number"},
+		{"rgb(1, 2, 3,)", "line 1, column 12: error: unexpected ',' at the end of rgb()"},
+		{"rgb(10%, 2, 3)", "line 1, column 10: error: unexpected number in rgb()"},
+		{"rgb(1, 2, none)", "line 1, column 11: error: unexpected ident in rgb()"},
+		{"rgb(1 2 3 /)", "line 1, column 11: error: expected a single alpha value after '/'"},
+		{"rgb(1 2 3 / 1 2)", "line 1, column 11: error: expected a single alpha value after '/'"},
+		{"rgb(1 2 3 / red)", "line 1, column 13: error: unexpected ident in rgb()"},
+		{"hsl(1, 2, 3)", "line 1, column 8: error: unexpected number in hsl()"},
+		{"hsl(1px 2 3)", "line 1, column 5: error: unexpected dimension in hsl()"},
+		{"hwb(1, 2%, 3%)", "line 1, column 1: error: hwb() does not allow commas"},
+		{"lab(50 a 1)", "line 1, column 8: error: unexpected ident in lab()"},
+		{"color(1 2 3 4)", "line 1, column 7: error: expected color space, found number"},
+		{"color(cmyk 1 2 3)", "line 1, column 7: error: unknown color space cmyk in color()"},
+		{"color-mix(in srgb, red)", "line 1, column 1: error: color-mix() needs an interpolation method and two colors"},
+		{"color-mix(srgb, red, blue)", "line 1, column 1: error: expected 'in <color space>' in color-mix()"},
+		{"color-mix(in cmyk, red, blue)", "line 1, column 14: error: unknown color space cmyk in color-mix()"},
+		{"color-mix(in srgb longer hue, red, blue)", "line 1, column 19: error: unexpected ident in color-mix()"},
+		{"color-mix(in hsl sideways hue, red, blue)", "line 1, column 18: error: unknown hue interpolation method sideways"},
+		{"color-mix(in srgb, red 0%, blue 0%)", "line 1, column 1: error: percentages in color-mix() add up to zero"},
+		{"color-mix(in srgb, red 150%, blue)", "line 1, column 24: error: percentage in color-mix() must be between 0% and 100%"},
+		{"color-mix(in srgb, red blue, blue)", "line 1, column 1: error: expected a color and an optional percentage in color-mix()"},
+		{"color-mix(in srgb, red, bluish)", "line 1, column 25: error: unknown color bluish"},
+		{"rgb(calc(1px) 0 0)", "line 1, column 5: error: unexpected length in rgb()"},
+		{"hsl(calc(10%) 0 0)", "line 1, column 5: error: unexpected percentage in hsl()"},
+		{"rgb(from)", "line 1, column 1: error: missing origin color in rgb()"},
+		{"rgb(from, r, g, b)", "line 1, column 9: error: expected color, found ','"},
+		{"rgb(from bluish r g b)", "line 1, column 10: error: unknown color bluish"},
+		{"rgb(from red r g)", "line 1, column 1: error: rgb() needs 3 components"},
+		{"rgb(from red r g x)", "line 1, column 18: error: unexpected ident in rgb()"},
+		{"rgb(from red r g h)", "line 1, column 18: error: unexpected ident in rgb()"},
+		{"hsl(from red h, s, l)", "line 1, column 1: error: hsl() does not allow commas"},
+		{"color(from red cmyk r g b)", "line 1, column 16: error: unknown color space cmyk in color()"},
+		{"device-cmyk(from red c m y k)", "line 1, column 1: error: device-cmyk() does not allow relative colors"},
+	} {
+		_, err := ParseColor(test.input)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeInvalidColor {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+}
+
+// closeTo reports whether the first n components of a and b differ by at
+// most epsilon.
+func closeTo(a, b [4]float64, n int, epsilon float64) bool {
+	for i := range n {
+		if math.Abs(a[i]-b[i]) > epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+func TestColorConvert(t *testing.T) {
+	red, _ := ParseColor("red")
+	for _, test := range []struct {
+		space    ColorSpace
+		expected [4]float64
+	}{
+		{ColorSRGB, [4]float64{1, 0, 0}},
+		{ColorSRGBLinear, [4]float64{1, 0, 0}},
+		{ColorDisplayP3, [4]float64{0.917488, 0.200287, 0.138561}},
+		{ColorA98RGB, [4]float64{0.858592, 0, 0}},
+		{ColorProPhotoRGB, [4]float64{0.702248, 0.275721, 0.103548}},
+		{ColorRec2020, [4]float64{0.791977, 0.230976, 0.073761}},
+		{ColorXYZD65, [4]float64{0.412391, 0.212639, 0.019331}},
+		{ColorXYZD50, [4]float64{0.436066, 0.222493, 0.013924}},
+		{ColorHSL, [4]float64{0, 100, 50}},
+		{ColorHWB, [4]float64{0, 0, 0}},
+		{ColorLab, [4]float64{54.290541, 80.804928, 69.890965}},
+		{ColorLCH, [4]float64{54.290541, 106.837182, 40.857657}},
+		{ColorOklab, [4]float64{0.627955, 0.224863, 0.125846}},
+		{ColorOklch, [4]float64{0.627955, 0.257683, 29.23388}},
+		{ColorDeviceCMYK, [4]float64{0, 1, 1, 0}},
+	} {
+		got := red.Convert(test.space)
+		if got.Space != test.space || !closeTo(got.Components, test.expected, 4, 1e-5) || got.Alpha != 1 {
+			t.Errorf("Red in %s: expected %v, got %v", test.space, test.expected, got)
+		}
+		back := got.Convert(ColorSRGB)
+		if !closeTo(back.Components, red.Components, 3, 1e-9) {
+			t.Errorf("Red via %s: got %v", test.space, back)
+		}
+	}
+
+	gray, _ := ParseColor("gray")
+	for _, space := range []ColorSpace{ColorHSL, ColorHWB, ColorLCH, ColorOklch} {
+		if got := gray.Convert(space); !math.IsNaN(got.Components[hueIndex(space)]) {
+			t.Errorf("Gray in %s: expected a missing hue, got %v", space, got)
+		}
+	}
+	cur, _ := ParseColor("currentcolor")
+	if got := cur.Convert(ColorLab); !got.CurrentColor {
+		t.Errorf("Unexpected conversion of currentcolor %v", got)
+	}
+}
+
+func TestColorSRGB(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected [4]float64
+	}{
+		{"rgb(255 128 0 / 0.5)", [4]float64{1, 128.0 / 255, 0, 0.5}},
+		{"rgb(0 0 0 / none)", [4]float64{0, 0, 0, 0}},
+		{"lab(100 0 0)", [4]float64{1, 1, 1, 1}},
+		{"lch(0 100 30)", [4]float64{0, 0, 0, 1}},
+		{"oklch(1.2 0.3 40)", [4]float64{1, 1, 1, 1}},
+		{"color(srgb 1.000001 0.5 0)", [4]float64{1, 0.5, 0, 1}},
+	} {
+		col, err := ParseColor(test.input)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.input, err)
+		}
+		r, g, b, a := col.SRGB()
+		if got := [4]float64{r, g, b, a}; !closeTo(got, test.expected, 4, 1e-6) {
+			t.Errorf("For %q: expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+
+	// Out of gamut colors are mapped by reducing their chroma in Oklch.
+	for _, input := range []string{
+		"color(srgb 1.2 0.5 -0.1)",
+		"color(display-p3 0 1 0)",
+		"color(rec2020 0 0 1)",
+		"lab(50 200 0)",
+		"oklch(0.7 0.4 250)",
+	} {
+		col, err := ParseColor(input)
+		if err != nil {
+			t.Fatalf("For %q: %v", input, err)
+		}
+		r, g, b, _ := col.SRGB()
+		for _, v := range []float64{r, g, b} {
+			if v < 0 || v > 1 {
+				t.Errorf("For %q: %v is out of gamut", input, [3]float64{r, g, b})
+			}
+		}
+		// The result is within about a JND of the color with the original
+		// lightness and hue and the chroma of the result.
+		want := col.Convert(ColorOklch)
+		got := Color{Components: [4]float64{r, g, b}}.Convert(ColorOklch).Components
+		want.Components[1] = got[1]
+		if e := deltaEOK([3]float64{r, g, b}, want); e > 0.03 || got[1] >= col.Convert(ColorOklch).Components[1] {
+			t.Errorf("For %q: got oklch%v, %v away from oklch%v", input, got, e, want.Components)
+		}
+	}
+}
+
+func TestColorCMYK(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected [4]float64
+	}{
+		{"red", [4]float64{0, 1, 1, 0}},
+		{"black", [4]float64{0, 0, 0, 1}},
+		{"white", [4]float64{0, 0, 0, 0}},
+		{"rgb(0 128 255)", [4]float64{1, 127.0 / 255, 0, 0}},
+		{"rgb(51 102 102)", [4]float64{0.5, 0, 0, 0.6}},
+		{"device-cmyk(0.1 0.2 0.3 0.4)", [4]float64{0.1, 0.2, 0.3, 0.4}},
+		{"device-cmyk(0.1 none 0.3 0.4)", [4]float64{0.1, 0, 0.3, 0.4}},
+	} {
+		col, err := ParseColor(test.input)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.input, err)
+		}
+		c, m, y, k := col.CMYK()
+		if got := [4]float64{c, m, y, k}; !closeTo(got, test.expected, 4, 1e-9) {
+			t.Errorf("For %q: expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+	col, _ := ParseColor("device-cmyk(0 1 1 0.5)")
+	if r, g, b, _ := col.SRGB(); r != 0.5 || g != 0 || b != 0 {
+		t.Errorf("Unexpected sRGB value %v %v %v", r, g, b)
+	}
+}
+
+func TestColorResolve(t *testing.T) {
+	blue, _ := ParseColor("blue")
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"currentcolor", "rgb(0, 0, 255)"},
+		{"red", "rgb(255, 0, 0)"},
+		{"color-mix(in srgb, currentcolor, white)", "rgb(128, 128, 255)"},
+		{"color-mix(in srgb, color-mix(in srgb, currentcolor 50%, red), white 50%)", "rgb(191, 128, 191)"},
+		{"rgb(from currentcolor b g r / 0.5)", "rgba(255, 0, 0, 0.5)"},
+		{"rgb(from color-mix(in srgb, currentcolor, red) r 0 0)", "rgb(128, 0, 0)"},
+		{"color-mix(in srgb, hsl(from currentcolor calc(h + 120) s l), black 0%)", "rgb(255, 0, 0)"},
+	} {
+		col, err := ParseColor(test.input)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.input, err)
+		}
+		if got := col.Resolve(blue).String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+	mix, _ := ParseColor("color-mix(in oklch, red, white)")
+	red, _ := ParseColor("red")
+	if h, want := mix.Components[2], red.Convert(ColorOklch).Components[2]; math.Abs(h-want) > 1e-9 {
+		t.Errorf("White should take the hue of red, got %v", h)
+	}
+}
@@ -0,0 +1,620 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math"
+)
+
+// --------------------------------------------------------------------
+// Color space conversion, gamut mapping and interpolation
+// --------------------------------------------------------------------
+
+// matrix is a 3×3 matrix for linear color space conversions.
+type matrix [3][3]float64
+
+func (m matrix) apply(v [3]float64) [3]float64 {
+	var res [3]float64
+	for i := range m {
+		res[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return res
+}
+
+func (m matrix) mul(n matrix) matrix {
+	var res matrix
+	for i := range 3 {
+		for j := range 3 {
+			res[i][j] = m[i][0]*n[0][j] + m[i][1]*n[1][j] + m[i][2]*n[2][j]
+		}
+	}
+	return res
+}
+
+func (m matrix) inverse() matrix {
+	a, b, c := m[0][0], m[0][1], m[0][2]
+	d, e, f := m[1][0], m[1][1], m[1][2]
+	g, h, i := m[2][0], m[2][1], m[2][2]
+	det := a*(e*i-f*h) - b*(d*i-f*g) + c*(d*h-e*g)
+	return matrix{
+		{(e*i - f*h) / det, (c*h - b*i) / det, (b*f - c*e) / det},
+		{(f*g - d*i) / det, (a*i - c*g) / det, (c*d - a*f) / det},
+		{(d*h - e*g) / det, (b*g - a*h) / det, (a*e - b*d) / det},
+	}
+}
+
+// whiteXYZ returns the XYZ value of the white point with the
+// chromaticity x, y.
+func whiteXYZ(x, y float64) [3]float64 {
+	return [3]float64{x / y, 1, (1 - x - y) / y}
+}
+
+var (
+	whiteD65 = whiteXYZ(0.3127, 0.3290)
+	whiteD50 = whiteXYZ(0.3457, 0.3585)
+)
+
+// rgbToXYZ returns the matrix from linear RGB with the given primaries to
+// XYZ relative to white.
+func rgbToXYZ(rx, ry, gx, gy, bx, by float64, white [3]float64) matrix {
+	r, g, b := whiteXYZ(rx, ry), whiteXYZ(gx, gy), whiteXYZ(bx, by)
+	m := matrix{{r[0], g[0], b[0]}, {r[1], g[1], b[1]}, {r[2], g[2], b[2]}}
+	s := m.inverse().apply(white)
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] *= s[j]
+		}
+	}
+	return m
+}
+
+// bradford returns the Bradford chromatic adaptation from the white point
+// from to the white point to.
+func bradford(from, to [3]float64) matrix {
+	cone := matrix{{0.8951, 0.2664, -0.1614}, {-0.7502, 1.7135, 0.0367}, {0.0389, -0.0685, 1.0296}}
+	src, dst := cone.apply(from), cone.apply(to)
+	scale := matrix{{dst[0] / src[0], 0, 0}, {0, dst[1] / src[1], 0}, {0, 0, dst[2] / src[2]}}
+	return cone.inverse().mul(scale).mul(cone)
+}
+
+// rgbSpace is an RGB color space with its transfer function.
+type rgbSpace struct {
+	toXYZ, fromXYZ matrix
+	// toLinear and fromLinear are the transfer functions; nil for linear
+	// spaces.
+	toLinear, fromLinear func(float64) float64
+}
+
+var (
+	d65ToD50 = bradford(whiteD65, whiteD50)
+	d50ToD65 = d65ToD50.inverse()
+
+	xyzToLMS   = matrix{{0.8190224379967030, 0.3619062600528904, -0.1288737815209879}, {0.0329836539323885, 0.9292868615863434, 0.0361446663506424}, {0.0481771893596242, 0.2642395317527308, 0.6335478284694309}}
+	lmsToOklab = matrix{{0.2104542683093140, 0.7936177747023054, -0.0040720430116193}, {1.9779985324311684, -2.4285922420485799, 0.4505937096174110}, {0.0259040424655478, 0.7827717124575296, -0.8086757549230774}}
+	lmsToXYZ   = xyzToLMS.inverse()
+	oklabToLMS = lmsToOklab.inverse()
+
+	rgbSpaces = map[ColorSpace]*rgbSpace{
+		ColorSRGB:        newRGBSpace(rgbToXYZ(0.64, 0.33, 0.30, 0.60, 0.15, 0.06, whiteD65), srgbToLinear, srgbFromLinear),
+		ColorSRGBLinear:  newRGBSpace(rgbToXYZ(0.64, 0.33, 0.30, 0.60, 0.15, 0.06, whiteD65), nil, nil),
+		ColorDisplayP3:   newRGBSpace(rgbToXYZ(0.680, 0.320, 0.265, 0.690, 0.150, 0.060, whiteD65), srgbToLinear, srgbFromLinear),
+		ColorA98RGB:      newRGBSpace(rgbToXYZ(0.64, 0.33, 0.21, 0.71, 0.15, 0.06, whiteD65), gamma(563.0/256), gamma(256.0/563)),
+		ColorProPhotoRGB: newRGBSpace(d50ToD65.mul(rgbToXYZ(0.734699, 0.265301, 0.159597, 0.840403, 0.036598, 0.000105, whiteD50)), prophotoToLinear, prophotoFromLinear),
+		ColorRec2020:     newRGBSpace(rgbToXYZ(0.708, 0.292, 0.170, 0.797, 0.131, 0.046, whiteD65), rec2020ToLinear, rec2020FromLinear),
+		ColorXYZD65:      newRGBSpace(matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}, nil, nil),
+		ColorXYZD50:      newRGBSpace(d50ToD65, nil, nil),
+	}
+)
+
+// newRGBSpace returns an RGB space whose linear values are converted to
+// XYZ D65 by toXYZ.
+func newRGBSpace(toXYZ matrix, toLinear, fromLinear func(float64) float64) *rgbSpace {
+	return &rgbSpace{toXYZ: toXYZ, fromXYZ: toXYZ.inverse(), toLinear: toLinear, fromLinear: fromLinear}
+}
+
+// signed applies f to the magnitude of v and keeps its sign.
+func signed(v float64, f func(float64) float64) float64 {
+	if v < 0 {
+		return -f(-v)
+	}
+	return f(v)
+}
+
+func srgbToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	})
+}
+
+func srgbFromLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v <= 0.0031308 {
+			return v * 12.92
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	})
+}
+
+func gamma(g float64) func(float64) float64 {
+	return func(v float64) float64 {
+		return signed(v, func(v float64) float64 { return math.Pow(v, g) })
+	}
+}
+
+func prophotoToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v <= 16.0/512 {
+			return v / 16
+		}
+		return math.Pow(v, 1.8)
+	})
+}
+
+func prophotoFromLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v < 1.0/512 {
+			return v * 16
+		}
+		return math.Pow(v, 1/1.8)
+	})
+}
+
+const (
+	rec2020Alpha = 1.09929682680944
+	rec2020Beta  = 0.018053968510807
+)
+
+func rec2020ToLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v < rec2020Beta*4.5 {
+			return v / 4.5
+		}
+		return math.Pow((v+rec2020Alpha-1)/rec2020Alpha, 1/0.45)
+	})
+}
+
+func rec2020FromLinear(v float64) float64 {
+	return signed(v, func(v float64) float64 {
+		if v < rec2020Beta {
+			return v * 4.5
+		}
+		return rec2020Alpha*math.Pow(v, 0.45) - (rec2020Alpha - 1)
+	})
+}
+
+// baseSpace returns the space that s is converted through: sRGB for HSL,
+// HWB and device CMYK, Lab for LCH and Oklab for Oklch. The other spaces
+// are their own base.
+func baseSpace(s ColorSpace) ColorSpace {
+	switch s {
+	case ColorHSL, ColorHWB, ColorDeviceCMYK:
+		return ColorSRGB
+	case ColorLCH:
+		return ColorLab
+	case ColorOklch:
+		return ColorOklab
+	}
+	return s
+}
+
+// hueIndex returns the index of the hue component of s, or -1.
+func hueIndex(s ColorSpace) int {
+	switch s {
+	case ColorHSL, ColorHWB:
+		return 0
+	case ColorLCH, ColorOklch:
+		return 2
+	}
+	return -1
+}
+
+// Convert returns c in the color space space. Missing components are
+// treated as zero. A hue that is powerless because the color is
+// achromatic is missing in the result. Colors that depend on currentcolor
+// are returned unchanged.
+func (c Color) Convert(space ColorSpace) Color {
+	if c.dependsOnCurrentColor() || c.Space == space {
+		return c
+	}
+	v := c.Components
+	for i := range v {
+		if math.IsNaN(v[i]) {
+			v[i] = 0
+		}
+	}
+	base := toBase(c.Space, v)
+	if from, to := baseSpace(c.Space), baseSpace(space); from != to {
+		base = fromXYZ(to, toXYZ(from, base))
+	}
+	return Color{Space: space, Components: fromBase(space, base), Alpha: c.Alpha}
+}
+
+// toBase converts v from s to the base space of s.
+func toBase(s ColorSpace, v [4]float64) [3]float64 {
+	switch s {
+	case ColorHSL:
+		return hslToSRGB(v[0], v[1], v[2])
+	case ColorHWB:
+		w, b := v[1]/100, v[2]/100
+		if w+b >= 1 {
+			gray := w / (w + b)
+			return [3]float64{gray, gray, gray}
+		}
+		rgb := hslToSRGB(v[0], 100, 50)
+		for i := range rgb {
+			rgb[i] = rgb[i]*(1-w-b) + w
+		}
+		return rgb
+	case ColorDeviceCMYK:
+		k := v[3]
+		return [3]float64{
+			1 - min(1, v[0]*(1-k)+k),
+			1 - min(1, v[1]*(1-k)+k),
+			1 - min(1, v[2]*(1-k)+k),
+		}
+	case ColorLCH, ColorOklch:
+		h := v[2] * math.Pi / 180
+		return [3]float64{v[0], v[1] * math.Cos(h), v[1] * math.Sin(h)}
+	}
+	return [3]float64{v[0], v[1], v[2]}
+}
+
+// fromBase converts v from the base space of s to s.
+func fromBase(s ColorSpace, v [3]float64) [4]float64 {
+	switch s {
+	case ColorHSL:
+		h, sat, l := srgbToHSL(v)
+		return [4]float64{h, sat, l}
+	case ColorHWB:
+		h, _, _ := srgbToHSL(v)
+		w, b := min(v[0], v[1], v[2]), 1-max(v[0], v[1], v[2])
+		if w+b >= 1-1e-9 {
+			h = math.NaN()
+		}
+		return [4]float64{h, w * 100, b * 100}
+	case ColorDeviceCMYK:
+		k := 1 - max(v[0], v[1], v[2])
+		if k >= 1 {
+			return [4]float64{0, 0, 0, 1}
+		}
+		return [4]float64{(1 - v[0] - k) / (1 - k), (1 - v[1] - k) / (1 - k), (1 - v[2] - k) / (1 - k), k}
+	case ColorLCH, ColorOklch:
+		// Chroma below epsilon is treated as achromatic.
+		epsilon := 0.0015
+		if s == ColorOklch {
+			epsilon = 0.000004
+		}
+		c := math.Hypot(v[1], v[2])
+		h := math.NaN()
+		if c >= epsilon {
+			h = normalizeHue(math.Atan2(v[2], v[1]) * 180 / math.Pi)
+		}
+		return [4]float64{v[0], c, h}
+	}
+	return [4]float64{v[0], v[1], v[2]}
+}
+
+// toXYZ converts v from the base space s to XYZ D65.
+func toXYZ(s ColorSpace, v [3]float64) [3]float64 {
+	switch s {
+	case ColorLab:
+		return d50ToD65.apply(labToXYZ(v))
+	case ColorOklab:
+		lms := oklabToLMS.apply(v)
+		for i := range lms {
+			lms[i] = lms[i] * lms[i] * lms[i]
+		}
+		return lmsToXYZ.apply(lms)
+	}
+	rgb := rgbSpaces[s]
+	if rgb.toLinear != nil {
+		for i := range v {
+			v[i] = rgb.toLinear(v[i])
+		}
+	}
+	return rgb.toXYZ.apply(v)
+}
+
+// fromXYZ converts v from XYZ D65 to the base space s.
+func fromXYZ(s ColorSpace, v [3]float64) [3]float64 {
+	switch s {
+	case ColorLab:
+		return xyzToLab(d65ToD50.apply(v))
+	case ColorOklab:
+		lms := xyzToLMS.apply(v)
+		for i := range lms {
+			lms[i] = math.Cbrt(lms[i])
+		}
+		return lmsToOklab.apply(lms)
+	}
+	rgb := rgbSpaces[s]
+	res := rgb.fromXYZ.apply(v)
+	if rgb.fromLinear != nil {
+		for i := range res {
+			res[i] = rgb.fromLinear(res[i])
+		}
+	}
+	return res
+}
+
+const (
+	labEpsilon = 216.0 / 24389
+	labKappa   = 24389.0 / 27
+)
+
+// xyzToLab converts XYZ D50 to Lab.
+func xyzToLab(v [3]float64) [3]float64 {
+	var f [3]float64
+	for i := range v {
+		x := v[i] / whiteD50[i]
+		if x > labEpsilon {
+			f[i] = math.Cbrt(x)
+		} else {
+			f[i] = (labKappa*x + 16) / 116
+		}
+	}
+	return [3]float64{116*f[1] - 16, 500 * (f[0] - f[1]), 200 * (f[1] - f[2])}
+}
+
+// labToXYZ converts Lab to XYZ D50.
+func labToXYZ(v [3]float64) [3]float64 {
+	f1 := (v[0] + 16) / 116
+	f0 := v[1]/500 + f1
+	f2 := f1 - v[2]/200
+	inverse := func(f float64) float64 {
+		if f*f*f > labEpsilon {
+			return f * f * f
+		}
+		return (116*f - 16) / labKappa
+	}
+	y := v[0] / labKappa
+	if v[0] > labKappa*labEpsilon {
+		y = f1 * f1 * f1
+	}
+	return [3]float64{inverse(f0) * whiteD50[0], y * whiteD50[1], inverse(f2) * whiteD50[2]}
+}
+
+// hslToSRGB converts hue in degrees and saturation and lightness from 0
+// to 100 to sRGB.
+func hslToSRGB(h, s, l float64) [3]float64 {
+	h = normalizeHue(h)
+	s, l = s/100, l/100
+	f := func(n float64) float64 {
+		k := math.Mod(n+h/30, 12)
+		a := s * min(l, 1-l)
+		return l - a*max(-1, min(k-3, 9-k, 1))
+	}
+	return [3]float64{f(0), f(8), f(4)}
+}
+
+// srgbToHSL converts sRGB to hue, saturation and lightness. The hue of an
+// achromatic color is NaN.
+func srgbToHSL(v [3]float64) (h, s, l float64) {
+	hi, lo := max(v[0], v[1], v[2]), min(v[0], v[1], v[2])
+	h, l = math.NaN(), (hi+lo)/2
+	if d := hi - lo; d > 1e-9 {
+		if l > 0 && l < 1 {
+			s = (hi - l) / min(l, 1-l)
+		}
+		switch hi {
+		case v[0]:
+			h = (v[1]-v[2])/d + 6
+		case v[1]:
+			h = (v[2]-v[0])/d + 2
+		default:
+			h = (v[0]-v[1])/d + 4
+		}
+		h = normalizeHue(h * 60)
+	}
+	if s < 0 {
+		h, s = normalizeHue(h+180), -s
+	}
+	return h, s * 100, l * 100
+}
+
+// normalizeHue returns h in the range [0, 360).
+func normalizeHue(h float64) float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// SRGB returns the color as sRGB red, green and blue from 0 to 1 and its
+// alpha value. Colors outside of the sRGB gamut are mapped into it by
+// reducing their chroma in Oklch as described in CSS Color 4. Missing
+// components are treated as zero and currentcolor as black.
+func (c Color) SRGB() (r, g, b, alpha float64) {
+	if math.IsNaN(c.Alpha) {
+		alpha = 0
+	} else {
+		alpha = c.Alpha
+	}
+	if c.dependsOnCurrentColor() {
+		return 0, 0, 0, alpha
+	}
+	rgb := gamutMapSRGB(c)
+	return rgb[0], rgb[1], rgb[2], alpha
+}
+
+// CMYK returns device cyan, magenta, yellow and black from 0 to 1 for
+// print output. device-cmyk() colors are returned as given, all other
+// colors are converted from their gamut mapped sRGB value without a color
+// profile, with the gray component in black.
+func (c Color) CMYK() (cyan, magenta, yellow, black float64) {
+	if c.Space == ColorDeviceCMYK && !c.dependsOnCurrentColor() {
+		cmyk := c.Components
+		for i := range cmyk {
+			if math.IsNaN(cmyk[i]) {
+				cmyk[i] = 0
+			}
+		}
+		return cmyk[0], cmyk[1], cmyk[2], cmyk[3]
+	}
+	r, g, b, _ := c.SRGB()
+	cmyk := fromBase(ColorDeviceCMYK, [3]float64{r, g, b})
+	return cmyk[0], cmyk[1], cmyk[2], cmyk[3]
+}
+
+// inSRGBGamut reports whether the sRGB components v are within the gamut.
+func inSRGBGamut(v [4]float64) bool {
+	const epsilon = 1e-6
+	for _, x := range v[:3] {
+		if x < -epsilon || x > 1+epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// clipSRGB clamps the sRGB components v to the gamut.
+func clipSRGB(v [4]float64) [3]float64 {
+	return [3]float64{clamp(v[0], 0, 1), clamp(v[1], 0, 1), clamp(v[2], 0, 1)}
+}
+
+// deltaEOK is the color difference of an sRGB color and an Oklch color.
+func deltaEOK(rgb [3]float64, lch Color) float64 {
+	a := Color{Space: ColorSRGB, Components: [4]float64{rgb[0], rgb[1], rgb[2]}}.Convert(ColorOklab).Components
+	b := lch.Convert(ColorOklab).Components
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+// gamutMapSRGB maps c into the sRGB gamut with the binary search over the
+// Oklch chroma of CSS Color 4.
+func gamutMapSRGB(c Color) [3]float64 {
+	rgb := c.Convert(ColorSRGB).Components
+	if inSRGBGamut(rgb) {
+		return clipSRGB(rgb)
+	}
+	current := c.Convert(ColorOklch)
+	switch l := current.Components[0]; {
+	case l >= 1:
+		return [3]float64{1, 1, 1}
+	case l <= 0:
+		return [3]float64{0, 0, 0}
+	}
+	const (
+		jnd     = 0.02
+		epsilon = 0.0001
+	)
+	clipped := clipSRGB(rgb)
+	if deltaEOK(clipped, current) < jnd {
+		return clipped
+	}
+	lo, hi := 0.0, current.Components[1]
+	loInGamut := true
+	for hi-lo > epsilon {
+		chroma := (lo + hi) / 2
+		current.Components[1] = chroma
+		rgb := current.Convert(ColorSRGB).Components
+		if loInGamut && inSRGBGamut(rgb) {
+			lo = chroma
+			continue
+		}
+		clipped = clipSRGB(rgb)
+		e := deltaEOK(clipped, current)
+		if e >= jnd {
+			hi = chroma
+			continue
+		}
+		if jnd-e < epsilon {
+			break
+		}
+		loInGamut = false
+		lo = chroma
+	}
+	return clipped
+}
+
+// mix computes the color-mix(). The result is in the interpolation color
+// space.
+func (m *ColorMix) mix() Color {
+	hue := hueIndex(m.Space)
+	var colors [2]Color
+	for i, col := range m.Colors {
+		colors[i] = col.Convert(m.Space)
+	}
+	a, b := colors[0].Components, colors[1].Components
+	alphaA, alphaB := colors[0].Alpha, colors[1].Alpha
+	// A missing component takes the value of the other color.
+	for i := range a {
+		if math.IsNaN(a[i]) {
+			a[i] = b[i]
+		} else if math.IsNaN(b[i]) {
+			b[i] = a[i]
+		}
+	}
+	if math.IsNaN(alphaA) {
+		alphaA = alphaB
+	} else if math.IsNaN(alphaB) {
+		alphaB = alphaA
+	}
+	sum := m.Percentages[0] + m.Percentages[1]
+	t := m.Percentages[1] / sum
+	res := Color{Space: m.Space}
+	if math.IsNaN(alphaA) {
+		// Both alpha values are missing; interpolate without
+		// premultiplication.
+		alphaA, alphaB, res.Alpha = 1, 1, math.NaN()
+	} else {
+		res.Alpha = alphaA + (alphaB-alphaA)*t
+	}
+	if hue >= 0 && !math.IsNaN(a[hue]) {
+		a[hue], b[hue] = fixupHues(a[hue], b[hue], m.Hue)
+	}
+	for i := range a {
+		if i == hue {
+			res.Components[i] = a[i] + (b[i]-a[i])*t
+			if !math.IsNaN(res.Components[i]) {
+				res.Components[i] = normalizeHue(res.Components[i])
+			}
+			continue
+		}
+		v := a[i]*alphaA + (b[i]*alphaB-a[i]*alphaA)*t
+		if alpha := alphaA + (alphaB-alphaA)*t; alpha != 0 {
+			v /= alpha
+		}
+		res.Components[i] = v
+	}
+	if sum < 100 && !math.IsNaN(res.Alpha) {
+		res.Alpha *= sum / 100
+	}
+	return res
+}
+
+// fixupHues adjusts the hues a and b in degrees for interpolation with
+// method.
+func fixupHues(a, b float64, method HueInterpolation) (float64, float64) {
+	a, b = normalizeHue(a), normalizeHue(b)
+	d := b - a
+	switch method {
+	case HueShorter:
+		if d > 180 {
+			a += 360
+		} else if d < -180 {
+			b += 360
+		}
+	case HueLonger:
+		if d > 0 && d < 180 {
+			a += 360
+		} else if d > -180 && d <= 0 {
+			b += 360
+		}
+	case HueIncreasing:
+		if d < 0 {
+			b += 360
+		}
+	case HueDecreasing:
+		if d > 0 {
+			a += 360
+		}
+	}
+	return a, b
+}
@@ -0,0 +1,221 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "io"
+
+// ComponentKind identifies the kind of a ComponentValue.
+type ComponentKind int
+
+const (
+	// PreservedToken is a single token that is not part of a function or
+	// block structure.
+	PreservedToken ComponentKind = iota
+	// FunctionBlock is a function such as rgb(1 2 3). Its Token is the
+	// Function token and its Values are the arguments.
+	FunctionBlock
+	// SimpleBlock is a {}, [] or () block. Its Token is the opening bracket
+	// and its Values are the contents.
+	SimpleBlock
+)
+
+// ComponentValue is a node of the component value tree of CSS Syntax
+// Module Level 3: a preserved token, a function or a simple block.
+//
+// The URI, Local, Format and Tech tokens already contain their closing
+// paren and are preserved tokens.
+type ComponentValue struct {
+	Kind ComponentKind
+	// Token is the preserved token, the Function token of a function or
+	// the opening bracket of a simple block.
+	Token *Token
+	// Values are the arguments of a function or the contents of a simple
+	// block, including whitespace.
+	Values []ComponentValue
+	// End is the closing token of a function or simple block. It is nil if
+	// the input ended before the block was closed.
+	End *Token
+}
+
+// Name returns the name of a function, or "" for other component values.
+func (c *ComponentValue) Name() string {
+	if c.Kind != FunctionBlock {
+		return ""
+	}
+	return c.Token.Value
+}
+
+// IsBlock reports whether c is a simple block opened by open, which is
+// one of '{', '[' and '('.
+func (c *ComponentValue) IsBlock(open byte) bool {
+	return c.Kind == SimpleBlock && punct(c.Token) == open
+}
+
+// IsDelim reports whether c is the preserved token for the single
+// character ch, either a Delim token or one of the punctuation tokens of
+// Syntax3 mode.
+func (c *ComponentValue) IsDelim(ch byte) bool {
+	return c.Kind == PreservedToken && punct(c.Token) == ch
+}
+
+// IsWhitespace reports whether c is a whitespace token.
+func (c *ComponentValue) IsWhitespace() bool {
+	return c.Kind == PreservedToken && c.Token.Type == S
+}
+
+// Emit writes the CSS representation of the component value to w. It
+// uses Token.Emit for the tokens, so in Lossless mode an unmodified tree
+// is written exactly as it appeared in the input.
+func (c *ComponentValue) Emit(w io.Writer) error {
+	if err := c.Token.Emit(w); err != nil {
+		return err
+	}
+	for i := range c.Values {
+		if err := c.Values[i].Emit(w); err != nil {
+			return err
+		}
+	}
+	if c.End != nil {
+		return c.End.Emit(w)
+	}
+	return nil
+}
+
+// Span is the part of the input that a parsed construct covers. The fields
+// have the same meaning as the positions of a Token.
+type Span struct {
+	Line      int
+	Column    int
+	Offset    int
+	EndLine   int
+	EndColumn int
+	EndOffset int
+}
+
+// spanOf returns the span from the start of first to the end of last.
+func spanOf(first, last *Token) Span {
+	return Span{
+		Line:      first.Line,
+		Column:    first.Column,
+		Offset:    first.Offset,
+		EndLine:   last.EndLine,
+		EndColumn: last.EndColumn,
+		EndOffset: last.EndOffset,
+	}
+}
+
+// Span returns the part of the input that c covers.
+func (c *ComponentValue) Span() Span {
+	return spanOf(c.Token, c.lastToken())
+}
+
+// lastToken returns the last token of c.
+func (c *ComponentValue) lastToken() *Token {
+	switch {
+	case c.End != nil:
+		return c.End
+	case len(c.Values) > 0:
+		return c.Values[len(c.Values)-1].lastToken()
+	}
+	return c.Token
+}
+
+var punctuation = map[Type]byte{
+	Colon:        ':',
+	Semicolon:    ';',
+	Comma:        ',',
+	LeftParen:    '(',
+	RightParen:   ')',
+	LeftBracket:  '[',
+	RightBracket: ']',
+	LeftBrace:    '{',
+	RightBrace:   '}',
+}
+
+// punct returns the character of a single character Delim token or of a
+// Syntax3 punctuation token, and 0 for all other tokens.
+func punct(t *Token) byte {
+	if t.Type == Delim && len(t.Value) == 1 {
+		return t.Value[0]
+	}
+	return punctuation[t.Type]
+}
+
+// closing returns the character that closes a block opened by t, and 0 if
+// t does not open a block.
+func closing(t *Token) byte {
+	switch punct(t) {
+	case '{':
+		return '}'
+	case '[':
+		return ']'
+	case '(':
+		return ')'
+	}
+	if t.Type == Function {
+		return ')'
+	}
+	return 0
+}
+
+// ComponentValues consumes the remaining input of s and returns it as a
+// list of component values. Comment tokens are dropped. Blocks that are
+// not closed at the end of the input are reported as diagnostics. An
+// Error token (outside of Recover and Syntax3 mode) ends the list.
+func (s *Scanner) ComponentValues() []ComponentValue {
+	var list []ComponentValue
+	for {
+		t := s.nextSignificant()
+		if t.Type == EOF || t.Type == Error {
+			return list
+		}
+		list = append(list, s.componentValue(t))
+	}
+}
+
+// ParseComponentValueList parses input into a list of component values.
+// The scanner runs in Recover mode, so all problems in the input are
+// returned as diagnostics.
+func ParseComponentValueList(input string) ([]ComponentValue, []Diagnostic) {
+	s := New(input)
+	s.Mode = Recover
+	list := s.ComponentValues()
+	return list, s.Diagnostics()
+}
+
+// nextSignificant returns the next token that is not a comment.
+func (s *Scanner) nextSignificant() *Token {
+	for {
+		t := s.Next()
+		if t.Type != Comment {
+			return t
+		}
+	}
+}
+
+// componentValue consumes the component value that starts with t.
+func (s *Scanner) componentValue(t *Token) ComponentValue {
+	end := closing(t)
+	if end == 0 {
+		return ComponentValue{Kind: PreservedToken, Token: t}
+	}
+	c := ComponentValue{Kind: SimpleBlock, Token: t}
+	if t.Type == Function {
+		c.Kind = FunctionBlock
+	}
+	for {
+		next := s.nextSignificant()
+		switch {
+		case next.Type == EOF || next.Type == Error:
+			s.reportToken(t, SeverityError, CodeUnclosedBlock, "unclosed block")
+			return c
+		case punct(next) == end:
+			c.End = next
+			return c
+		}
+		c.Values = append(c.Values, s.componentValue(next))
+	}
+}
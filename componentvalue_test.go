@@ -0,0 +1,153 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// componentJSON returns the css-parsing-tests representation of c.
+func componentJSON(c *ComponentValue) any {
+	var res []any
+	switch c.Kind {
+	case FunctionBlock:
+		res = []any{"function", c.Name()}
+	case SimpleBlock:
+		res = []any{c.Token.Value + string(closing(c.Token))}
+	default:
+		if ch := punct(c.Token); ch == ')' || ch == ']' || ch == '}' {
+			return []any{"error", string(ch)}
+		}
+		return tokenJSON(c.Token)
+	}
+	for i := range c.Values {
+		res = append(res, componentJSON(&c.Values[i]))
+	}
+	return res
+}
+
+func TestComponentValueList(t *testing.T) {
+	for _, test := range loadParsingTests(t, "component_value_list.json") {
+		input := test[0].(string)
+		s := New(input)
+		s.Mode = Syntax3
+		got := []any{}
+		for _, c := range s.ComponentValues() {
+			got = append(got, componentJSON(&c))
+		}
+		checkParsingTest(t, input, got, test[1])
+	}
+}
+
+// corpusSkips lists the cases of the upstream css-parsing-tests corpus that
+// TestCorpus does not check, by input, with the reason.
+var corpusSkips = map[string]string{}
+
+// corpusFiles are the files of the upstream corpus in
+// testdata/css-parsing-tests that are checked, with the function that
+// returns the result of an input in the corpus representation.
+var corpusFiles = map[string]func(input string) any{
+	"component_value_list.json": func(input string) any {
+		s := New(input)
+		s.Mode = Syntax3
+		got := []any{}
+		for _, c := range s.ComponentValues() {
+			got = append(got, componentJSON(&c))
+		}
+		return got
+	},
+	"one_component_value.json": func(input string) any {
+		s := New(input)
+		s.Mode = Syntax3
+		list := trimWhitespace(s.ComponentValues())
+		for len(list) > 0 && list[0].IsWhitespace() {
+			list = list[1:]
+		}
+		switch len(list) {
+		case 0:
+			return []any{"error", "empty"}
+		case 1:
+			return componentJSON(&list[0])
+		}
+		return []any{"error", "extra-input"}
+	},
+}
+
+func TestCorpus(t *testing.T) {
+	for _, name := range []string{"LICENSE", "SOURCE"} {
+		if _, err := os.Stat("testdata/css-parsing-tests/" + name); err != nil {
+			t.Errorf("upstream corpus incomplete: %v (see testdata/css-parsing-tests/README.md)", err)
+		}
+	}
+	for name, result := range corpusFiles {
+		name = "css-parsing-tests/" + name
+		if _, err := os.Stat("testdata/" + name); err != nil {
+			t.Errorf("upstream corpus incomplete: %v (see testdata/css-parsing-tests/README.md)", err)
+			continue
+		}
+		for _, test := range loadParsingTests(t, name) {
+			input := test[0].(string)
+			if reason, ok := corpusSkips[input]; ok {
+				t.Logf("Skipping %q: %s", input, reason)
+				continue
+			}
+			checkParsingTest(t, input, result(input), test[1])
+		}
+	}
+}
+
+func TestComponentValuesDefaultMode(t *testing.T) {
+	list, diagnostics := ParseComponentValueList("local(Foo), url(a.woff) format('woff') fn(a{b}) [x")
+	if len(list) != 10 {
+		t.Fatalf("Expected 10 component values, got %d", len(list))
+	}
+	for i, want := range []struct {
+		kind ComponentKind
+		typ  Type
+	}{
+		{PreservedToken, Local},
+		{PreservedToken, Delim},
+		{PreservedToken, S},
+		{PreservedToken, URI},
+		{PreservedToken, S},
+		{PreservedToken, Format},
+		{PreservedToken, S},
+		{FunctionBlock, Function},
+		{PreservedToken, S},
+		{SimpleBlock, Delim},
+	} {
+		if list[i].Kind != want.kind || list[i].Token.Type != want.typ {
+			t.Errorf("Component value %d: expected %v %s, got %v %s", i, want.kind, want.typ, list[i].Kind, list[i].Token.Type)
+		}
+	}
+	fn := list[7]
+	if fn.Name() != "fn" || len(fn.Values) != 2 || !fn.Values[1].IsBlock('{') || fn.End == nil {
+		t.Errorf("Unexpected function %#v", fn)
+	}
+	if !list[9].IsBlock('[') || list[9].End != nil {
+		t.Errorf("Expected an unclosed [] block, got %#v", list[9])
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Code != CodeUnclosedBlock || diagnostics[0].Column != 49 {
+		t.Errorf("Unexpected diagnostics %v", diagnostics)
+	}
+}
+
+func TestComponentValueEmit(t *testing.T) {
+	input := "a { color: rgb(1 2 3 / 50%) } /* x */ [b] )"
+	s := New(input)
+	s.Mode = Lossless
+	var buf bytes.Buffer
+	for _, c := range s.ComponentValues() {
+		if err := c.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "a { color: rgb(1 2 3 / 50%) }  [b] )"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
@@ -35,8 +35,8 @@ var Number = Type{6}
 // Percentage token type is for percentages. The .Value does not include the %.
 var Percentage = Type{7}
 
-// Dimension token type is for dimensions. No further parsing is done on the
-// dimension, which may be bad since we could break in into number and unit.
+// Dimension token type is for dimensions. The .Value contains the number
+// and the unit, the unit alone is in .Unit.
 var Dimension = Type{8}
 
 // URI token type is for URIs. The .Value will be the processed URI.
@@ -51,7 +51,9 @@ var Format = Type{102}
 // Tech token from src:
 var Tech = Type{103}
 
-// UnicodeRange token type is for Unicode ranges.
+// UnicodeRange token type is for Unicode ranges written with uppercase
+// hex digits, such as U+0025-00FF or u+4??. Ranges with lowercase hex
+// digits are scanned as other tokens, see ParseUnicodeRangeValues.
 var UnicodeRange = Type{10}
 
 // CDO token type represents the <!-- string.
@@ -93,6 +95,44 @@ var Delim = Type{21}
 // BOM token type refers to Byte Order Marks.
 var BOM = Type{22}
 
+// The following token types are only produced in Syntax3 mode. Otherwise
+// these characters are returned as Delim tokens.
+
+// Colon token type refers to ":".
+var Colon = Type{23}
+
+// Semicolon token type refers to ";".
+var Semicolon = Type{24}
+
+// Comma token type refers to ",".
+var Comma = Type{25}
+
+// LeftBracket token type refers to "[".
+var LeftBracket = Type{26}
+
+// RightBracket token type refers to "]".
+var RightBracket = Type{27}
+
+// LeftParen token type refers to "(".
+var LeftParen = Type{28}
+
+// RightParen token type refers to ")".
+var RightParen = Type{29}
+
+// LeftBrace token type refers to "{".
+var LeftBrace = Type{30}
+
+// RightBrace token type refers to "}".
+var RightBrace = Type{31}
+
+// BadString token type is for strings that contain an unescaped newline.
+// The token ends before the newline and its .Value is empty.
+var BadString = Type{32}
+
+// BadURL token type is for url() tokens with invalid content. The .Value
+// is empty.
+var BadURL = Type{33}
+
 // tokenNames maps Type's to their names. Used for conversion to string.
 var tokenNames = map[Type]string{
 	Error:          "error",
@@ -121,4 +161,15 @@ var tokenNames = map[Type]string{
 	SubstringMatch: "SUBSTRINGMATCH",
 	Delim:          "DELIM",
 	BOM:            "BOM",
+	Colon:          "COLON",
+	Semicolon:      "SEMICOLON",
+	Comma:          "COMMA",
+	LeftBracket:    "LEFT-BRACKET",
+	RightBracket:   "RIGHT-BRACKET",
+	LeftParen:      "LEFT-PAREN",
+	RightParen:     "RIGHT-PAREN",
+	LeftBrace:      "LEFT-BRACE",
+	RightBrace:     "RIGHT-BRACE",
+	BadString:      "BAD-STRING",
+	BadURL:         "BAD-URL",
 }
@@ -0,0 +1,283 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity tells how serious a Diagnostic is.
+type Severity int
+
+const (
+	// SeverityWarning is used for input that is tokenized without loss
+	// but is probably not what the author intended.
+	SeverityWarning Severity = iota
+	// SeverityError is used for input that violates the CSS syntax, such
+	// as an unclosed string. In the default mode these also stop the
+	// scanner with an Error token.
+	SeverityError
+)
+
+// String returns a string representation of the severity.
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Code identifies the kind of problem a Diagnostic describes.
+type Code int
+
+const (
+	// CodeUnclosedString is reported for a string that is not closed
+	// before the end of the input.
+	CodeUnclosedString Code = iota + 1
+	// CodeNewlineInString is reported for a string that runs into an
+	// unescaped newline.
+	CodeNewlineInString
+	// CodeUnclosedComment is reported for a comment that is not closed
+	// before the end of the input.
+	CodeUnclosedComment
+	// CodeUnclosedURL is reported for an unquoted url() that is not closed
+	// before the end of the input (Syntax3 mode).
+	CodeUnclosedURL
+	// CodeBadURL is reported for a url() whose body is invalid. In the
+	// default mode the url is then scanned as a Function token.
+	CodeBadURL
+	// CodeLoneBackslash is reported for a backslash that does not start
+	// an escape because it is followed by a newline or the end of the
+	// input.
+	CodeLoneBackslash
+	// CodeInvalidEscape is reported for a hex escape of U+0000 or of a
+	// value beyond U+10FFFF.
+	CodeInvalidEscape
+	// CodeSurrogateEscape is reported for a hex escape of a UTF-16
+	// surrogate code point, which is replaced by U+FFFD.
+	CodeSurrogateEscape
+	// CodeNULCharacter is reported for a NUL character in the input.
+	CodeNULCharacter
+	// CodeUnclosedBlock is reported for a {}, [] or () block or a function
+	// that is not closed before the end of the input.
+	CodeUnclosedBlock
+	// CodeInvalidRule is reported for a qualified rule that has no block,
+	// and for a rule passed to a method such as Rule.Page that expects
+	// another kind of rule.
+	CodeInvalidRule
+	// CodeInvalidDeclaration is reported for input in a block that is
+	// neither a declaration nor a rule.
+	CodeInvalidDeclaration
+	// CodeInvalidSelector is reported for a selector that can not be
+	// parsed.
+	CodeInvalidSelector
+	// CodeInvalidAnPlusB is reported for an invalid An+B argument, as in
+	// :nth-child(2n +- 1).
+	CodeInvalidAnPlusB
+	// CodeInvalidMediaQuery is reported for a media query that can not be
+	// parsed and is treated as "not all".
+	CodeInvalidMediaQuery
+	// CodeInvalidSupports is reported for an @supports condition that can
+	// not be parsed.
+	CodeInvalidSupports
+	// CodeInvalidPageSelector is reported for an @page selector that can
+	// not be parsed.
+	CodeInvalidPageSelector
+	// CodeInvalidDescriptor is reported for a descriptor of an at-rule
+	// such as @font-face whose value is invalid, and for a missing
+	// required descriptor.
+	CodeInvalidDescriptor
+	// CodeInvalidUnicodeRange is reported for a unicode range list that
+	// can not be parsed.
+	CodeInvalidUnicodeRange
+	// CodeInvalidColor is reported for a color that can not be parsed.
+	CodeInvalidColor
+	// CodeInvalidCalc is reported for a math function such as calc() that
+	// can not be evaluated.
+	CodeInvalidCalc
+	// CodeInvalidVar is reported for a var() reference that can not be
+	// substituted.
+	CodeInvalidVar
+	// CodeInvalidLength is reported for a length or other dimension that
+	// can not be parsed.
+	CodeInvalidLength
+	// CodeUnresolvedPercentage is reported for a math function that
+	// combines a percentage with another type, such as calc(50% - 2pt),
+	// when the context has no percentage basis to resolve it.
+	CodeUnresolvedPercentage
+)
+
+var codeNames = map[Code]string{
+	CodeUnclosedString:       "unclosed-string",
+	CodeNewlineInString:      "newline-in-string",
+	CodeUnclosedComment:      "unclosed-comment",
+	CodeUnclosedURL:          "unclosed-url",
+	CodeBadURL:               "bad-url",
+	CodeLoneBackslash:        "lone-backslash",
+	CodeInvalidEscape:        "invalid-escape",
+	CodeSurrogateEscape:      "surrogate-escape",
+	CodeNULCharacter:         "nul-character",
+	CodeUnclosedBlock:        "unclosed-block",
+	CodeInvalidRule:          "invalid-rule",
+	CodeInvalidDeclaration:   "invalid-declaration",
+	CodeInvalidSelector:      "invalid-selector",
+	CodeInvalidAnPlusB:       "invalid-an-plus-b",
+	CodeInvalidMediaQuery:    "invalid-media-query",
+	CodeInvalidSupports:      "invalid-supports-condition",
+	CodeInvalidPageSelector:  "invalid-page-selector",
+	CodeInvalidDescriptor:    "invalid-descriptor",
+	CodeInvalidUnicodeRange:  "invalid-unicode-range",
+	CodeInvalidColor:         "invalid-color",
+	CodeInvalidCalc:          "invalid-calc",
+	CodeInvalidVar:           "invalid-var",
+	CodeInvalidLength:        "invalid-length",
+	CodeUnresolvedPercentage: "unresolved-percentage",
+}
+
+// String returns the name of the code.
+func (c Code) String() string {
+	return codeNames[c]
+}
+
+// Diagnostic describes a problem in the input. Line, Column and Offset
+// give the start of the affected input, EndLine, EndColumn and EndOffset
+// the position just after it, with the same meaning as in Token.
+type Diagnostic struct {
+	Severity  Severity
+	Code      Code
+	Message   string
+	Line      int
+	Column    int
+	Offset    int
+	EndLine   int
+	EndColumn int
+	EndOffset int
+}
+
+// String returns a string representation of the diagnostic.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d, column %d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+// Error returns the string representation of the diagnostic, so that the
+// parse functions of this package can return a *Diagnostic as error.
+func (d *Diagnostic) Error() string {
+	return d.String()
+}
+
+// syntaxError returns an error diagnostic that covers span.
+func syntaxError(span Span, code Code, msg string) *Diagnostic {
+	return &Diagnostic{
+		Severity:  SeverityError,
+		Code:      code,
+		Message:   msg,
+		Line:      span.Line,
+		Column:    span.Column,
+		Offset:    span.Offset,
+		EndLine:   span.EndLine,
+		EndColumn: span.EndColumn,
+		EndOffset: span.EndOffset,
+	}
+}
+
+// Diagnostics returns the problems the scanner has found so far, in input
+// order.
+func (s *Scanner) Diagnostics() []Diagnostic {
+	return s.diagnostics
+}
+
+// reportToken records a diagnostic that covers token.
+func (s *Scanner) reportToken(token *Token, sev Severity, code Code, msg string) {
+	s.reportSpan(spanOf(token, token), sev, code, msg)
+}
+
+// reportSpan records a diagnostic that covers span. It is placed before
+// the diagnostics already reported for input after the start of span, so
+// that the diagnostics stay in input order.
+func (s *Scanner) reportSpan(span Span, sev Severity, code Code, msg string) {
+	i := len(s.diagnostics)
+	for i > 0 && s.diagnostics[i-1].Offset > span.Offset {
+		i--
+	}
+	s.diagnostics = slices.Insert(s.diagnostics, i, Diagnostic{
+		Severity:  sev,
+		Code:      code,
+		Message:   msg,
+		Line:      span.Line,
+		Column:    span.Column,
+		Offset:    span.Offset,
+		EndLine:   span.EndLine,
+		EndColumn: span.EndColumn,
+		EndOffset: span.EndOffset,
+	})
+}
+
+// reportIn records a diagnostic that covers token.Raw()[start:end].
+func (s *Scanner) reportIn(token *Token, start, end int, sev Severity, code Code, msg string) {
+	d := Diagnostic{Severity: sev, Code: code, Message: msg}
+	d.Line, d.Column = advancePosition(token.Line, token.Column, token.raw[:start])
+	d.EndLine, d.EndColumn = advancePosition(d.Line, d.Column, token.raw[start:end])
+	d.Offset = token.Offset + start
+	d.EndOffset = token.Offset + end
+	s.diagnostics = append(s.diagnostics, d)
+}
+
+// advancePosition returns the line and column after text, starting at
+// line and column. It counts like Scanner.updatePosition.
+func advancePosition(line, column int, text string) (int, int) {
+	lines := strings.Count(text, "\n")
+	if lines == 0 {
+		return line, column + utf8.RuneCountInString(text)
+	}
+	return line + lines, utf8.RuneCountInString(text[strings.LastIndex(text, "\n"):])
+}
+
+// checkRaw reports the problems that the scanner silently recovers from
+// inside a token: NUL characters, lone backslashes and escapes of code
+// points that can not be represented.
+func (s *Scanner) checkRaw(token *Token) {
+	raw := token.raw
+	if strings.IndexAny(raw, "\\\x00") < 0 {
+		return
+	}
+	escapes := token.Type != Comment && token.Type != Delim
+	if token.Type == Delim && raw == "\\" {
+		s.reportIn(token, 0, 1, SeverityWarning, CodeLoneBackslash, "lone backslash")
+		return
+	}
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case c == 0:
+			s.reportIn(token, i, i+1, SeverityWarning, CodeNULCharacter, "NUL character")
+		case c == '\\' && escapes:
+			if i+1 == len(raw) {
+				s.reportIn(token, i, i+1, SeverityWarning, CodeLoneBackslash, "lone backslash")
+				continue
+			}
+			if !isHexChar(raw[i+1]) {
+				i++ // skip the escaped character
+				continue
+			}
+			j := i + 1
+			for j < len(raw) && j-i <= 6 && isHexChar(raw[j]) {
+				j++
+			}
+			r := decodeHex([]byte(raw[i+1 : j]))
+			switch {
+			case r == 0 || r > utf8.MaxRune:
+				s.reportIn(token, i, j, SeverityWarning, CodeInvalidEscape,
+					fmt.Sprintf("escape of invalid code point U+%X", r))
+			case r >= 0xD800 && r <= 0xDFFF:
+				s.reportIn(token, i, j, SeverityWarning, CodeSurrogateEscape,
+					fmt.Sprintf("escape of surrogate code point U+%X", r))
+			}
+			i = j - 1
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package css
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		mode        Mode
+		input       string
+		diagnostics []Diagnostic
+	}{
+		{0, "a b", nil},
+		{0, "a \\\nb", []Diagnostic{
+			{SeverityWarning, CodeLoneBackslash, "lone backslash", 1, 3, 2, 1, 4, 3},
+		}},
+		{0, "\\0 b", []Diagnostic{
+			{SeverityWarning, CodeInvalidEscape, "escape of invalid code point U+0", 1, 1, 0, 1, 3, 2},
+		}},
+		{0, "x\n.a\\D800 b", []Diagnostic{
+			{SeverityWarning, CodeSurrogateEscape, "escape of surrogate code point U+D800", 2, 3, 4, 2, 8, 9},
+		}},
+		{0, "'\\110000'", []Diagnostic{
+			{SeverityWarning, CodeInvalidEscape, "escape of invalid code point U+110000", 1, 2, 1, 1, 9, 8},
+		}},
+		{0, "'\\\\0'", nil},
+		{0, "a\x00b", []Diagnostic{
+			{SeverityWarning, CodeNULCharacter, "NUL character", 1, 2, 1, 1, 3, 2},
+		}},
+		{0, "/* \\0 */", nil},
+		{0, "url(a b)", []Diagnostic{
+			{SeverityWarning, CodeBadURL, "invalid url(), scanned as function", 1, 1, 0, 1, 5, 4},
+		}},
+		{0, "a 'b", []Diagnostic{
+			{SeverityError, CodeUnclosedString, "unclosed quotation mark", 1, 3, 2, 1, 3, 2},
+		}},
+		{Recover, "a 'b\\0", []Diagnostic{
+			{SeverityError, CodeUnclosedString, "unclosed quotation mark", 1, 3, 2, 1, 7, 6},
+			{SeverityWarning, CodeInvalidEscape, "escape of invalid code point U+0", 1, 5, 4, 1, 7, 6},
+		}},
+		{Syntax3, "/* a\nb", []Diagnostic{
+			{SeverityError, CodeUnclosedComment, "unclosed comment", 1, 1, 0, 2, 2, 6},
+		}},
+		{Syntax3, "a\\", []Diagnostic{
+			{SeverityWarning, CodeLoneBackslash, "lone backslash", 1, 2, 1, 1, 3, 2},
+		}},
+	} {
+		for _, s := range []*Scanner{
+			New(test.input),
+			NewReader(iotest.OneByteReader(strings.NewReader(test.input))),
+		} {
+			s.Mode = test.mode
+			for {
+				tok := s.Next()
+				if tok.Type == EOF || tok.Type == Error {
+					break
+				}
+			}
+			d := s.Diagnostics()
+			if len(d) != len(test.diagnostics) {
+				t.Fatalf("For %q: expected diagnostics %v, got %v", test.input, test.diagnostics, d)
+			}
+			for i := range d {
+				if d[i] != test.diagnostics[i] {
+					t.Errorf("For %q: expected diagnostic %#v, got %#v", test.input, test.diagnostics[i], d[i])
+				}
+			}
+		}
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{Severity: SeverityWarning, Code: CodeNULCharacter, Message: "NUL character", Line: 3, Column: 7}
+	if d.String() != "line 3, column 7: warning: NUL character" {
+		t.Errorf("Unexpected string representation %q", d.String())
+	}
+	if d.Code.String() != "nul-character" {
+		t.Errorf("Unexpected code name %q", d.Code.String())
+	}
+}
@@ -19,12 +19,92 @@ the token returned has type scanner.EOF or scanner.Error:
 		// Use token.Type, token.Value, token.Line, token.Column
 	}
 
+Large stylesheets can be tokenized from an io.Reader with NewReader, which
+reads the input incrementally and produces the same token stream.
+
 Token values are post-processed to contain semantic content: CSS escapes are
 resolved, quotes are stripped from strings, and delimiters are removed from
 functions and URLs. Tokens can be re-emitted to valid CSS via token.Emit(w).
 
 Following the CSS specification, an error can only occur when the scanner
 finds an unclosed quote or unclosed comment. Everything else is tokenizable
-and it is up to a parser to make sense of the token stream.
+and it is up to a parser to make sense of the token stream. In Recover mode
+the scanner continues after such errors and records them as Diagnostics.
+Diagnostics also include warnings for input that is tokenized but suspect,
+such as lone backslashes, invalid escapes and NUL characters.
+
+# Tokens
+
+Each token records where it starts (Line, Column, Offset) and ends
+(EndLine, EndColumn, EndOffset). Columns count runes, offsets are byte
+offsets into the input, and Token.Raw returns the source text of the token.
+In Lossless mode Emit writes every unmodified token exactly as it appeared
+in the input.
+
+Number, Percentage and Dimension tokens carry their value in Token.Num.
+Token.Integer tells whether the number has the CSS Syntax 3 "integer" type,
+Token.Signed whether it had an explicit sign, and Token.Unit holds the unit
+of a dimension in the case of the source. Token.LowerUnit returns it in
+lower case for comparisons.
+
+The Syntax3 mode produces exactly the token set of the CSS Syntax Module
+Level 3 tokenizer, with its error recovery instead of Error tokens.
+
+# Parsing
+
+Scanner.ComponentValues and ParseComponentValueList return the input as a
+tree of component values: preserved tokens, functions and blocks.
+ParseStylesheet parses a style sheet into rules with their preludes,
+declarations and nested rules, following the error recovery of CSS Syntax
+Level 3, and ParseDeclarationList parses the contents of a declaration
+block such as an HTML style attribute. Stylesheet.Desugar flattens nested
+rules, and ResolveSelector resolves the nesting selector &.
+
+Most parse functions come in pairs: ParseX parses a string, ParseXValues
+parses component values, for example the prelude of a rule or the value of
+a declaration. Problems are returned as *Diagnostic errors or as a list of
+Diagnostics, each with a Code and the position of the input.
+
+# Selectors
+
+ParseSelectorList parses a Selectors Level 4 selector list, including the
+selector arguments of :is(), :where(), :not(), :has() and
+:nth-child(An+B of S). Specificity computes the specificity of a selector,
+and ParseAnPlusB parses the An+B notation. A Matcher matches selectors
+against any document tree that implements the Element interface.
+
+# At-rules
+
+ParseMediaQueryList parses a Media Queries Level 4 list, and
+MediaQueryList.Match evaluates it against a MediaEnvironment. Unknown
+features and invalid values follow the three-valued logic of the
+specification.
+
+ParseSupportsCondition parses the condition of an @supports rule, and
+SupportsCondition.Match evaluates it against a SupportsEnvironment.
+
+Rule.Page turns an @page rule into a PageRule with its page selectors,
+declarations and margin rules, and PageRule.Match tells whether it applies
+to a page.
+
+Rule.FontFace and ParseFontFace read the descriptors of an @font-face rule
+into a FontFace. ParseUnicodeRange parses unicode-range values into a
+UnicodeRangeSet.
+
+# Values
+
+ParseColor parses the colors of CSS Color 4 and 5, including the relative
+color syntax, color-mix() and device-cmyk(). A Color converts to sRGB with
+gamut mapping and to device CMYK. SubstituteVars replaces var() references
+before a value is parsed.
+
+EvalCalc evaluates math functions such as calc(), min() and round(). A
+CalcContext resolves percentages, relative units and keywords.
+
+ParseLength parses a dimension into a Length, which Length.Points converts
+to PDF points with the sizes of a LengthContext.
+
+	l, _ := scanner.ParseLength("1.5em")
+	pt, _ := l.Points(&scanner.LengthContext{FontSize: 10})
 */
 package css
@@ -0,0 +1,441 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"slices"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Fonts Module Level 4: @font-face descriptors
+// --------------------------------------------------------------------
+
+// FontFace holds the descriptors of an @font-face rule.
+type FontFace struct {
+	Span
+	// Family is the font family name.
+	Family string
+	// Sources are the entries of the src descriptor in order of
+	// preference. Entries that can not be parsed are left out.
+	Sources []FontSource
+	// UnicodeRange is the set of code points of the unicode-range
+	// descriptor. It is empty if the descriptor is not given, which
+	// means U+0-10FFFF; use Covers to test a code point.
+	UnicodeRange UnicodeRangeSet
+	// Weight is the range of the font-weight descriptor, from 1 to 1000.
+	// Stretch is the range of the font-stretch descriptor as percentages
+	// of the normal width. The zero value stands for auto.
+	Weight, Stretch FontRange
+	// Style is the lowercased keyword of the font-style descriptor:
+	// "auto", "normal", "italic" or "oblique". Oblique is the range of
+	// the oblique angle in degrees, 14deg if none is given.
+	Style   string
+	Oblique FontRange
+	// Display is the lowercased keyword of the font-display descriptor:
+	// "auto", "block", "swap", "fallback" or "optional".
+	Display string
+	// AscentOverride, DescentOverride and LineGapOverride are the metric
+	// override percentages, nil for normal.
+	AscentOverride, DescentOverride, LineGapOverride *float64
+	// SizeAdjust is the size-adjust percentage, 100 if not given.
+	SizeAdjust float64
+}
+
+// FontSource is an entry of the src descriptor: either the URL of a font
+// file with optional format and technology hints, or the name of a
+// locally installed font.
+type FontSource struct {
+	// URL is the URL of a url() entry.
+	URL string
+	// Local is the font name of a local() entry.
+	Local string
+	// Format is the lowercased argument of format(), or "".
+	Format string
+	// Tech are the lowercased arguments of tech().
+	Tech []string
+}
+
+// FontRange is a range of values for a font descriptor. A single value is
+// a range with Min equal to Max.
+type FontRange struct {
+	Min, Max float64
+}
+
+// ParseFontFace parses input as the contents of an @font-face block.
+func ParseFontFace(input string) (*FontFace, []Diagnostic) {
+	decls, _, diagnostics := ParseDeclarationList(input)
+	face, errors := fontFace(Span{}, decls)
+	return face, append(diagnostics, errors...)
+}
+
+// FontFace returns the descriptors of the @font-face rule r. Descriptors
+// with invalid values are ignored and reported as diagnostics. If r is not
+// an @font-face rule, the FontFace is nil.
+func (r *Rule) FontFace() (*FontFace, []Diagnostic) {
+	if r.AtKeyword == nil || !strings.EqualFold(r.Name(), "font-face") {
+		return nil, []Diagnostic{*syntaxError(r.Span, CodeInvalidRule, "not an @font-face rule")}
+	}
+	decls := r.Declarations
+	for _, child := range r.Rules {
+		if child.IsNestedDeclarations() {
+			decls = append(slices.Clip(decls), child.Declarations...)
+		}
+	}
+	return fontFace(r.Span, decls)
+}
+
+// Covers reports whether the unicode-range of the face includes r.
+func (f *FontFace) Covers(r rune) bool {
+	return len(f.UnicodeRange) == 0 || f.UnicodeRange.Contains(r)
+}
+
+// fontFace builds a FontFace from decls; later declarations override
+// earlier ones.
+func fontFace(span Span, decls []*Declaration) (*FontFace, []Diagnostic) {
+	face := &FontFace{Span: span, Style: "auto", Display: "auto", SizeAdjust: 100}
+	var diagnostics []Diagnostic
+	invalid := func(d *Declaration, msg string) {
+		diagnostics = append(diagnostics, *syntaxError(d.Span, CodeInvalidDescriptor, msg))
+	}
+	hasFamily, hasSrc := false, false
+	for _, d := range decls {
+		value := d.Value
+		switch name := strings.ToLower(d.Name); name {
+		case "font-family":
+			family, ok := familyName(value, false)
+			if !ok {
+				invalid(d, "invalid font-family")
+				continue
+			}
+			face.Family, hasFamily = family, true
+		case "src":
+			sources := fontSources(value)
+			if len(sources) == 0 {
+				invalid(d, "no valid entry in src")
+				continue
+			}
+			face.Sources, hasSrc = sources, true
+		case "unicode-range":
+			ranges, err := ParseUnicodeRangeValues(value)
+			if err != nil {
+				invalid(d, "invalid unicode-range")
+				continue
+			}
+			face.UnicodeRange = ranges
+		case "font-weight":
+			r, ok := fontDescriptorRange(significant(value), fontWeightValue)
+			if !ok {
+				invalid(d, "invalid font-weight")
+				continue
+			}
+			face.Weight = r
+		case "font-stretch":
+			r, ok := fontDescriptorRange(significant(value), fontStretchValue)
+			if !ok {
+				invalid(d, "invalid font-stretch")
+				continue
+			}
+			face.Stretch = r
+		case "font-style":
+			style, oblique, ok := fontStyle(value)
+			if !ok {
+				invalid(d, "invalid font-style")
+				continue
+			}
+			face.Style, face.Oblique = style, oblique
+		case "font-display":
+			keyword := singleIdent(value)
+			switch keyword {
+			case "auto", "block", "swap", "fallback", "optional":
+				face.Display = keyword
+			default:
+				invalid(d, "invalid font-display")
+			}
+		case "ascent-override", "descent-override", "line-gap-override":
+			var p *float64
+			if singleIdent(value) != "normal" {
+				v, ok := percentage(value)
+				if !ok {
+					invalid(d, "invalid "+name)
+					continue
+				}
+				p = &v
+			}
+			switch name {
+			case "ascent-override":
+				face.AscentOverride = p
+			case "descent-override":
+				face.DescentOverride = p
+			default:
+				face.LineGapOverride = p
+			}
+		case "size-adjust":
+			v, ok := percentage(value)
+			if !ok {
+				invalid(d, "invalid size-adjust")
+				continue
+			}
+			face.SizeAdjust = v
+		}
+	}
+	if !hasFamily || !hasSrc {
+		missing := "font-family"
+		if hasFamily {
+			missing = "src"
+		}
+		diagnostics = append(diagnostics, *syntaxError(span, CodeInvalidDescriptor, "@font-face without "+missing))
+	}
+	return face, diagnostics
+}
+
+// significant returns list without whitespace.
+func significant(list []ComponentValue) []*ComponentValue {
+	var res []*ComponentValue
+	for i := range list {
+		if !list[i].IsWhitespace() {
+			res = append(res, &list[i])
+		}
+	}
+	return res
+}
+
+// singleIdent returns the lowercased identifier if list is a single
+// identifier, and "" otherwise.
+func singleIdent(list []ComponentValue) string {
+	values := significant(list)
+	if len(values) != 1 || !isToken(values[0], Ident) {
+		return ""
+	}
+	return strings.ToLower(values[0].Token.Value)
+}
+
+// genericFamilies are the keywords that can not be used as an unquoted
+// family name in @font-face.
+var genericFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "cursive": true, "fantasy": true,
+	"monospace": true, "system-ui": true, "emoji": true, "math": true,
+	"fangsong": true, "ui-serif": true, "ui-sans-serif": true,
+	"ui-monospace": true, "ui-rounded": true,
+	"inherit": true, "initial": true, "unset": true, "revert": true,
+	"revert-layer": true, "default": true,
+}
+
+// familyName returns the family name in list: a string or a sequence of
+// identifiers, which are joined by single spaces. In local() a single
+// generic family keyword is allowed.
+func familyName(list []ComponentValue, local bool) (string, bool) {
+	values := significant(list)
+	if len(values) == 1 && isToken(values[0], String) {
+		return values[0].Token.Value, true
+	}
+	if len(values) == 0 {
+		return "", false
+	}
+	parts := make([]string, len(values))
+	for i, c := range values {
+		if !isToken(c, Ident) {
+			return "", false
+		}
+		parts[i] = c.Token.Value
+	}
+	if len(parts) == 1 && !local && genericFamilies[strings.ToLower(parts[0])] {
+		return "", false
+	}
+	return strings.Join(parts, " "), true
+}
+
+// fontSources parses the comma separated entries of the src descriptor
+// and skips the invalid ones.
+func fontSources(list []ComponentValue) []FontSource {
+	var res []FontSource
+	for _, item := range splitCommas(list) {
+		if src, ok := fontSource(significant(item)); ok {
+			res = append(res, src)
+		}
+	}
+	return res
+}
+
+// splitCommas splits list at the top level commas.
+func splitCommas(list []ComponentValue) [][]ComponentValue {
+	var res [][]ComponentValue
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i == len(list) || list[i].IsDelim(',') {
+			res = append(res, list[start:i])
+			start = i + 1
+		}
+	}
+	return res
+}
+
+// fontSource parses a single src entry.
+func fontSource(values []*ComponentValue) (FontSource, bool) {
+	var src FontSource
+	if len(values) == 0 {
+		return src, false
+	}
+	first := values[0]
+	switch {
+	case isToken(first, Local):
+		src.Local = first.Token.Value
+		return src, len(values) == 1 && src.Local != ""
+	case first.Kind == FunctionBlock && strings.EqualFold(first.Name(), "local"):
+		name, ok := familyName(first.Values, true)
+		src.Local = name
+		return src, ok && len(values) == 1
+	case isToken(first, URI):
+		src.URL = first.Token.Value
+	case first.Kind == FunctionBlock && strings.EqualFold(first.Name(), "url"):
+		args := significant(first.Values)
+		if len(args) != 1 || !isToken(args[0], String) {
+			return src, false
+		}
+		src.URL = args[0].Token.Value
+	default:
+		return src, false
+	}
+	rest := values[1:]
+	if len(rest) > 0 {
+		switch c := rest[0]; {
+		case isToken(c, Format):
+			src.Format = strings.ToLower(c.Token.Value)
+			rest = rest[1:]
+		case c.Kind == FunctionBlock && strings.EqualFold(c.Name(), "format"):
+			// The legacy syntax allows a list of strings; the first
+			// one is used.
+			args := significant(c.Values)
+			if len(args) == 0 || !isToken(args[0], String) && !isToken(args[0], Ident) {
+				return src, false
+			}
+			src.Format = strings.ToLower(args[0].Token.Value)
+			rest = rest[1:]
+		}
+	}
+	if len(rest) > 0 {
+		switch c := rest[0]; {
+		case isToken(c, Tech):
+			src.Tech = []string{strings.ToLower(c.Token.Value)}
+			rest = rest[1:]
+		case c.Kind == FunctionBlock && strings.EqualFold(c.Name(), "tech"):
+			for _, item := range splitCommas(c.Values) {
+				tech := singleIdent(item)
+				if tech == "" {
+					return src, false
+				}
+				src.Tech = append(src.Tech, tech)
+			}
+			rest = rest[1:]
+		}
+	}
+	return src, len(rest) == 0
+}
+
+// fontDescriptorRange parses auto or one or two values with value. A range
+// whose first value is larger than the second is swapped.
+func fontDescriptorRange(values []*ComponentValue, value func(*ComponentValue) (float64, bool)) (FontRange, bool) {
+	if len(values) == 1 && isIdent(values[0], "auto") {
+		return FontRange{}, true
+	}
+	if len(values) == 0 || len(values) > 2 {
+		return FontRange{}, false
+	}
+	var r FontRange
+	var ok bool
+	if r.Min, ok = value(values[0]); !ok {
+		return FontRange{}, false
+	}
+	r.Max = r.Min
+	if len(values) == 2 {
+		if r.Max, ok = value(values[1]); !ok {
+			return FontRange{}, false
+		}
+	}
+	if r.Min > r.Max {
+		r.Min, r.Max = r.Max, r.Min
+	}
+	return r, true
+}
+
+// fontWeightValue parses normal, bold or a number from 1 to 1000.
+func fontWeightValue(c *ComponentValue) (float64, bool) {
+	switch {
+	case isIdent(c, "normal"):
+		return 400, true
+	case isIdent(c, "bold"):
+		return 700, true
+	case isToken(c, Number):
+		n := c.Token.Num
+		return n, n >= 1 && n <= 1000
+	}
+	return 0, false
+}
+
+// fontStretchKeywords are the percentages of the font-stretch keywords.
+var fontStretchKeywords = map[string]float64{
+	"ultra-condensed": 50,
+	"extra-condensed": 62.5,
+	"condensed":       75,
+	"semi-condensed":  87.5,
+	"normal":          100,
+	"semi-expanded":   112.5,
+	"expanded":        125,
+	"extra-expanded":  150,
+	"ultra-expanded":  200,
+}
+
+// fontStretchValue parses a font-stretch keyword or a percentage.
+func fontStretchValue(c *ComponentValue) (float64, bool) {
+	if isToken(c, Ident) {
+		v, ok := fontStretchKeywords[strings.ToLower(c.Token.Value)]
+		return v, ok
+	}
+	if isToken(c, Percentage) {
+		return c.Token.Num, c.Token.Num >= 0
+	}
+	return 0, false
+}
+
+// fontStyle parses the font-style descriptor.
+func fontStyle(list []ComponentValue) (string, FontRange, bool) {
+	values := significant(list)
+	if len(values) == 0 || !isToken(values[0], Ident) {
+		return "", FontRange{}, false
+	}
+	style := strings.ToLower(values[0].Token.Value)
+	switch style {
+	case "auto", "normal", "italic":
+		return style, FontRange{}, len(values) == 1
+	case "oblique":
+		if len(values) == 1 {
+			return style, FontRange{14, 14}, true
+		}
+		if isIdent(values[1], "auto") {
+			// Unlike the other ranges, oblique takes no auto.
+			return "", FontRange{}, false
+		}
+		r, ok := fontDescriptorRange(values[1:], obliqueAngle)
+		return style, r, ok
+	}
+	return "", FontRange{}, false
+}
+
+// obliqueAngle parses an angle from -90deg to 90deg and returns it in
+// degrees.
+func obliqueAngle(c *ComponentValue) (float64, bool) {
+	deg, ok := angle(c)
+	return deg, ok && deg >= -90 && deg <= 90
+}
+
+// percentage parses a single non-negative percentage.
+func percentage(list []ComponentValue) (float64, bool) {
+	values := significant(list)
+	if len(values) != 1 || !isToken(values[0], Percentage) || values[0].Token.Num < 0 {
+		return 0, false
+	}
+	return values[0].Token.Num, true
+}
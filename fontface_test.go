@@ -0,0 +1,132 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFontFace(t *testing.T) {
+	face, diagnostics := ParseFontFace(`
+		font-family: "Noto Serif";
+		src: local(Noto Serif Regular), local("NotoSerif-Regular"),
+		     url(noto.woff2) format("woff2") tech(color-COLRv1),
+		     url("noto.otf") format(opentype) tech(variations, palettes),
+		     url(noto.ttf) format("truetype", "opentype"),
+		     url(bad) foo, bar;
+		unicode-range: U+0000-00FF, U+0131, U+4??;
+		font-weight: 700 100;
+		font-stretch: condensed 110%;
+		font-style: oblique 10deg 0.25turn;
+		font-display: SWAP;
+		ascent-override: 90%;
+		line-gap-override: normal;
+		size-adjust: 105.5%;
+	`)
+	if len(diagnostics) > 0 {
+		t.Fatalf("Unexpected diagnostics %v", diagnostics)
+	}
+	if face.Family != "Noto Serif" {
+		t.Errorf("Unexpected family %q", face.Family)
+	}
+	expected := []FontSource{
+		{Local: "Noto Serif Regular"},
+		{Local: "NotoSerif-Regular"},
+		{URL: "noto.woff2", Format: "woff2", Tech: []string{"color-colrv1"}},
+		{URL: "noto.otf", Format: "opentype", Tech: []string{"variations", "palettes"}},
+		{URL: "noto.ttf", Format: "truetype"},
+	}
+	if !reflect.DeepEqual(face.Sources, expected) {
+		t.Errorf("Unexpected sources\n%+v\n%+v", face.Sources, expected)
+	}
+	if face.UnicodeRange.String() != "U+0-FF, U+131, U+400-4FF" {
+		t.Errorf("Unexpected unicode-range %v", face.UnicodeRange)
+	}
+	if face.Weight != (FontRange{100, 700}) {
+		t.Errorf("Unexpected weight %v", face.Weight)
+	}
+	if face.Stretch != (FontRange{75, 110}) {
+		t.Errorf("Unexpected stretch %v", face.Stretch)
+	}
+	if face.Style != "oblique" || face.Oblique != (FontRange{10, 90}) {
+		t.Errorf("Unexpected style %q %v", face.Style, face.Oblique)
+	}
+	if face.Display != "swap" {
+		t.Errorf("Unexpected display %q", face.Display)
+	}
+	if face.AscentOverride == nil || *face.AscentOverride != 90 || face.DescentOverride != nil || face.LineGapOverride != nil {
+		t.Errorf("Unexpected metric overrides %v %v %v", face.AscentOverride, face.DescentOverride, face.LineGapOverride)
+	}
+	if face.SizeAdjust != 105.5 {
+		t.Errorf("Unexpected size-adjust %v", face.SizeAdjust)
+	}
+}
+
+func TestParseFontFaceDefaults(t *testing.T) {
+	face, diagnostics := ParseFontFace("font-family: Foo Bar; src: url(x); font-style: oblique; font-weight: bold")
+	if len(diagnostics) > 0 {
+		t.Fatalf("Unexpected diagnostics %v", diagnostics)
+	}
+	if face.Family != "Foo Bar" || face.Display != "auto" || face.SizeAdjust != 100 || face.Stretch != (FontRange{}) {
+		t.Errorf("Unexpected defaults %+v", face)
+	}
+	if face.Style != "oblique" || face.Oblique != (FontRange{14, 14}) || face.Weight != (FontRange{700, 700}) {
+		t.Errorf("Unexpected style %q %v, weight %v", face.Style, face.Oblique, face.Weight)
+	}
+}
+
+func TestParseFontFaceErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"font-family: serif; src: url(x)", "invalid font-family"},
+		{"font-family: a 1; src: url(x)", "invalid font-family"},
+		{"font-family: a; src: url(x) foo", "no valid entry in src"},
+		{"font-family: a; src: url(x); unicode-range: U+00FF-0000", "invalid unicode-range"},
+		{"font-family: a; src: url(x); unicode-range: U+110000", "invalid unicode-range"},
+		{"font-family: a; src: url(x); unicode-range: U+0-7F x", "invalid unicode-range"},
+		{"font-family: a; src: url(x); font-weight: 0", "invalid font-weight"},
+		{"font-family: a; src: url(x); font-weight: 100 200 300", "invalid font-weight"},
+		{"font-family: a; src: url(x); font-stretch: -5%", "invalid font-stretch"},
+		{"font-family: a; src: url(x); font-style: oblique 100deg", "invalid font-style"},
+		{"font-family: a; src: url(x); font-style: oblique auto", "invalid font-style"},
+		{"font-family: a; src: url(x); font-style: italic 10deg", "invalid font-style"},
+		{"font-family: a; src: url(x); font-display: later", "invalid font-display"},
+		{"font-family: a; src: url(x); descent-override: 10px", "invalid descent-override"},
+		{"font-family: a; src: url(x); size-adjust: normal", "invalid size-adjust"},
+		{"font-family: a", "@font-face without src"},
+		{"src: url(x)", "@font-face without font-family"},
+	} {
+		_, diagnostics := ParseFontFace(test.input)
+		if len(diagnostics) == 0 || diagnostics[0].Message != test.expected || diagnostics[0].Code != CodeInvalidDescriptor {
+			t.Errorf("For %q: expected %q, got %v", test.input, test.expected, diagnostics)
+		}
+	}
+}
+
+func TestRuleFontFace(t *testing.T) {
+	sheet, _ := ParseStylesheet("@font-face { font-family: a; src: url(a.woff) } @font-face { font-weight: bold }")
+	face, diagnostics := sheet.Rules[0].FontFace()
+	if len(diagnostics) > 0 || face.Family != "a" || face.Sources[0].URL != "a.woff" {
+		t.Errorf("Unexpected font face %+v %v", face, diagnostics)
+	}
+	if face.Offset != 0 || face.EndOffset != 47 {
+		t.Errorf("Unexpected span %d-%d", face.Offset, face.EndOffset)
+	}
+	_, diagnostics = sheet.Rules[1].FontFace()
+	if len(diagnostics) != 1 || diagnostics[0].String() != "line 1, column 49: error: @font-face without font-family" {
+		t.Errorf("Unexpected diagnostics %v", diagnostics)
+	}
+
+	sheet, _ = ParseStylesheet("@media print { a { b: c } } a { font-family: x; src: url(y) }")
+	for _, r := range sheet.Rules {
+		face, diagnostics := r.FontFace()
+		if face != nil || len(diagnostics) != 1 || diagnostics[0].Code != CodeInvalidRule {
+			t.Errorf("For %q: expected not an @font-face rule, got %+v %v", r.Prelude, face, diagnostics)
+		}
+	}
+}
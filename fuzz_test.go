@@ -34,6 +34,21 @@ func FuzzScanner(f *testing.F) {
 			return
 		}
 
+		// Phase 0: Syntax3 and Recover mode recover from every error.
+		for _, mode := range []Mode{Syntax3, Recover} {
+			s := New(input)
+			s.Mode = mode
+			for {
+				tok := s.Next()
+				if tok.Type == Error {
+					t.Fatalf("Error token in mode %d for %q: %v", mode, input, tok)
+				}
+				if tok.Type == EOF {
+					break
+				}
+			}
+		}
+
 		// Phase 1: tokenize (must not crash or panic).
 		tokens, hasError := fuzzParse(input)
 		if hasError {
@@ -101,3 +116,31 @@ func fuzzParse(input string) ([]Token, bool) {
 		tokens = append(tokens, *tok)
 	}
 }
+
+// FuzzParseStylesheet tests that the parser does not crash or panic on any
+// input and that the spans of the rules lie within the input.
+func FuzzParseStylesheet(f *testing.F) {
+	f.Add(`a, b { color: red; margin: 0 auto !important }`)
+	f.Add(`@media print { p { x: y } } @import url(x.css);`)
+	f.Add(`.card { color: red; &:hover { color: blue } z: w }`)
+	f.Add(`a { --x: {c} d; b: {c} d; 'e`)
+	f.Add(`<!-- a {} --> ) ] } {`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		sheet, _ := ParseStylesheet(input)
+		var check func(rules []*Rule)
+		check = func(rules []*Rule) {
+			for _, r := range rules {
+				if r.Offset < 0 || r.Offset > r.EndOffset || r.EndOffset > len(input) {
+					t.Fatalf("Rule span %+v out of range for %q", r.Span, input)
+				}
+				check(r.Rules)
+			}
+		}
+		check(sheet.Rules)
+		var buf bytes.Buffer
+		if err := sheet.Emit(&buf); err != nil {
+			t.Fatalf("Emit failed for %q: %v", input, err)
+		}
+	})
+}
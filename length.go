@@ -0,0 +1,380 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Values and Units Level 4: dimensions
+// --------------------------------------------------------------------
+
+// Unit is the unit of a dimension.
+type Unit int
+
+// The units of lengths.
+const (
+	// Absolute lengths.
+	UnitPx Unit = iota
+	UnitPt
+	UnitPc
+	UnitIn
+	UnitCm
+	UnitMm
+	UnitQ
+
+	// Font-relative lengths.
+	UnitEm
+	UnitRem
+	UnitEx
+	UnitCh
+	UnitCap
+	UnitIc
+	UnitLh
+	UnitRlh
+
+	// Viewport-percentage lengths. The small (sv), large (lv) and dynamic
+	// (dv) variants are all resolved against the viewport of the
+	// LengthContext.
+	UnitVw
+	UnitVh
+	UnitVi
+	UnitVb
+	UnitVmin
+	UnitVmax
+	UnitSvw
+	UnitSvh
+	UnitSvi
+	UnitSvb
+	UnitSvmin
+	UnitSvmax
+	UnitLvw
+	UnitLvh
+	UnitLvi
+	UnitLvb
+	UnitLvmin
+	UnitLvmax
+	UnitDvw
+	UnitDvh
+	UnitDvi
+	UnitDvb
+	UnitDvmin
+	UnitDvmax
+
+	// Container query lengths.
+	UnitCqw
+	UnitCqh
+	UnitCqi
+	UnitCqb
+	UnitCqmin
+	UnitCqmax
+)
+
+// The units of angles, times, frequencies and resolutions.
+const (
+	UnitDeg Unit = iota + UnitCqmax + 1
+	UnitGrad
+	UnitRad
+	UnitTurn
+	UnitS
+	UnitMs
+	UnitHz
+	UnitKHz
+	UnitDpi
+	UnitDpcm
+	UnitDppx
+	UnitX
+)
+
+// unitInfo describes the units.
+var unitInfo = [...]struct {
+	name string
+	typ  CalcType
+	// canonical is the value of the unit in the canonical unit of its
+	// type, or 0 for relative lengths.
+	canonical float64
+}{
+	UnitPx:    {"px", CalcLength, 1},
+	UnitPt:    {"pt", CalcLength, 96.0 / 72},
+	UnitPc:    {"pc", CalcLength, 16},
+	UnitIn:    {"in", CalcLength, 96},
+	UnitCm:    {"cm", CalcLength, 96 / 2.54},
+	UnitMm:    {"mm", CalcLength, 96 / 25.4},
+	UnitQ:     {"Q", CalcLength, 96 / 101.6},
+	UnitEm:    {"em", CalcLength, 0},
+	UnitRem:   {"rem", CalcLength, 0},
+	UnitEx:    {"ex", CalcLength, 0},
+	UnitCh:    {"ch", CalcLength, 0},
+	UnitCap:   {"cap", CalcLength, 0},
+	UnitIc:    {"ic", CalcLength, 0},
+	UnitLh:    {"lh", CalcLength, 0},
+	UnitRlh:   {"rlh", CalcLength, 0},
+	UnitVw:    {"vw", CalcLength, 0},
+	UnitVh:    {"vh", CalcLength, 0},
+	UnitVi:    {"vi", CalcLength, 0},
+	UnitVb:    {"vb", CalcLength, 0},
+	UnitVmin:  {"vmin", CalcLength, 0},
+	UnitVmax:  {"vmax", CalcLength, 0},
+	UnitSvw:   {"svw", CalcLength, 0},
+	UnitSvh:   {"svh", CalcLength, 0},
+	UnitSvi:   {"svi", CalcLength, 0},
+	UnitSvb:   {"svb", CalcLength, 0},
+	UnitSvmin: {"svmin", CalcLength, 0},
+	UnitSvmax: {"svmax", CalcLength, 0},
+	UnitLvw:   {"lvw", CalcLength, 0},
+	UnitLvh:   {"lvh", CalcLength, 0},
+	UnitLvi:   {"lvi", CalcLength, 0},
+	UnitLvb:   {"lvb", CalcLength, 0},
+	UnitLvmin: {"lvmin", CalcLength, 0},
+	UnitLvmax: {"lvmax", CalcLength, 0},
+	UnitDvw:   {"dvw", CalcLength, 0},
+	UnitDvh:   {"dvh", CalcLength, 0},
+	UnitDvi:   {"dvi", CalcLength, 0},
+	UnitDvb:   {"dvb", CalcLength, 0},
+	UnitDvmin: {"dvmin", CalcLength, 0},
+	UnitDvmax: {"dvmax", CalcLength, 0},
+	UnitCqw:   {"cqw", CalcLength, 0},
+	UnitCqh:   {"cqh", CalcLength, 0},
+	UnitCqi:   {"cqi", CalcLength, 0},
+	UnitCqb:   {"cqb", CalcLength, 0},
+	UnitCqmin: {"cqmin", CalcLength, 0},
+	UnitCqmax: {"cqmax", CalcLength, 0},
+	UnitDeg:   {"deg", CalcAngle, 1},
+	UnitGrad:  {"grad", CalcAngle, 0.9},
+	UnitRad:   {"rad", CalcAngle, 180 / math.Pi},
+	UnitTurn:  {"turn", CalcAngle, 360},
+	UnitS:     {"s", CalcTime, 1},
+	UnitMs:    {"ms", CalcTime, 0.001},
+	UnitHz:    {"Hz", CalcFrequency, 1},
+	UnitKHz:   {"kHz", CalcFrequency, 1000},
+	UnitDpi:   {"dpi", CalcResolution, 1.0 / 96},
+	UnitDpcm:  {"dpcm", CalcResolution, 2.54 / 96},
+	UnitDppx:  {"dppx", CalcResolution, 1},
+	UnitX:     {"x", CalcResolution, 1},
+}
+
+// unitNames maps the lowercased unit names to the units.
+var unitNames = map[string]Unit{}
+
+func init() {
+	for u, info := range unitInfo {
+		unitNames[strings.ToLower(info.name)] = Unit(u)
+	}
+}
+
+// ParseUnit returns the unit with the case-insensitive name.
+func ParseUnit(name string) (Unit, bool) {
+	u, ok := unitNames[strings.ToLower(name)]
+	return u, ok
+}
+
+// known reports whether u is one of the Unit constants.
+func (u Unit) known() bool {
+	return u >= 0 && int(u) < len(unitInfo)
+}
+
+// String returns the name of the unit, such as "px" or "kHz".
+func (u Unit) String() string {
+	if !u.known() {
+		return fmt.Sprintf("Unit(%d)", int(u))
+	}
+	return unitInfo[u].name
+}
+
+// Type returns whether the unit is a length, angle, time, frequency or
+// resolution unit. It returns -1 for an unknown unit.
+func (u Unit) Type() CalcType {
+	if !u.known() {
+		return -1
+	}
+	return unitInfo[u].typ
+}
+
+// Absolute reports whether the unit does not depend on a LengthContext.
+// This is true for all units except the relative lengths.
+func (u Unit) Absolute() bool {
+	return u.known() && unitInfo[u].canonical != 0
+}
+
+// Length is a dimension: a number with a unit such as 12pt or 2em. Besides
+// lengths it holds angles, times, frequencies and resolutions, which
+// Unit.Type tells apart.
+type Length struct {
+	Value float64
+	Unit  Unit
+}
+
+// String returns the length in CSS syntax, such as 12pt.
+func (l Length) String() string {
+	return formatNumber(l.Value) + l.Unit.String()
+}
+
+// Canonical returns the value of l in the canonical unit of its type: px,
+// deg, s, Hz or dppx. It returns false for relative lengths.
+func (l Length) Canonical() (CalcValue, bool) {
+	if !l.Unit.Absolute() {
+		return CalcValue{}, false
+	}
+	info := unitInfo[l.Unit]
+	return CalcValue{l.Value * info.canonical, info.typ}, true
+}
+
+// ParseLength parses a dimension such as 12pt, 2em or 90deg. A unitless
+// zero is accepted as 0px.
+func ParseLength(input string) (Length, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return Length{}, &diagnostics[i]
+		}
+	}
+	return ParseLengthValues(list)
+}
+
+// ParseLengthValues parses a dimension from component values such as the
+// value of a declaration.
+func ParseLengthValues(list []ComponentValue) (Length, error) {
+	values := significant(list)
+	switch {
+	case len(values) == 0:
+		return Length{}, syntaxError(Span{}, CodeInvalidLength, "missing length")
+	case len(values) > 1:
+		return Length{}, syntaxError(values[1].Span(), CodeInvalidLength, "unexpected "+describe(values[1])+" after length")
+	}
+	c := values[0]
+	switch {
+	case isToken(c, Number) && c.Token.Num == 0:
+		return Length{0, UnitPx}, nil
+	case isToken(c, Dimension):
+		u, ok := ParseUnit(c.Token.LowerUnit())
+		if !ok {
+			return Length{}, syntaxError(c.Span(), CodeInvalidLength, "unknown unit "+c.Token.Unit)
+		}
+		return Length{c.Token.Num, u}, nil
+	}
+	return Length{}, syntaxError(c.Span(), CodeInvalidLength, "expected length, found "+describe(c))
+}
+
+// LengthContext holds the sizes that relative lengths are resolved
+// against. All sizes are in points.
+type LengthContext struct {
+	// FontSize is the font size for em. If it is 0, the default of 12pt is
+	// used.
+	FontSize float64
+	// RootFontSize is the font size of the root element for rem. If it is
+	// 0, the default of 12pt is used.
+	RootFontSize float64
+	// XHeight, ChWidth, CapHeight and IcWidth are the font metrics for ex,
+	// ch, cap and ic. If they are 0, 0.5em, 0.5em, 0.7em and 1em are used.
+	XHeight, ChWidth, CapHeight, IcWidth float64
+	// LineHeight and RootLineHeight are the line heights for lh and rlh.
+	// If they are 0, 1.2em and 1.2rem are used.
+	LineHeight, RootLineHeight float64
+	// ViewportWidth and ViewportHeight are the size of the viewport or
+	// page area.
+	ViewportWidth, ViewportHeight float64
+	// ContainerWidth and ContainerHeight are the size of the query
+	// container. If they are 0, the viewport is used.
+	ContainerWidth, ContainerHeight float64
+	// Vertical is set for a vertical writing mode, in which the inline
+	// axis of vi and cqi is vertical.
+	Vertical bool
+}
+
+// absolutePoints are the sizes of the absolute length units in points.
+var absolutePoints = map[Unit]float64{
+	UnitPx: 0.75,
+	UnitPt: 1,
+	UnitPc: 12,
+	UnitIn: 72,
+	UnitCm: 72 / 2.54,
+	UnitMm: 72 / 25.4,
+	UnitQ:  72 / 101.6,
+}
+
+// orDefault returns v, or def if v is 0.
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// Points returns l in points. ctx is only needed for relative lengths.
+// An error is returned for units that are not lengths and for relative
+// lengths that ctx does not resolve. It is a *Diagnostic with the code
+// CodeInvalidLength and no position.
+func (l Length) Points(ctx *LengthContext) (float64, error) {
+	if !l.Unit.known() {
+		return 0, syntaxError(Span{}, CodeInvalidLength, "unknown unit "+l.Unit.String())
+	}
+	if l.Unit.Type() != CalcLength {
+		return 0, syntaxError(Span{}, CodeInvalidLength, l.String()+" is not a length")
+	}
+	if pt, ok := absolutePoints[l.Unit]; ok {
+		return l.Value * pt, nil
+	}
+	if ctx == nil {
+		return 0, syntaxError(Span{}, CodeInvalidLength, "can not convert "+l.String()+" to points without a context")
+	}
+	em := orDefault(ctx.FontSize, 12)
+	rem := orDefault(ctx.RootFontSize, 12)
+	switch l.Unit {
+	case UnitEm:
+		return l.Value * em, nil
+	case UnitRem:
+		return l.Value * rem, nil
+	case UnitEx:
+		return l.Value * orDefault(ctx.XHeight, em/2), nil
+	case UnitCh:
+		return l.Value * orDefault(ctx.ChWidth, em/2), nil
+	case UnitCap:
+		return l.Value * orDefault(ctx.CapHeight, em*0.7), nil
+	case UnitIc:
+		return l.Value * orDefault(ctx.IcWidth, em), nil
+	case UnitLh:
+		return l.Value * orDefault(ctx.LineHeight, em*1.2), nil
+	case UnitRlh:
+		return l.Value * orDefault(ctx.RootLineHeight, rem*1.2), nil
+	}
+	width, height := ctx.ViewportWidth, ctx.ViewportHeight
+	axes := int(l.Unit - UnitVw)
+	if l.Unit >= UnitCqw {
+		width, height = orDefault(ctx.ContainerWidth, width), orDefault(ctx.ContainerHeight, height)
+		axes = int(l.Unit - UnitCqw)
+	}
+	if width == 0 || height == 0 {
+		return 0, syntaxError(Span{}, CodeInvalidLength, "can not convert "+l.String()+" to points without the viewport size")
+	}
+	inline, block := width, height
+	if ctx.Vertical {
+		inline, block = height, width
+	}
+	// The viewport and container units come in groups of six.
+	size := [6]float64{width, height, inline, block, min(width, height), max(width, height)}[axes%6]
+	return l.Value * size / 100, nil
+}
+
+// CalcContext returns a context for EvalCalc that resolves the relative
+// lengths with ctx. They are converted to px like the absolute lengths.
+func (ctx *LengthContext) CalcContext() *CalcContext {
+	return &CalcContext{
+		Unit: func(v float64, unit string) (CalcValue, bool) {
+			u, ok := ParseUnit(unit)
+			if !ok {
+				return CalcValue{}, false
+			}
+			pt, err := Length{v, u}.Points(ctx)
+			if err != nil {
+				return CalcValue{}, false
+			}
+			return CalcValue{pt / 0.75, CalcLength}, true
+		},
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseLength(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		value float64
+		unit  Unit
+		typ   CalcType
+	}{
+		{"12pt", 12, UnitPt, CalcLength},
+		{"0", 0, UnitPx, CalcLength},
+		{" 1.5EM ", 1.5, UnitEm, CalcLength},
+		{"4q", 4, UnitQ, CalcLength},
+		{"50svmin", 50, UnitSvmin, CalcLength},
+		{"10cqi", 10, UnitCqi, CalcLength},
+		{"90deg", 90, UnitDeg, CalcAngle},
+		{"200ms", 200, UnitMs, CalcTime},
+		{"1.2khz", 1.2, UnitKHz, CalcFrequency},
+		{"2x", 2, UnitX, CalcResolution},
+	} {
+		l, err := ParseLength(test.input)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if l.Value != test.value || l.Unit != test.unit || l.Unit.Type() != test.typ {
+			t.Errorf("For %q: expected %v%v (%v), got %v (%v)", test.input, test.value, test.unit, test.typ, l, l.Unit.Type())
+		}
+	}
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"", "line 0, column 0: error: missing length"},
+		{"1px 2px", "line 1, column 5: error: unexpected dimension after length"},
+		{"12", "line 1, column 1: error: expected length, found number"},
+		{"12foo", "line 1, column 1: error: unknown unit foo"},
+		{"50%", "line 1, column 1: error: expected length, found percentage"},
+	} {
+		_, err := ParseLength(test.input)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeInvalidLength {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+	for u := range unitInfo {
+		if got, ok := ParseUnit(Unit(u).String()); !ok || got != Unit(u) {
+			t.Errorf("Unit %v does not round trip", Unit(u))
+		}
+	}
+}
+
+func TestLengthPoints(t *testing.T) {
+	ctx := &LengthContext{
+		FontSize:       10,
+		XHeight:        4,
+		ViewportWidth:  600,
+		ViewportHeight: 800,
+		ContainerWidth: 200,
+	}
+	vertical := *ctx
+	vertical.Vertical = true
+	for _, test := range []struct {
+		input    string
+		ctx      *LengthContext
+		expected float64
+	}{
+		{"12pt", nil, 12},
+		{"16px", nil, 12},
+		{"1in", nil, 72},
+		{"2.54cm", nil, 72},
+		{"25.4mm", nil, 72},
+		{"101.6Q", nil, 72},
+		{"1pc", nil, 12},
+		{"2em", ctx, 20},
+		{"2rem", ctx, 24},
+		{"2ex", ctx, 8},
+		{"2ch", ctx, 10},
+		{"1cap", ctx, 7},
+		{"1ic", ctx, 10},
+		{"1lh", ctx, 12},
+		{"1rlh", ctx, 14.4},
+		{"10vw", ctx, 60},
+		{"10dvh", ctx, 80},
+		{"10vi", ctx, 60},
+		{"10vi", &vertical, 80},
+		{"10lvb", &vertical, 60},
+		{"10svmin", ctx, 60},
+		{"10vmax", ctx, 80},
+		{"10cqw", ctx, 20},
+		{"10cqh", ctx, 80},
+		{"10cqmin", ctx, 20},
+		{"10cqb", &vertical, 20},
+	} {
+		l, err := ParseLength(test.input)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.input, err)
+		}
+		got, err := l.Points(test.ctx)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if math.Abs(got-test.expected) > 1e-9 {
+			t.Errorf("For %q: expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+	for _, test := range []struct {
+		length   Length
+		ctx      *LengthContext
+		expected string
+	}{
+		{Length{90, UnitDeg}, ctx, "90deg is not a length"},
+		{Length{1, UnitEm}, nil, "can not convert 1em to points without a context"},
+		{Length{1, UnitVw}, &LengthContext{}, "can not convert 1vw to points without the viewport size"},
+		{Length{1, Unit(999)}, ctx, "unknown unit Unit(999)"},
+		{Length{1, Unit(-1)}, ctx, "unknown unit Unit(-1)"},
+	} {
+		_, err := test.length.Points(test.ctx)
+		d, ok := err.(*Diagnostic)
+		if !ok || d.Message != test.expected || d.Code != CodeInvalidLength {
+			t.Errorf("For %v: expected error %q, got %v", test.length, test.expected, err)
+		}
+	}
+}
+
+func TestLengthCanonical(t *testing.T) {
+	for _, test := range []struct {
+		length   Length
+		expected string
+	}{
+		{Length{12, UnitPt}, "16px"},
+		{Length{0.5, UnitTurn}, "180deg"},
+		{Length{250, UnitMs}, "0.25s"},
+		{Length{2, UnitKHz}, "2000hz"},
+		{Length{96, UnitDpi}, "1dppx"},
+	} {
+		v, ok := test.length.Canonical()
+		if !ok || v.String() != test.expected {
+			t.Errorf("For %v: expected %q, got %v", test.length, test.expected, v)
+		}
+	}
+	if _, ok := (Length{1, UnitEm}).Canonical(); ok {
+		t.Error("em should not have a canonical value")
+	}
+	if UnitEm.Absolute() || !UnitCm.Absolute() || !UnitDeg.Absolute() {
+		t.Error("Unexpected Absolute result")
+	}
+
+	ctx := &LengthContext{FontSize: 9}
+	v, err := EvalCalc("max(1em, 10px)", ctx.CalcContext())
+	if err != nil || v.String() != "12px" {
+		t.Errorf("Expected 12px, got %v (%v)", v, err)
+	}
+	v, err = EvalCalc("calc(1in - 2em)", ctx.CalcContext())
+	if err != nil || math.Abs(v.Value-72) > 1e-9 {
+		t.Errorf("Expected 72px, got %v (%v)", v, err)
+	}
+	if _, err = EvalCalc("calc(1vw)", ctx.CalcContext()); err == nil {
+		t.Error("vw without a viewport should not be resolved")
+	}
+}
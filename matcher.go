@@ -0,0 +1,500 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "strings"
+
+// Element is the view of a document element that a Matcher needs. The
+// values returned by Parent, PreviousSibling and Children are compared
+// with ==, so implementations are usually pointers.
+type Element interface {
+	// LocalName returns the element name without a prefix.
+	LocalName() string
+	// Namespace returns the namespace URI of the element, or "" if it is
+	// in no namespace.
+	Namespace() string
+	// Attributes returns the attributes of the element.
+	Attributes() []Attribute
+	// Parent returns the parent element, or nil for the root element.
+	Parent() Element
+	// PreviousSibling returns the previous sibling element, or nil.
+	PreviousSibling() Element
+	// Children returns the child elements. Text is not visible to the
+	// matcher, so :empty matches an element without child elements.
+	Children() []Element
+	// State returns the user interface state of the element.
+	State() ElementState
+}
+
+// Attribute is an attribute of an Element. Namespace is the namespace URI
+// of the attribute, or "" for an attribute in no namespace.
+type Attribute struct {
+	Namespace string
+	Name      string
+	Value     string
+}
+
+// ElementState is a set of flags for the pseudo-classes that depend on the
+// state of an element rather than on the document tree.
+type ElementState uint32
+
+const (
+	StateHover            ElementState = 1 << iota // :hover
+	StateActive                                    // :active
+	StateFocus                                     // :focus, :focus-within
+	StateFocusVisible                              // :focus-visible
+	StateLink                                      // :link, :any-link
+	StateVisited                                   // :visited, :any-link
+	StateTarget                                    // :target
+	StateChecked                                   // :checked
+	StateIndeterminate                             // :indeterminate
+	StateDefault                                   // :default
+	StateDisabled                                  // :disabled
+	StateEnabled                                   // :enabled
+	StateRequired                                  // :required
+	StateOptional                                  // :optional
+	StateReadWrite                                 // :read-write, not :read-only
+	StateValid                                     // :valid
+	StateInvalid                                   // :invalid
+	StatePlaceholderShown                          // :placeholder-shown
+	StateOpen                                      // :open
+)
+
+// statePseudoClasses maps the pseudo-classes to the state flags of which
+// one must be set.
+var statePseudoClasses = map[string]ElementState{
+	"hover":             StateHover,
+	"active":            StateActive,
+	"focus":             StateFocus,
+	"focus-visible":     StateFocusVisible,
+	"link":              StateLink,
+	"visited":           StateVisited,
+	"any-link":          StateLink | StateVisited,
+	"target":            StateTarget,
+	"checked":           StateChecked,
+	"indeterminate":     StateIndeterminate,
+	"default":           StateDefault,
+	"disabled":          StateDisabled,
+	"enabled":           StateEnabled,
+	"required":          StateRequired,
+	"optional":          StateOptional,
+	"read-write":        StateReadWrite,
+	"valid":             StateValid,
+	"invalid":           StateInvalid,
+	"placeholder-shown": StatePlaceholderShown,
+	"open":              StateOpen,
+}
+
+// Matcher matches parsed selectors against elements. The zero value
+// matches without namespaces and with case-sensitive names.
+type Matcher struct {
+	// Namespaces maps the namespace prefixes used in selectors to
+	// namespace URIs, as declared with @namespace. Selectors with an
+	// undeclared prefix match nothing.
+	Namespaces map[string]string
+	// DefaultNamespace is the URI that type and universal selectors
+	// without a prefix require, or "" to match elements in any namespace.
+	DefaultNamespace string
+	// Scope is the element matched by :scope and &. If it is nil, they
+	// match the root element.
+	Scope Element
+	// HTML enables the rules of HTML documents: element and attribute
+	// names are matched case-insensitively.
+	HTML bool
+}
+
+// Match reports whether e matches any selector in list.
+func (m *Matcher) Match(list SelectorList, e Element) bool {
+	for _, sel := range list {
+		if m.MatchSelector(sel, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSelector reports whether e matches the complex selector sel. A
+// selector with a pseudo-element never matches an element.
+func (m *Matcher) MatchSelector(sel *ComplexSelector, e Element) bool {
+	if len(sel.Compounds) == 0 {
+		return false
+	}
+	return m.matchFrom(sel, len(sel.Compounds)-1, e, nil)
+}
+
+// Select returns the descendants of root that match list in document
+// order.
+func (m *Matcher) Select(list SelectorList, root Element) []Element {
+	var res []Element
+	var walk func(Element)
+	walk = func(e Element) {
+		for _, c := range e.Children() {
+			if m.Match(list, c) {
+				res = append(res, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return res
+}
+
+// matchFrom matches e against the compound selectors of sel up to index
+// i, from right to left. anchor is the element of :has() that a relative
+// selector is relative to, nil otherwise.
+func (m *Matcher) matchFrom(sel *ComplexSelector, i int, e, anchor Element) bool {
+	compound := sel.Compounds[i]
+	if !m.matchCompound(compound, e) {
+		return false
+	}
+	if i == 0 {
+		return anchor == nil || related(compound.Combinator, anchor, e)
+	}
+	switch compound.Combinator {
+	case Child:
+		p := e.Parent()
+		return p != nil && m.matchFrom(sel, i-1, p, anchor)
+	case NextSibling:
+		p := e.PreviousSibling()
+		return p != nil && m.matchFrom(sel, i-1, p, anchor)
+	case SubsequentSibling:
+		for p := e.PreviousSibling(); p != nil; p = p.PreviousSibling() {
+			if m.matchFrom(sel, i-1, p, anchor) {
+				return true
+			}
+		}
+	default:
+		for p := e.Parent(); p != nil; p = p.Parent() {
+			if m.matchFrom(sel, i-1, p, anchor) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// related reports whether left and right are in the relation given by
+// the combinator c.
+func related(c Combinator, left, right Element) bool {
+	switch c {
+	case Child:
+		return right.Parent() == left
+	case NextSibling:
+		return right.PreviousSibling() == left
+	case SubsequentSibling:
+		for p := right.PreviousSibling(); p != nil; p = p.PreviousSibling() {
+			if p == left {
+				return true
+			}
+		}
+	default:
+		for p := right.Parent(); p != nil; p = p.Parent() {
+			if p == left {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchCompound reports whether e matches all simple selectors of c.
+func (m *Matcher) matchCompound(c *CompoundSelector, e Element) bool {
+	for _, s := range c.Selectors {
+		if !m.matchSimple(s, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSimple reports whether e matches the simple selector s.
+func (m *Matcher) matchSimple(s *SimpleSelector, e Element) bool {
+	switch s.Kind {
+	case TypeSelector:
+		return m.matchNamespace(s, e) && m.equalName(s.Name, e.LocalName())
+	case UniversalSelector:
+		return m.matchNamespace(s, e)
+	case IDSelector:
+		v, ok := m.attribute(e, "", "id")
+		return ok && v == s.Name
+	case ClassSelector:
+		v, ok := m.attribute(e, "", "class")
+		return ok && containsWord(v, s.Name)
+	case AttributeSelector:
+		return m.matchAttribute(s, e)
+	case PseudoClassSelector:
+		return m.matchPseudoClass(s, e)
+	case NestingSelector:
+		return m.isScope(e)
+	}
+	return false
+}
+
+// matchNamespace checks the namespace of a type or universal selector.
+func (m *Matcher) matchNamespace(s *SimpleSelector, e Element) bool {
+	if !s.HasNamespace {
+		return m.DefaultNamespace == "" || e.Namespace() == m.DefaultNamespace
+	}
+	switch s.Namespace {
+	case "*":
+		return true
+	case "":
+		return e.Namespace() == ""
+	}
+	uri, ok := m.Namespaces[s.Namespace]
+	return ok && e.Namespace() == uri
+}
+
+// equalName compares an element or attribute name.
+func (m *Matcher) equalName(a, b string) bool {
+	if m.HTML {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// attribute returns the value of the attribute name in the namespace ns,
+// where "*" stands for any namespace.
+func (m *Matcher) attribute(e Element, ns, name string) (string, bool) {
+	for _, a := range e.Attributes() {
+		if (ns == "*" || a.Namespace == ns) && m.equalName(name, a.Name) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// matchAttribute matches an attribute selector.
+func (m *Matcher) matchAttribute(s *SimpleSelector, e Element) bool {
+	ns := ""
+	if s.HasNamespace && s.Namespace != "" {
+		ns = s.Namespace
+		if ns != "*" {
+			var ok bool
+			if ns, ok = m.Namespaces[ns]; !ok {
+				return false
+			}
+		}
+	}
+	v, ok := m.attribute(e, ns, s.Name)
+	if !ok {
+		return false
+	}
+	want := s.Value
+	fold := s.Modifier == "i"
+	if fold {
+		v, want = strings.ToLower(v), strings.ToLower(want)
+	}
+	switch s.Operator {
+	case "":
+		return true
+	case "=":
+		return v == want
+	case "~=":
+		return containsWord(v, want)
+	case "|=":
+		return v == want || strings.HasPrefix(v, want+"-")
+	case "^=":
+		return want != "" && strings.HasPrefix(v, want)
+	case "$=":
+		return want != "" && strings.HasSuffix(v, want)
+	case "*=":
+		return want != "" && strings.Contains(v, want)
+	}
+	return false
+}
+
+// containsWord reports whether the whitespace separated list s contains
+// word.
+func containsWord(s, word string) bool {
+	if word == "" || strings.ContainsAny(word, " \t\n\r\f") {
+		return false
+	}
+	for _, w := range strings.Fields(s) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// isScope reports whether e is the scoping element.
+func (m *Matcher) isScope(e Element) bool {
+	if m.Scope != nil {
+		return e == m.Scope
+	}
+	return e.Parent() == nil
+}
+
+// matchPseudoClass matches a pseudo-class. Unknown pseudo-classes match
+// nothing.
+func (m *Matcher) matchPseudoClass(s *SimpleSelector, e Element) bool {
+	if flags, ok := statePseudoClasses[s.Name]; ok && !s.Function {
+		return e.State()&flags != 0
+	}
+	switch s.Name {
+	case "is", "where", "matches", "-webkit-any", "-moz-any":
+		return m.Match(s.Selectors, e)
+	case "not":
+		return !m.Match(s.Selectors, e)
+	case "has":
+		return m.matchHas(s.Selectors, e)
+	case "root":
+		return e.Parent() == nil
+	case "scope":
+		return m.isScope(e)
+	case "empty":
+		return len(e.Children()) == 0
+	case "read-only":
+		return e.State()&StateReadWrite == 0
+	case "focus-within":
+		return m.focusWithin(e)
+	case "lang":
+		return m.matchLang(s.Arguments, e)
+	case "first-child":
+		return m.position(e, nil, false, false) == 1
+	case "last-child":
+		return m.position(e, nil, false, true) == 1
+	case "only-child":
+		return m.position(e, nil, false, false) == 1 && m.position(e, nil, false, true) == 1
+	case "first-of-type":
+		return m.position(e, nil, true, false) == 1
+	case "last-of-type":
+		return m.position(e, nil, true, true) == 1
+	case "only-of-type":
+		return m.position(e, nil, true, false) == 1 && m.position(e, nil, true, true) == 1
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		if s.Selectors != nil && !m.Match(s.Selectors, e) {
+			return false
+		}
+		ofType := strings.HasSuffix(s.Name, "-of-type")
+		last := strings.HasPrefix(s.Name, "nth-last-")
+		return nthMatches(s.A, s.B, m.position(e, s.Selectors, ofType, last))
+	}
+	return false
+}
+
+// position returns the 1-based index of e among its siblings, counted
+// from the end if last is set. Only siblings that match of, or that have
+// the same type as e if ofType is set, are counted.
+func (m *Matcher) position(e Element, of SelectorList, ofType, last bool) int {
+	p := e.Parent()
+	if p == nil {
+		return 1
+	}
+	siblings := p.Children()
+	pos := 0
+	for i := range siblings {
+		sib := siblings[i]
+		if last {
+			sib = siblings[len(siblings)-1-i]
+		}
+		switch {
+		case ofType:
+			if !m.equalName(sib.LocalName(), e.LocalName()) || sib.Namespace() != e.Namespace() {
+				continue
+			}
+		case of != nil:
+			if !m.Match(of, sib) {
+				continue
+			}
+		}
+		pos++
+		if sib == e {
+			return pos
+		}
+	}
+	return 0
+}
+
+// nthMatches reports whether pos is An+B for some n >= 0.
+func nthMatches(a, b, pos int) bool {
+	if pos < 1 {
+		return false
+	}
+	if a == 0 {
+		return pos == b
+	}
+	d := pos - b
+	return d%a == 0 && d/a >= 0
+}
+
+// matchHas reports whether any element matches one of the relative
+// selectors in list anchored at e.
+func (m *Matcher) matchHas(list SelectorList, e Element) bool {
+	for _, sel := range list {
+		if len(sel.Compounds) == 0 {
+			continue
+		}
+		found := false
+		var walk func(Element)
+		walk = func(x Element) {
+			for _, c := range x.Children() {
+				if found {
+					return
+				}
+				if m.matchFrom(sel, len(sel.Compounds)-1, c, e) {
+					found = true
+					return
+				}
+				walk(c)
+			}
+		}
+		switch sel.Compounds[0].Combinator {
+		case NextSibling, SubsequentSibling:
+			// The subject is a following sibling or one of its
+			// descendants.
+			if p := e.Parent(); p != nil {
+				walk(p)
+			}
+		default:
+			walk(e)
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// focusWithin reports whether e or one of its descendants has the focus.
+func (m *Matcher) focusWithin(e Element) bool {
+	if e.State()&StateFocus != 0 {
+		return true
+	}
+	for _, c := range e.Children() {
+		if m.focusWithin(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLang matches :lang() with the language of the nearest lang
+// attribute.
+func (m *Matcher) matchLang(args []ComponentValue, e Element) bool {
+	var lang string
+	found := false
+	for x := e; x != nil && !found; x = x.Parent() {
+		lang, found = m.attribute(x, "", "lang")
+		if !found {
+			lang, found = m.attribute(x, "http://www.w3.org/XML/1998/namespace", "lang")
+		}
+	}
+	if !found {
+		return false
+	}
+	for i := range args {
+		if !isToken(&args[i], Ident) && !isToken(&args[i], String) {
+			continue
+		}
+		want := args[i].Token.Value
+		if strings.EqualFold(lang, want) || len(lang) > len(want) && strings.EqualFold(lang[:len(want)+1], want+"-") {
+			return true
+		}
+	}
+	return false
+}
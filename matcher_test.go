@@ -0,0 +1,273 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+// testElement is a minimal Element implementation.
+type testElement struct {
+	name      string
+	namespace string
+	attrs     []Attribute
+	state     ElementState
+	parent    *testElement
+	children  []*testElement
+}
+
+func (e *testElement) LocalName() string       { return e.name }
+func (e *testElement) Namespace() string       { return e.namespace }
+func (e *testElement) Attributes() []Attribute { return e.attrs }
+func (e *testElement) State() ElementState     { return e.state }
+
+func (e *testElement) Parent() Element {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+func (e *testElement) PreviousSibling() Element {
+	if e.parent == nil {
+		return nil
+	}
+	var prev Element
+	for _, c := range e.parent.children {
+		if c == e {
+			return prev
+		}
+		prev = c
+	}
+	return nil
+}
+
+func (e *testElement) Children() []Element {
+	res := make([]Element, len(e.children))
+	for i, c := range e.children {
+		res[i] = c
+	}
+	return res
+}
+
+// el returns an element with the attributes given as name=value pairs
+// separated by spaces.
+func el(name, attrs string, children ...*testElement) *testElement {
+	e := &testElement{name: name, children: children}
+	for _, a := range strings.Fields(attrs) {
+		k, v, _ := strings.Cut(a, "=")
+		e.attrs = append(e.attrs, Attribute{Name: k, Value: strings.ReplaceAll(v, "_", " ")})
+	}
+	for _, c := range children {
+		c.parent = e
+	}
+	return e
+}
+
+// ids returns the id attributes of elements.
+func ids(elements []Element) string {
+	var res []string
+	for _, e := range elements {
+		for _, a := range e.Attributes() {
+			if a.Name == "id" {
+				res = append(res, a.Value)
+			}
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+func testDocument() *testElement {
+	return el("html", "id=html lang=en-US",
+		el("body", "id=body",
+			el("div", "id=d1 class=card_big",
+				el("h1", "id=h1 title=Hello"),
+				el("p", "id=p1 class=intro"),
+				el("p", "id=p2"),
+				el("span", "id=s1"),
+				el("p", "id=p3 lang=de"),
+			),
+			el("div", "id=d2 data-x=en-GB",
+				el("a", "id=a1 href=https://example.com/x.pdf"),
+				el("input", "id=i1 type=Text"),
+			),
+			el("ul", "id=ul",
+				el("li", "id=li1 class=x"),
+				el("li", "id=li2"),
+				el("li", "id=li3 class=x"),
+				el("li", "id=li4"),
+				el("li", "id=li5 class=x"),
+			),
+			el("section", "id=empty"),
+		),
+	)
+}
+
+func TestMatcherSelect(t *testing.T) {
+	doc := testDocument()
+	var m Matcher
+	for _, test := range []struct {
+		selector, expected string
+	}{
+		{"p", "p1 p2 p3"},
+		{"div p", "p1 p2 p3"},
+		{"body > p", ""},
+		{"h1 + p", "p1"},
+		{"h1 ~ p", "p1 p2 p3"},
+		{"span ~ *", "p3"},
+		{".card", "d1"},
+		{".big.card", "d1"},
+		{"#p2, #s1", "p2 s1"},
+		{"[title]", "h1"},
+		{"[title=hello]", ""},
+		{"[title=hello i]", "h1"},
+		{"[class~=big]", "d1"},
+		{"[data-x|=en]", "d2"},
+		{"[href^=https]", "a1"},
+		{"[href$='.pdf']", "a1"},
+		{"[href*=example]", "a1"},
+		{"[href^='']", ""},
+		{"[type=text s]", ""},
+		{":root", "html"},
+		{":empty", "h1 p1 p2 s1 p3 a1 i1 li1 li2 li3 li4 li5 empty"},
+		{"div > :first-child", "h1 a1"},
+		{"div > :last-child", "p3 i1"},
+		{"p:first-of-type", "p1"},
+		{"p:last-of-type", "p3"},
+		{"span:only-of-type", "s1"},
+		{"body > :only-child", ""},
+		{"li:nth-child(odd)", "li1 li3 li5"},
+		{"li:nth-child(2n)", "li2 li4"},
+		{"li:nth-child(-n+2)", "li1 li2"},
+		{"li:nth-child(3)", "li3"},
+		{"li:nth-last-child(2)", "li4"},
+		{"li:nth-child(2 of .x)", "li3"},
+		{"li:nth-last-child(-n+2 of .x)", "li3 li5"},
+		{"p:nth-of-type(2)", "p2"},
+		{"p:nth-last-of-type(1)", "p3"},
+		{"li:not(.x)", "li2 li4"},
+		{"li:not(:first-child, :last-child)", "li2 li3 li4"},
+		{":is(h1, span)", "h1 s1"},
+		{":where(ul) > .x", "li1 li3 li5"},
+		{"div:has(> span)", "d1"},
+		{"div:has(a[href])", "d2"},
+		{"div:has(+ div)", "d1"},
+		{"div:has(~ section)", "d1 d2"},
+		{"h1:has(+ p.intro)", "h1"},
+		{"body:has(> div > p)", "body"},
+		{":has(li:nth-child(5))", "html body ul"},
+		{"p:lang(de)", "p3"},
+		{"p:lang(en)", "p1 p2"},
+		{":scope > body", "body"},
+		{"p::before", ""},
+		{":hover", ""},
+	} {
+		list, err := ParseSelectorList(test.selector)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.selector, err)
+		}
+		all := append([]Element{doc}, m.Select(list, doc)...)
+		if !m.Match(list, doc) {
+			all = all[1:]
+		}
+		if got := ids(all); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.selector, test.expected, got)
+		}
+	}
+}
+
+// byID returns the element below e with the given id.
+func byID(e *testElement, id string) *testElement {
+	for _, a := range e.attrs {
+		if a.Name == "id" && a.Value == id {
+			return e
+		}
+	}
+	for _, c := range e.children {
+		if found := byID(c, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestMatcherState(t *testing.T) {
+	doc := testDocument()
+	byID(doc, "i1").state = StateFocus | StateChecked
+	var m Matcher
+	for _, test := range []struct {
+		selector, id string
+		expected     bool
+	}{
+		{"input:focus", "i1", true},
+		{"input:checked:focus", "i1", true},
+		{"input:hover", "i1", false},
+		{"input:read-only", "i1", true},
+		{"input:read-write", "i1", false},
+		{":focus-within", "d2", true},
+		{":focus-within", "d1", false},
+		{"body:has(:checked)", "body", true},
+	} {
+		list, err := ParseSelectorList(test.selector)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.selector, err)
+		}
+		if got := m.Match(list, byID(doc, test.id)); got != test.expected {
+			t.Errorf("For %q on #%s: expected %v, got %v", test.selector, test.id, test.expected, got)
+		}
+	}
+}
+
+func TestMatcherNamespaces(t *testing.T) {
+	const svgNS = "http://www.w3.org/2000/svg"
+	circle := el("circle", "id=c")
+	circle.namespace = svgNS
+	circle.attrs = append(circle.attrs, Attribute{Namespace: "http://www.w3.org/1999/xlink", Name: "href", Value: "#x"})
+	svg := el("svg", "id=svg", circle)
+	svg.namespace = svgNS
+	root := el("html", "id=html", svg)
+	m := Matcher{Namespaces: map[string]string{
+		"svg":   svgNS,
+		"xlink": "http://www.w3.org/1999/xlink",
+	}}
+	for _, test := range []struct {
+		selector, expected string
+	}{
+		{"svg|*", "svg c"},
+		{"svg|circle", "c"},
+		{"|*", "html"},
+		{"*|circle", "c"},
+		{"foo|*", ""},
+		{"[xlink|href]", "c"},
+		{"[href]", ""},
+		{"[*|href='#x']", "c"},
+	} {
+		list, err := ParseSelectorList(test.selector)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.selector, err)
+		}
+		all := append([]Element{root}, m.Select(list, root)...)
+		if !m.Match(list, root) {
+			all = all[1:]
+		}
+		if got := ids(all); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.selector, test.expected, got)
+		}
+	}
+
+	m.DefaultNamespace = svgNS
+	list, _ := ParseSelectorList("circle, html")
+	if got := ids(m.Select(list, root)); got != "c" {
+		t.Errorf("Unexpected match with default namespace: %q", got)
+	}
+
+	m = Matcher{HTML: true}
+	list, _ = ParseSelectorList("CIRCLE[ID]")
+	if got := ids(m.Select(list, root)); got != "c" {
+		t.Errorf("Unexpected case-insensitive match: %q", got)
+	}
+}
@@ -0,0 +1,861 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// Media Queries Level 4
+//
+// A media query list is parsed into a tree of conditions on media
+// features. Evaluation uses three-valued logic: a feature that is unknown
+// or used with an invalid value is neither true nor false, and a query
+// that ends up unknown does not match.
+// --------------------------------------------------------------------
+
+// MediaQueryList is a comma separated list of media queries. It matches if
+// any of its queries matches; an empty list matches everything.
+type MediaQueryList []*MediaQuery
+
+// MediaQuery is a single media query such as "only screen and (color)".
+type MediaQuery struct {
+	Span
+	// Not and Only are set for the not and only keywords before the media
+	// type.
+	Not, Only bool
+	// Type is the lowercased media type, or "" for a query that is only a
+	// condition.
+	Type string
+	// Condition is the condition after the media type, or the whole query
+	// if Type is "". It is nil for a query that is only a media type.
+	Condition *MediaCondition
+}
+
+// MediaConditionKind identifies the kind of a MediaCondition.
+type MediaConditionKind int
+
+const (
+	// MediaFeatureTest is a test of a media feature such as (width > 5in).
+	MediaFeatureTest MediaConditionKind = iota
+	// MediaNot negates its single condition.
+	MediaNot
+	// MediaAnd matches if all of its conditions match.
+	MediaAnd
+	// MediaOr matches if any of its conditions matches.
+	MediaOr
+	// MediaGeneralEnclosed is a function or a () block that is not a
+	// media feature. It is reserved for future extensions and never
+	// matches.
+	MediaGeneralEnclosed
+)
+
+// MediaCondition is a node in the condition tree of a media query.
+type MediaCondition struct {
+	Span
+	Kind MediaConditionKind
+	// Feature is the feature of a MediaFeatureTest.
+	Feature *MediaFeature
+	// Conditions are the operands of MediaNot, MediaAnd and MediaOr.
+	Conditions []*MediaCondition
+	// Enclosed is the function or block of a MediaGeneralEnclosed.
+	Enclosed *ComponentValue
+}
+
+// MediaFeature is a media feature in parentheses. The feature
+// (min-width: 400px) has the name min-width and one comparison with the
+// operator ":", (400px <= width < 800px) has the name width and the
+// comparisons >= 400px and < 800px. A feature without comparisons is
+// evaluated in a boolean context.
+type MediaFeature struct {
+	Span
+	// Name is the lowercased name, including a min- or max- prefix.
+	Name        string
+	Comparisons []MediaComparison
+}
+
+// MediaComparison compares a media feature with a value. Op is ":" for the
+// plain syntax, or one of "<", "<=", "=", ">=" and ">", with the feature
+// on the left side.
+type MediaComparison struct {
+	Op    string
+	Value MediaValue
+}
+
+// MediaValue is the value of a media feature: a number, a dimension, a
+// ratio or an identifier.
+type MediaValue struct {
+	// Number is the number, or the numerator of a ratio.
+	Number float64
+	// Unit is the lowercased unit of a dimension.
+	Unit string
+	// Denominator is the denominator of a ratio and 0 otherwise.
+	Denominator float64
+	// Ident is the lowercased identifier of an identifier value.
+	Ident string
+}
+
+// MediaEnvironment describes the output device that media queries are
+// evaluated against. Lengths are in CSS pixels of 1/96 inch.
+type MediaEnvironment struct {
+	// Type is the media type, usually "print" or "screen".
+	Type string
+	// Width and Height are the size of the viewport or the page box. The
+	// orientation is portrait if Height is at least Width, and landscape
+	// otherwise.
+	Width, Height float64
+	// Resolution is the resolution in dots per CSS pixel (dppx). 0 means
+	// 1dppx, which is 96dpi.
+	Resolution float64
+	// Color is the number of bits per color component, 0 for a monochrome
+	// device. ColorIndex is the number of entries in the color lookup
+	// table and Monochrome the number of bits per pixel of a monochrome
+	// device.
+	Color, ColorIndex, Monochrome int
+	// Grid is set for a grid device such as a terminal.
+	Grid bool
+	// FontSize is the initial font size in pixels for em and rem values.
+	// 0 means 16.
+	FontSize float64
+	// Features holds the values of other discrete features, such as
+	// "hover": "none" or "prefers-color-scheme": "light". Features that
+	// are not listed are unknown.
+	Features map[string]string
+}
+
+// ParseMediaQueryList parses input as a media query list. A query that
+// can not be parsed is replaced by "not all", which never matches, and
+// reported as a diagnostic.
+func ParseMediaQueryList(input string) (MediaQueryList, []Diagnostic) {
+	list, diagnostics := ParseComponentValueList(input)
+	queries, errors := ParseMediaQueryValues(list)
+	return queries, append(diagnostics, errors...)
+}
+
+// ParseMediaQueryValues parses a media query list from component values
+// such as the prelude of an @media rule.
+func ParseMediaQueryValues(list []ComponentValue) (MediaQueryList, []Diagnostic) {
+	var res MediaQueryList
+	var diagnostics []Diagnostic
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !list[i].IsDelim(',') {
+			continue
+		}
+		item := trimWhitespace(list[start:i])
+		item = item[skipSpace(item, 0):]
+		start = i + 1
+		if len(item) == 0 {
+			if i == len(list) && len(res) == 0 {
+				// An empty list.
+				break
+			}
+			if i < len(list) {
+				diagnostics = append(diagnostics, *syntaxError(list[i].Span(), CodeInvalidMediaQuery, "empty media query before ','"))
+			} else {
+				diagnostics = append(diagnostics, *syntaxError(list[i-1].Span(), CodeInvalidMediaQuery, "empty media query after ','"))
+			}
+			res = append(res, &MediaQuery{Not: true, Type: "all"})
+			continue
+		}
+		q, err := parseMediaQuery(item)
+		if err != nil {
+			diagnostics = append(diagnostics, *err)
+			q = &MediaQuery{Not: true, Type: "all"}
+		}
+		q.Span = spanOf(item[0].Token, item[len(item)-1].lastToken())
+		res = append(res, q)
+	}
+	return res, diagnostics
+}
+
+// MediaQueries parses the prelude of an @media rule.
+func (r *Rule) MediaQueries() (MediaQueryList, []Diagnostic) {
+	return ParseMediaQueryValues(r.Prelude)
+}
+
+// isIdent reports whether c is the identifier name, ignoring case.
+func isIdent(c *ComponentValue, name string) bool {
+	return isToken(c, Ident) && strings.EqualFold(c.Token.Value, name)
+}
+
+// parseMediaQuery parses a single media query from list, which is not
+// empty and has no leading or trailing whitespace.
+func parseMediaQuery(list []ComponentValue) (*MediaQuery, *Diagnostic) {
+	q := &MediaQuery{}
+	c := &list[0]
+	if !isToken(c, Ident) {
+		cond, err := parseMediaCondition(list, true)
+		q.Condition = cond
+		return q, err
+	}
+	pos := 0
+	switch strings.ToLower(c.Token.Value) {
+	case "not":
+		next := skipSpace(list, 1)
+		if next < len(list) && !isToken(&list[next], Ident) {
+			// not (condition)
+			cond, err := parseMediaCondition(list, true)
+			q.Condition = cond
+			return q, err
+		}
+		q.Not = true
+		pos = next
+	case "only":
+		q.Only = true
+		pos = skipSpace(list, 1)
+	}
+	if pos == len(list) {
+		return nil, syntaxError(list[pos-1].Span(), CodeInvalidMediaQuery, "missing media type")
+	}
+	if !isToken(&list[pos], Ident) {
+		return nil, syntaxError(list[pos].Span(), CodeInvalidMediaQuery, "expected media type, found "+describe(&list[pos]))
+	}
+	c = &list[pos]
+	q.Type = strings.ToLower(c.Token.Value)
+	switch q.Type {
+	case "not", "only", "and", "or", "layer":
+		return nil, syntaxError(c.Span(), CodeInvalidMediaQuery, "invalid media type "+c.Token.Value)
+	}
+	pos = skipSpace(list, pos+1)
+	if pos == len(list) {
+		return q, nil
+	}
+	if !isIdent(&list[pos], "and") {
+		return nil, syntaxError(list[pos].Span(), CodeInvalidMediaQuery, "expected 'and' after media type, found "+describe(&list[pos]))
+	}
+	and := &list[pos]
+	pos = skipSpace(list, pos+1)
+	if pos == len(list) {
+		return nil, syntaxError(and.Span(), CodeInvalidMediaQuery, "missing condition after 'and'")
+	}
+	var err *Diagnostic
+	q.Condition, err = parseMediaCondition(list[pos:], false)
+	return q, err
+}
+
+// parseMediaCondition parses list as a media condition. The operands may
+// be joined with or if allowOr is set. list is not empty and has no
+// leading or trailing whitespace.
+func parseMediaCondition(list []ComponentValue, allowOr bool) (*MediaCondition, *Diagnostic) {
+	span := spanOf(list[0].Token, list[len(list)-1].lastToken())
+	if isIdent(&list[0], "not") {
+		pos := skipSpace(list, 1)
+		if pos == len(list) {
+			return nil, syntaxError(list[0].Span(), CodeInvalidMediaQuery, "missing condition after 'not'")
+		}
+		operand, err := parseMediaInParens(&list[pos])
+		if err != nil {
+			return nil, err
+		}
+		if next := skipSpace(list, pos+1); next < len(list) {
+			return nil, syntaxError(list[next].Span(), CodeInvalidMediaQuery, "unexpected "+describe(&list[next])+" after 'not' condition")
+		}
+		return &MediaCondition{Span: span, Kind: MediaNot, Conditions: []*MediaCondition{operand}}, nil
+	}
+	var operands []*MediaCondition
+	op := ""
+	pos := 0
+	for {
+		operand, err := parseMediaInParens(&list[pos])
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		pos = skipSpace(list, pos+1)
+		if pos == len(list) {
+			break
+		}
+		c := &list[pos]
+		word := ""
+		if isToken(c, Ident) {
+			word = strings.ToLower(c.Token.Value)
+		}
+		switch {
+		case word != "and" && word != "or":
+			return nil, syntaxError(c.Span(), CodeInvalidMediaQuery, "expected 'and' or 'or', found "+describe(c))
+		case word == "or" && !allowOr:
+			return nil, syntaxError(c.Span(), CodeInvalidMediaQuery, "'or' is not allowed after a media type")
+		case op != "" && word != op:
+			return nil, syntaxError(c.Span(), CodeInvalidMediaQuery, "'and' and 'or' can not be mixed without parentheses")
+		}
+		op = word
+		pos = skipSpace(list, pos+1)
+		if pos == len(list) {
+			return nil, syntaxError(c.Span(), CodeInvalidMediaQuery, "missing condition after '"+op+"'")
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	kind := MediaAnd
+	if op == "or" {
+		kind = MediaOr
+	}
+	return &MediaCondition{Span: span, Kind: kind, Conditions: operands}, nil
+}
+
+// parseMediaInParens parses a condition or media feature in parentheses,
+// or general enclosed content.
+func parseMediaInParens(c *ComponentValue) (*MediaCondition, *Diagnostic) {
+	general := &MediaCondition{Span: c.Span(), Kind: MediaGeneralEnclosed, Enclosed: c}
+	switch {
+	case c.Kind == FunctionBlock:
+		return general, nil
+	case !c.IsBlock('('):
+		return nil, syntaxError(c.Span(), CodeInvalidMediaQuery, "expected '(', found "+describe(c))
+	}
+	inner := trimWhitespace(c.Values)
+	inner = inner[skipSpace(inner, 0):]
+	if len(inner) == 0 {
+		return general, nil
+	}
+	if inner[0].IsBlock('(') || inner[0].Kind == FunctionBlock || isIdent(&inner[0], "not") {
+		if cond, err := parseMediaCondition(inner, true); err == nil && cond.Kind != MediaGeneralEnclosed {
+			cond.Span = c.Span()
+			return cond, nil
+		}
+	}
+	if f := parseMediaFeature(inner); f != nil {
+		f.Span = c.Span()
+		return &MediaCondition{Span: c.Span(), Kind: MediaFeatureTest, Feature: f}, nil
+	}
+	return general, nil
+}
+
+// parseMediaFeature parses the contents of a media feature, or returns nil
+// if list is not a valid media feature.
+func parseMediaFeature(list []ComponentValue) *MediaFeature {
+	if isToken(&list[0], Ident) {
+		f := &MediaFeature{Name: strings.ToLower(list[0].Token.Value)}
+		pos := skipSpace(list, 1)
+		if pos == len(list) {
+			// Boolean context.
+			if strings.HasPrefix(f.Name, "min-") || strings.HasPrefix(f.Name, "max-") {
+				return nil
+			}
+			return f
+		}
+		if list[pos].IsDelim(':') {
+			v, next, ok := parseMediaValue(list, skipSpace(list, pos+1))
+			if !ok || skipSpace(list, next) != len(list) {
+				return nil
+			}
+			f.Comparisons = []MediaComparison{{Op: ":", Value: v}}
+			return f
+		}
+		if strings.HasPrefix(f.Name, "min-") || strings.HasPrefix(f.Name, "max-") {
+			return nil
+		}
+		op, next := parseComparison(list, pos)
+		if op == "" {
+			return nil
+		}
+		v, next, ok := parseMediaValue(list, skipSpace(list, next))
+		if !ok || skipSpace(list, next) != len(list) {
+			return nil
+		}
+		f.Comparisons = []MediaComparison{{Op: op, Value: v}}
+		return f
+	}
+
+	// value op name [op value]
+	first, pos, ok := parseMediaValue(list, 0)
+	if !ok {
+		return nil
+	}
+	op1, pos := parseComparison(list, skipSpace(list, pos))
+	pos = skipSpace(list, pos)
+	if op1 == "" || pos == len(list) || !isToken(&list[pos], Ident) {
+		return nil
+	}
+	f := &MediaFeature{Name: strings.ToLower(list[pos].Token.Value)}
+	if strings.HasPrefix(f.Name, "min-") || strings.HasPrefix(f.Name, "max-") {
+		return nil
+	}
+	f.Comparisons = []MediaComparison{{Op: flipComparison(op1), Value: first}}
+	pos = skipSpace(list, pos+1)
+	if pos == len(list) {
+		return f
+	}
+	op2, pos := parseComparison(list, pos)
+	if op1 == "=" || op2 == "" || op1[0] != op2[0] {
+		// Both comparisons must point in the same direction.
+		return nil
+	}
+	second, pos, ok := parseMediaValue(list, skipSpace(list, pos))
+	if !ok || skipSpace(list, pos) != len(list) {
+		return nil
+	}
+	f.Comparisons = append(f.Comparisons, MediaComparison{Op: op2, Value: second})
+	return f
+}
+
+// parseComparison parses a comparison operator at pos and returns it with
+// the position after it, or "" if there is none. The = of <= and >= must
+// follow the < or > directly.
+func parseComparison(list []ComponentValue, pos int) (string, int) {
+	if pos == len(list) {
+		return "", pos
+	}
+	c := &list[pos]
+	switch {
+	case c.IsDelim('='):
+		return "=", pos + 1
+	case c.IsDelim('<'), c.IsDelim('>'):
+		op := c.Token.Value
+		if pos+1 < len(list) && list[pos+1].IsDelim('=') && list[pos+1].Token.Offset == c.Token.Offset+1 {
+			return op + "=", pos + 2
+		}
+		return op, pos + 1
+	}
+	return "", pos
+}
+
+// flipComparison returns the operator with the operands swapped.
+func flipComparison(op string) string {
+	switch op[0] {
+	case '<':
+		return ">" + op[1:]
+	case '>':
+		return "<" + op[1:]
+	}
+	return op
+}
+
+// parseMediaValue parses a number, dimension, ratio or identifier at pos.
+func parseMediaValue(list []ComponentValue, pos int) (MediaValue, int, bool) {
+	var v MediaValue
+	if pos == len(list) {
+		return v, pos, false
+	}
+	c := &list[pos]
+	switch {
+	case isToken(c, Ident):
+		v.Ident = strings.ToLower(c.Token.Value)
+		return v, pos + 1, true
+	case isToken(c, Dimension):
+		v.Number = c.Token.Num
+		v.Unit = c.Token.LowerUnit()
+		return v, pos + 1, true
+	case isToken(c, Number):
+		v.Number = c.Token.Num
+		// A ratio is a number, a slash and a number.
+		slash := skipSpace(list, pos+1)
+		if slash < len(list) && list[slash].IsDelim('/') {
+			den := skipSpace(list, slash+1)
+			if den == len(list) || !isToken(&list[den], Number) {
+				return v, pos, false
+			}
+			v.Denominator = list[den].Token.Num
+			return v, den + 1, true
+		}
+		return v, pos + 1, true
+	}
+	return v, pos, false
+}
+
+// String returns the CSS representation of the media query list.
+func (l MediaQueryList) String() string {
+	parts := make([]string, len(l))
+	for i, q := range l {
+		parts[i] = q.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// String returns the CSS representation of the media query.
+func (q *MediaQuery) String() string {
+	var parts []string
+	if q.Not {
+		parts = append(parts, "not")
+	}
+	if q.Only {
+		parts = append(parts, "only")
+	}
+	if q.Type != "" {
+		parts = append(parts, q.Type)
+		if q.Condition != nil {
+			parts = append(parts, "and")
+		}
+	}
+	if q.Condition != nil {
+		parts = append(parts, q.Condition.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// String returns the CSS representation of the condition.
+func (c *MediaCondition) String() string {
+	switch c.Kind {
+	case MediaFeatureTest:
+		return c.Feature.String()
+	case MediaNot:
+		return "not " + c.Conditions[0].parenthesized()
+	case MediaAnd, MediaOr:
+		op := " and "
+		if c.Kind == MediaOr {
+			op = " or "
+		}
+		parts := make([]string, len(c.Conditions))
+		for i, operand := range c.Conditions {
+			parts[i] = operand.parenthesized()
+		}
+		return strings.Join(parts, op)
+	}
+	var sb strings.Builder
+	_ = c.Enclosed.Emit(&sb)
+	return sb.String()
+}
+
+// parenthesized returns the condition as an operand of not, and or or.
+func (c *MediaCondition) parenthesized() string {
+	if c.Kind == MediaFeatureTest || c.Kind == MediaGeneralEnclosed {
+		return c.String()
+	}
+	return "(" + c.String() + ")"
+}
+
+// String returns the CSS representation of the media feature.
+func (f *MediaFeature) String() string {
+	switch len(f.Comparisons) {
+	case 0:
+		return "(" + f.Name + ")"
+	case 1:
+		cmp := f.Comparisons[0]
+		if cmp.Op == ":" {
+			return "(" + f.Name + ": " + cmp.Value.String() + ")"
+		}
+		return "(" + f.Name + " " + cmp.Op + " " + cmp.Value.String() + ")"
+	}
+	first, second := f.Comparisons[0], f.Comparisons[1]
+	return "(" + first.Value.String() + " " + flipComparison(first.Op) + " " + f.Name + " " + second.Op + " " + second.Value.String() + ")"
+}
+
+// String returns the CSS representation of the value.
+func (v MediaValue) String() string {
+	switch {
+	case v.Ident != "":
+		return v.Ident
+	case v.Denominator != 0:
+		return formatNumber(v.Number) + "/" + formatNumber(v.Denominator)
+	}
+	return formatNumber(v.Number) + v.Unit
+}
+
+// formatNumber returns the shortest representation of n.
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// --------------------------------------------------------------------
+// Evaluation
+// --------------------------------------------------------------------
+
+// kleene is a truth value of three-valued logic.
+type kleene int8
+
+const (
+	kUnknown kleene = iota
+	kFalse
+	kTrue
+)
+
+func kleeneOf(b bool) kleene {
+	if b {
+		return kTrue
+	}
+	return kFalse
+}
+
+func (k kleene) not() kleene {
+	switch k {
+	case kTrue:
+		return kFalse
+	case kFalse:
+		return kTrue
+	}
+	return kUnknown
+}
+
+// Match reports whether any query in l matches env. An empty list
+// matches.
+func (l MediaQueryList) Match(env *MediaEnvironment) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, q := range l {
+		if q.Match(env) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the query matches env. A query whose condition is
+// unknown, for example because of an unknown media feature, does not
+// match, even if it is negated with not.
+func (q *MediaQuery) Match(env *MediaEnvironment) bool {
+	res := kTrue
+	switch q.Type {
+	case "", "all":
+	default:
+		res = kleeneOf(q.Type == strings.ToLower(env.Type))
+	}
+	if q.Condition != nil && res == kTrue {
+		res = q.Condition.eval(env)
+	}
+	if q.Not {
+		res = res.not()
+	}
+	return res == kTrue
+}
+
+// Match reports whether the condition is true for env.
+func (c *MediaCondition) Match(env *MediaEnvironment) bool {
+	return c.eval(env) == kTrue
+}
+
+func (c *MediaCondition) eval(env *MediaEnvironment) kleene {
+	switch c.Kind {
+	case MediaFeatureTest:
+		return c.Feature.eval(env)
+	case MediaNot:
+		return c.Conditions[0].eval(env).not()
+	case MediaAnd:
+		res := kTrue
+		for _, operand := range c.Conditions {
+			switch operand.eval(env) {
+			case kFalse:
+				return kFalse
+			case kUnknown:
+				res = kUnknown
+			}
+		}
+		return res
+	case MediaOr:
+		res := kFalse
+		for _, operand := range c.Conditions {
+			switch operand.eval(env) {
+			case kTrue:
+				return kTrue
+			case kUnknown:
+				res = kUnknown
+			}
+		}
+		return res
+	}
+	return kUnknown
+}
+
+// Range features are compared as numbers, discrete features by their
+// identifier.
+const (
+	mediaLength = iota
+	mediaRatio
+	mediaResolution
+	mediaInteger
+	mediaDiscrete
+)
+
+// mediaFeatureTypes are the types of the known media features.
+var mediaFeatureTypes = map[string]int{
+	"width":               mediaLength,
+	"height":              mediaLength,
+	"device-width":        mediaLength,
+	"device-height":       mediaLength,
+	"aspect-ratio":        mediaRatio,
+	"device-aspect-ratio": mediaRatio,
+	"resolution":          mediaResolution,
+	"color":               mediaInteger,
+	"color-index":         mediaInteger,
+	"monochrome":          mediaInteger,
+	"grid":                mediaInteger,
+	"orientation":         mediaDiscrete,
+}
+
+// eval evaluates the feature. Unknown features and values that are not
+// valid for the feature are unknown.
+func (f *MediaFeature) eval(env *MediaEnvironment) kleene {
+	name := f.Name
+	prefix := ""
+	if strings.HasPrefix(name, "min-") || strings.HasPrefix(name, "max-") {
+		prefix, name = name[:3], name[4:]
+	}
+	typ, ok := mediaFeatureTypes[name]
+	if !ok {
+		value, ok := env.Features[name]
+		if !ok || prefix != "" {
+			return kUnknown
+		}
+		if len(f.Comparisons) == 0 {
+			return kleeneOf(value != "none" && value != "0")
+		}
+		cmp := f.Comparisons[0]
+		if cmp.Op != ":" || cmp.Value.Ident == "" {
+			return kUnknown
+		}
+		return kleeneOf(strings.EqualFold(value, cmp.Value.Ident))
+	}
+	if typ == mediaDiscrete {
+		orientation := "landscape"
+		if env.Height >= env.Width {
+			orientation = "portrait"
+		}
+		if len(f.Comparisons) == 0 {
+			return kTrue
+		}
+		cmp := f.Comparisons[0]
+		if prefix != "" || cmp.Op != ":" || cmp.Value.Ident != "portrait" && cmp.Value.Ident != "landscape" {
+			return kUnknown
+		}
+		return kleeneOf(cmp.Value.Ident == orientation)
+	}
+	actual := env.featureValue(name)
+	if len(f.Comparisons) == 0 {
+		return kleeneOf(actual != 0)
+	}
+	res := kTrue
+	for _, cmp := range f.Comparisons {
+		want, ok := env.mediaNumber(typ, cmp.Value)
+		if !ok {
+			return kUnknown
+		}
+		op := cmp.Op
+		if op == ":" {
+			switch prefix {
+			case "min":
+				op = ">="
+			case "max":
+				op = "<="
+			default:
+				op = "="
+			}
+		}
+		if !compareNumbers(actual, op, want) {
+			res = kFalse
+		}
+	}
+	return res
+}
+
+// featureValue returns the value of a numeric media feature in the units
+// mediaNumber uses.
+func (env *MediaEnvironment) featureValue(name string) float64 {
+	switch name {
+	case "width", "device-width":
+		return env.Width
+	case "height", "device-height":
+		return env.Height
+	case "aspect-ratio", "device-aspect-ratio":
+		if env.Height == 0 {
+			return 0
+		}
+		return env.Width / env.Height
+	case "resolution":
+		if env.Resolution == 0 {
+			return 1
+		}
+		return env.Resolution
+	case "color":
+		return float64(env.Color)
+	case "color-index":
+		return float64(env.ColorIndex)
+	case "monochrome":
+		return float64(env.Monochrome)
+	case "grid":
+		if env.Grid {
+			return 1
+		}
+	}
+	return 0
+}
+
+// mediaNumber converts v to a number that can be compared with the value
+// of a feature of type typ: lengths in pixels, resolutions in dppx.
+func (env *MediaEnvironment) mediaNumber(typ int, v MediaValue) (float64, bool) {
+	if v.Ident != "" {
+		if typ == mediaResolution && v.Ident == "infinite" {
+			return math.Inf(1), true
+		}
+		return 0, false
+	}
+	switch typ {
+	case mediaLength:
+		if v.Denominator != 0 {
+			return 0, false
+		}
+		if v.Unit == "" {
+			return 0, v.Number == 0
+		}
+		fontSize := env.FontSize
+		if fontSize == 0 {
+			fontSize = 16
+		}
+		switch v.Unit {
+		case "em", "rem":
+			return v.Number * fontSize, true
+		}
+		if f, ok := pixelsPer[v.Unit]; ok {
+			return v.Number * f, true
+		}
+	case mediaRatio:
+		if v.Unit != "" || v.Number < 0 || v.Denominator < 0 {
+			return 0, false
+		}
+		if v.Denominator == 0 {
+			return v.Number, true
+		}
+		return v.Number / v.Denominator, true
+	case mediaResolution:
+		if v.Denominator != 0 {
+			return 0, false
+		}
+		switch v.Unit {
+		case "dppx", "x":
+			return v.Number, true
+		case "dpi":
+			return v.Number / 96, true
+		case "dpcm":
+			return v.Number * 2.54 / 96, true
+		}
+	case mediaInteger:
+		if v.Unit == "" && v.Denominator == 0 && v.Number == float64(int64(v.Number)) {
+			return v.Number, true
+		}
+	}
+	return 0, false
+}
+
+// pixelsPer is the number of CSS pixels in the absolute length units.
+var pixelsPer = map[string]float64{
+	"px": 1,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+	"q":  96 / 101.6,
+	"pt": 96.0 / 72,
+	"pc": 16,
+}
+
+// compareNumbers compares a with b using the comparison operator op.
+func compareNumbers(a float64, op string, b float64) bool {
+	const epsilon = 1e-9
+	switch op {
+	case "<":
+		return a < b-epsilon
+	case "<=":
+		return a <= b+epsilon
+	case ">":
+		return a > b+epsilon
+	case ">=":
+		return a >= b-epsilon
+	}
+	return a >= b-epsilon && a <= b+epsilon
+}
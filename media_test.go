@@ -0,0 +1,167 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "testing"
+
+func TestParseMediaQueryList(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"", ""},
+		{"print", "print"},
+		{"SCREEN, Print", "screen, print"},
+		{"only screen and (color)", "only screen and (color)"},
+		{"not print and (min-width: 10cm)", "not print and (min-width: 10cm)"},
+		{"(min-width:400px) and (max-width:800px)", "(min-width: 400px) and (max-width: 800px)"},
+		{"(400px <= width < 800px)", "(400px <= width < 800px)"},
+		{"(800px>width>=400px)", "(800px > width >= 400px)"},
+		{"(width >= 600px)", "(width >= 600px)"},
+		{"(600px < width)", "(width > 600px)"},
+		{"(aspect-ratio: 16 / 9)", "(aspect-ratio: 16/9)"},
+		{"(orientation: PORTRAIT)", "(orientation: portrait)"},
+		{"not (color)", "not (color)"},
+		{"(color) or (grid)", "(color) or (grid)"},
+		{"((color) and (grid)) or (hover)", "((color) and (grid)) or (hover)"},
+		{"(not (color))", "not (color)"},
+		{"screen and (foo(bar))", "screen and (foo(bar))"},
+		{"(width: 1px 2px)", "(width: 1px 2px)"},
+		{"(width < = 3px)", "(width < = 3px)"},
+		{"(min-width)", "(min-width)"},
+	} {
+		list, diagnostics := ParseMediaQueryList(test.input)
+		if len(diagnostics) > 0 {
+			t.Errorf("For %q: unexpected diagnostics %v", test.input, diagnostics)
+			continue
+		}
+		if got := list.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestParseMediaQueryListErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"screen and", "line 1, column 8: error: missing condition after 'and'"},
+		{"print, , screen", "line 1, column 8: error: empty media query before ','"},
+		{"print,", "line 1, column 6: error: empty media query after ','"},
+		{"only", "line 1, column 1: error: missing media type"},
+		{"only (color)", "line 1, column 6: error: expected media type, found ( block"},
+		{"and", "line 1, column 1: error: invalid media type and"},
+		{"screen or (color)", "line 1, column 8: error: expected 'and' after media type, found ident"},
+		{"screen and (color) or (grid)", "line 1, column 20: error: 'or' is not allowed after a media type"},
+		{"(color) and (grid) or (hover)", "line 1, column 20: error: 'and' and 'or' can not be mixed without parentheses"},
+		{"not (color) and (grid)", "line 1, column 13: error: unexpected ident after 'not' condition"},
+		{"(color) 12px", "line 1, column 9: error: expected 'and' or 'or', found dimension"},
+	} {
+		list, diagnostics := ParseMediaQueryList(test.input)
+		if len(diagnostics) != 1 {
+			t.Errorf("For %q: expected one diagnostic, got %v", test.input, diagnostics)
+			continue
+		}
+		if got := diagnostics[0].String(); got != test.expected {
+			t.Errorf("For %q:\nexpected %q\ngot      %q", test.input, test.expected, got)
+		}
+		if diagnostics[0].Code != CodeInvalidMediaQuery {
+			t.Errorf("For %q: unexpected code %v", test.input, diagnostics[0].Code)
+		}
+		if list.Match(&MediaEnvironment{Type: "screen", Width: 800, Height: 600, Color: 8}) && test.input != "print, , screen" {
+			t.Errorf("For %q: invalid query matches", test.input)
+		}
+	}
+}
+
+func TestMediaQueryMatch(t *testing.T) {
+	// A4 paper in CSS pixels.
+	page := &MediaEnvironment{
+		Type:       "print",
+		Width:      210 * 96 / 25.4,
+		Height:     297 * 96 / 25.4,
+		Resolution: 3.125,
+		Color:      8,
+		Features:   map[string]string{"hover": "none", "update": "none"},
+	}
+	screen := &MediaEnvironment{Type: "screen", Width: 1280, Height: 800, Color: 8}
+	for _, test := range []struct {
+		query         string
+		print, screen bool
+	}{
+		{"", true, true},
+		{"all", true, true},
+		{"print", true, false},
+		{"screen", false, true},
+		{"not print", false, true},
+		{"only screen", false, true},
+		{"tv", false, false},
+		{"print, screen", true, true},
+		{"print and (orientation: portrait)", true, false},
+		{"(orientation: landscape)", false, true},
+		{"(orientation)", true, true},
+		{"(min-width: 20cm)", true, true},
+		{"(max-width: 210mm)", true, false},
+		{"(width: 210mm)", true, false},
+		{"(width < 1000px)", true, false},
+		{"(600px <= width <= 800px)", true, false},
+		{"(1000px < width < 2000px)", false, true},
+		{"(width > 50em)", false, true},
+		{"(height >= 11in)", true, false},
+		{"(aspect-ratio: 16/10)", false, true},
+		{"(min-aspect-ratio: 1/1)", false, true},
+		{"(resolution >= 300dpi)", true, false},
+		{"(min-resolution: 2dppx)", true, false},
+		{"(resolution: 1x)", false, true},
+		{"(resolution < infinite)", true, true},
+		{"(color)", true, true},
+		{"(min-color: 8)", true, true},
+		{"(color > 8)", false, false},
+		{"(monochrome)", false, false},
+		{"not (monochrome)", true, true},
+		{"(grid: 0)", true, true},
+		{"(hover: none)", true, false},
+		{"(hover)", false, false},
+		{"(update: none) and (color)", true, false},
+		{"(color) or (foo)", true, true},
+		{"(foo) or (color)", true, true},
+		{"(foo)", false, false},
+		{"not (foo)", false, false},
+		{"not all and (foo)", false, false},
+		{"(monochrome) or (foo)", false, false},
+		{"(width: 3s)", false, false},
+		{"(orientation > portrait)", false, false},
+		{"(width < = 3px)", false, false},
+		{"(min-width > 3px)", false, false},
+		{"screen and (max-width: 100px), print", true, false},
+		{"not print and (max-width: 100px)", true, true},
+	} {
+		list, diagnostics := ParseMediaQueryList(test.query)
+		if len(diagnostics) > 0 {
+			t.Fatalf("For %q: unexpected diagnostics %v", test.query, diagnostics)
+		}
+		if got := list.Match(page); got != test.print {
+			t.Errorf("For %q on print: expected %v, got %v", test.query, test.print, got)
+		}
+		if got := list.Match(screen); got != test.screen {
+			t.Errorf("For %q on screen: expected %v, got %v", test.query, test.screen, got)
+		}
+	}
+}
+
+func TestRuleMediaQueries(t *testing.T) {
+	sheet, _ := ParseStylesheet("@media print and (min-width: 5in) { a { b: c } }")
+	list, diagnostics := sheet.Rules[0].MediaQueries()
+	if len(diagnostics) > 0 || len(list) != 1 {
+		t.Fatalf("Unexpected result %v %v", list, diagnostics)
+	}
+	q := list[0]
+	if q.Type != "print" || q.Condition.Kind != MediaFeatureTest || q.Condition.Feature.Name != "min-width" {
+		t.Errorf("Unexpected query %#v", q)
+	}
+	if q.Offset != 7 || q.EndOffset != 33 {
+		t.Errorf("Unexpected span %d-%d", q.Offset, q.EndOffset)
+	}
+}
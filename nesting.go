@@ -0,0 +1,219 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+// --------------------------------------------------------------------
+// CSS Nesting Module Level 1
+//
+// Nested style rules and conditional group rules are parsed by the block
+// parser in stylesheet.go. The functions here resolve the nesting
+// selector & and flatten the nested rules.
+// --------------------------------------------------------------------
+
+// groupRules are the at-rules that may be nested in a style rule and
+// contain style rules and declarations of their own.
+var groupRules = map[string]bool{
+	"media":          true,
+	"supports":       true,
+	"container":      true,
+	"layer":          true,
+	"scope":          true,
+	"starting-style": true,
+	"document":       true,
+}
+
+// ResolveSelector returns the selector list of a nested style rule with
+// the nesting selector & resolved against the selector list parent of
+// the enclosing style rule. Both lists are preludes of style rules, for a
+// rule at the top level parent is nil and nested is returned unchanged.
+//
+// A nested selector without & is relative to the parent, so "> .x" turns
+// into "& > .x" first. The & is then replaced by the parent selector where
+// that does not change its meaning, such as &.x with the parent .a .b,
+// which gives .a .b.x, and by :is(parent) otherwise.
+func ResolveSelector(parent, nested []ComponentValue) []ComponentValue {
+	parents := splitSelectors(parent)
+	if len(parents) == 0 {
+		return nested
+	}
+	var res []ComponentValue
+	for i, sel := range splitSelectors(nested) {
+		if i > 0 {
+			res = append(res, synthetic(Delim, ","), synthetic(S, " "))
+		}
+		if !containsNesting(sel) {
+			sel = append([]ComponentValue{synthetic(Delim, "&"), synthetic(S, " ")}, sel...)
+		}
+		res = append(res, replaceNesting(sel, parents, true)...)
+	}
+	return res
+}
+
+// Desugar returns a copy of the style sheet in which all nested style
+// rules are flattened into top level rules with resolved selectors. Nested
+// conditional group rules such as @media and @supports are moved to the
+// top level and get a style rule with the parent selector for their
+// declarations. The copy shares the tokens and declarations with sh.
+func (sh *Stylesheet) Desugar() *Stylesheet {
+	return &Stylesheet{Rules: desugarRules(sh.Rules, nil)}
+}
+
+// desugarRules flattens rules whose enclosing style rule has the selector
+// list parent, nil at the top level.
+func desugarRules(rules []*Rule, parent []ComponentValue) []*Rule {
+	var out []*Rule
+	for _, r := range rules {
+		switch {
+		case r.IsNestedDeclarations():
+			if parent == nil {
+				out = append(out, r)
+				continue
+			}
+			out = append(out, &Rule{
+				Span:         r.Span,
+				Prelude:      parent,
+				Block:        syntheticBlock(),
+				Declarations: r.Declarations,
+			})
+		case r.AtKeyword == nil:
+			prelude := ResolveSelector(parent, trimWhitespace(r.Prelude))
+			flat := &Rule{
+				Span:         r.Span,
+				Prelude:      prelude,
+				Block:        r.Block,
+				Declarations: r.Declarations,
+			}
+			var nested []*Rule
+			for _, child := range r.Rules {
+				if child.AtKeyword != nil && !groupRules[child.Name()] {
+					// Other at-rules stay where they are.
+					flat.Rules = append(flat.Rules, child)
+					continue
+				}
+				nested = append(nested, desugarRules([]*Rule{child}, prelude)...)
+			}
+			if len(r.Rules) == 0 || len(flat.Declarations) > 0 || len(flat.Rules) > 0 {
+				out = append(out, flat)
+			}
+			out = append(out, nested...)
+		default:
+			at := *r
+			at.Rules = desugarRules(r.Rules, parent)
+			if parent != nil && len(r.Declarations) > 0 {
+				wrapper := &Rule{
+					Span:         r.Span,
+					Prelude:      parent,
+					Block:        syntheticBlock(),
+					Declarations: r.Declarations,
+				}
+				at.Declarations = nil
+				at.Rules = append([]*Rule{wrapper}, at.Rules...)
+			}
+			out = append(out, &at)
+		}
+	}
+	return out
+}
+
+// synthetic returns a preserved token that is not part of the input.
+func synthetic(t Type, value string) ComponentValue {
+	return ComponentValue{Kind: PreservedToken, Token: &Token{Type: t, Value: value}}
+}
+
+// syntheticBlock returns an empty {} block that is not part of the input.
+func syntheticBlock() *ComponentValue {
+	return &ComponentValue{
+		Kind:  SimpleBlock,
+		Token: &Token{Type: Delim, Value: "{"},
+		End:   &Token{Type: Delim, Value: "}"},
+	}
+}
+
+// splitSelectors splits a selector list at the top level commas and
+// removes the whitespace around each selector.
+func splitSelectors(list []ComponentValue) [][]ComponentValue {
+	var res [][]ComponentValue
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !list[i].IsDelim(',') {
+			continue
+		}
+		sel := list[start:i]
+		for len(sel) > 0 && sel[0].IsWhitespace() {
+			sel = sel[1:]
+		}
+		if sel = trimWhitespace(sel); len(sel) > 0 {
+			res = append(res, sel)
+		}
+		start = i + 1
+	}
+	return res
+}
+
+// containsNesting reports whether list contains the nesting selector at
+// any depth.
+func containsNesting(list []ComponentValue) bool {
+	for i := range list {
+		if list[i].IsDelim('&') || containsNesting(list[i].Values) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCombinator reports whether c is a combinator or the whitespace of a
+// descendant combinator.
+func isCombinator(c *ComponentValue) bool {
+	return c.IsWhitespace() || c.IsDelim('>') || c.IsDelim('+') || c.IsDelim('~')
+}
+
+// isCompound reports whether the selector sel has no combinators.
+func isCompound(sel []ComponentValue) bool {
+	for i := range sel {
+		if isCombinator(&sel[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceNesting replaces the nesting selectors in sel with parents. top
+// tells whether sel is a selector of the nested rule itself rather than
+// the argument of a function.
+func replaceNesting(sel []ComponentValue, parents [][]ComponentValue, top bool) []ComponentValue {
+	var res []ComponentValue
+	for i := range sel {
+		c := &sel[i]
+		if c.Kind != PreservedToken {
+			cp := *c
+			cp.Values = replaceNesting(c.Values, parents, false)
+			res = append(res, cp)
+			continue
+		}
+		if !c.IsDelim('&') {
+			res = append(res, *c)
+			continue
+		}
+		compoundStart := i == 0 || isCombinator(&sel[i-1])
+		if len(parents) == 1 && (top && i == 0 || compoundStart && isCompound(parents[0])) {
+			res = append(res, parents[0]...)
+			continue
+		}
+		is := ComponentValue{
+			Kind:  FunctionBlock,
+			Token: &Token{Type: Function, Value: "is"},
+			End:   &Token{Type: Delim, Value: ")"},
+		}
+		for j, p := range parents {
+			if j > 0 {
+				is.Values = append(is.Values, synthetic(Delim, ","), synthetic(S, " "))
+			}
+			is.Values = append(is.Values, p...)
+		}
+		res = append(res, synthetic(Delim, ":"), is)
+	}
+	return res
+}
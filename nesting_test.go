@@ -0,0 +1,63 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "testing"
+
+func TestResolveSelector(t *testing.T) {
+	for _, test := range []struct {
+		parent, nested, expected string
+	}{
+		{"", ".a &", ".a &"},
+		{".card", "&:hover", ".card:hover"},
+		{".card", ".title", ".card .title"},
+		{".card", "> .title", ".card > .title"},
+		{".card", "+ .x, ~ .y", ".card + .x, .card ~ .y"},
+		{".a .b", "&.x", ".a .b.x"},
+		{".a .b", ".x &", ".x :is(.a .b)"},
+		{".a", ".x &", ".x .a"},
+		{"div", ".x&", ".x:is(div)"},
+		{".a, .b", "& .c", ":is(.a, .b) .c"},
+		{".a", "&&", ".a:is(.a)"},
+		{".a > .b", ":not(&) .c", ":not(:is(.a > .b)) .c"},
+		{".a", ":not(&)", ":not(.a)"},
+	} {
+		parent, _ := ParseComponentValueList(test.parent)
+		nested, _ := ParseComponentValueList(test.nested)
+		got := valuesString(t, ResolveSelector(parent, nested))
+		if got != test.expected {
+			t.Errorf("For %q in %q: expected %q, got %q", test.nested, test.parent, test.expected, got)
+		}
+	}
+}
+
+func TestDesugar(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"a { b: c }", "a{b:c;}"},
+		{".card { color: red; &:hover { color: blue } .title { x: y } z: w }",
+			".card{color:red;}.card:hover{color:blue;}.card .title{x:y;}.card{z:w;}"},
+		{".a { .b { .c { x: y } } }", ".a .b .c{x:y;}"},
+		{".a, .b { & + .c { x: y } }", ":is(.a, .b) + .c{x:y;}"},
+		{".a { color: red; @media print { color: blue; .b { x: y } } }",
+			".a{color:red;}@media print {.a{color:blue;}.a .b{x:y;}}"},
+		{".a { @supports (display: grid) { @media screen { x: y } } }",
+			"@supports (display: grid) {@media screen {.a{x:y;}}}"},
+		{"@media print { .a { &:first-child { x: y } } }",
+			"@media print {.a:first-child{x:y;}}"},
+		{".a { @unknown x; b: c }", ".a{@unknown x;}.a{b:c;}"},
+		{"@font-face { font-family: x }", "@font-face {font-family:x;}"},
+	} {
+		sheet, diagnostics := ParseStylesheet(test.input)
+		if len(diagnostics) > 0 {
+			t.Fatalf("For %q: unexpected diagnostics %v", test.input, diagnostics)
+		}
+		if got := emitString(t, sheet.Desugar()); got != test.expected {
+			t.Errorf("For %q:\nexpected %q\ngot      %q", test.input, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"slices"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Paged Media Module Level 3: @page rules and margin boxes
+// --------------------------------------------------------------------
+
+// PageRule is a parsed @page rule.
+type PageRule struct {
+	Span
+	// Selectors is the page selector list. It is empty for a rule that
+	// applies to all pages.
+	Selectors []*PageSelector
+	// Declarations are the descriptors and properties of the page box.
+	Declarations []*Declaration
+	// Margins are the margin rules such as @top-center in source order.
+	Margins []*MarginRule
+}
+
+// PageSelector is a page selector such as "chapter:first:left".
+type PageSelector struct {
+	Span
+	// Name is the page type, or "" if the selector has none.
+	Name string
+	// Pseudos are the lowercased page pseudo-classes without the colon:
+	// "first", "left", "right" or "blank".
+	Pseudos []string
+}
+
+// MarginRule is a margin at-rule in an @page rule, such as
+// @top-left { content: "Chapter" }.
+type MarginRule struct {
+	Span
+	// Name is the lowercased name of the margin box without the @, such as
+	// "bottom-right-corner".
+	Name         string
+	Declarations []*Declaration
+}
+
+// MarginBoxes are the names of the sixteen page-margin boxes, clockwise
+// from the top left corner.
+var MarginBoxes = []string{
+	"top-left-corner", "top-left", "top-center", "top-right", "top-right-corner",
+	"right-top", "right-middle", "right-bottom",
+	"bottom-right-corner", "bottom-right", "bottom-center", "bottom-left", "bottom-left-corner",
+	"left-bottom", "left-middle", "left-top",
+}
+
+var marginBoxNames = func() map[string]bool {
+	m := make(map[string]bool, len(MarginBoxes))
+	for _, name := range MarginBoxes {
+		m[name] = true
+	}
+	return m
+}()
+
+// PageContext describes a page for matching page selectors.
+type PageContext struct {
+	// Name is the page type given by the page property, or "".
+	Name string
+	// First is set for the first page of the document, Blank for a page
+	// that is inserted to satisfy a forced break.
+	First, Blank bool
+	// Left is set for a left page, otherwise the page is a right page.
+	Left bool
+}
+
+// ParsePageSelectorList parses input as the selector list of an @page
+// rule.
+func ParsePageSelectorList(input string) ([]*PageSelector, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return nil, &diagnostics[i]
+		}
+	}
+	return ParsePageSelectorValues(list)
+}
+
+// ParsePageSelectorValues parses a page selector list from component
+// values such as the prelude of an @page rule. An empty list is valid.
+func ParsePageSelectorValues(list []ComponentValue) ([]*PageSelector, error) {
+	list = trimWhitespace(list)
+	list = list[skipSpace(list, 0):]
+	if len(list) == 0 {
+		return nil, nil
+	}
+	var res []*PageSelector
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !list[i].IsDelim(',') {
+			continue
+		}
+		item := trimWhitespace(list[start:i])
+		item = item[skipSpace(item, 0):]
+		if len(item) == 0 {
+			if i < len(list) {
+				return nil, syntaxError(list[i].Span(), CodeInvalidPageSelector, "empty page selector before ','")
+			}
+			return nil, syntaxError(list[i-1].Span(), CodeInvalidPageSelector, "empty page selector after ','")
+		}
+		sel, err := parsePageSelector(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, sel)
+		start = i + 1
+	}
+	return res, nil
+}
+
+// parsePageSelector parses a single page selector. The name and the
+// pseudo-classes must not be separated by whitespace.
+func parsePageSelector(list []ComponentValue) (*PageSelector, error) {
+	sel := &PageSelector{Span: spanOf(list[0].Token, list[len(list)-1].lastToken())}
+	pos := 0
+	if isToken(&list[0], Ident) {
+		sel.Name = list[0].Token.Value
+		pos++
+	}
+	for pos < len(list) {
+		c := &list[pos]
+		if c.IsWhitespace() {
+			return nil, syntaxError(c.Span(), CodeInvalidPageSelector, "unexpected whitespace in page selector")
+		}
+		if !c.IsDelim(':') {
+			return nil, syntaxError(c.Span(), CodeInvalidPageSelector, "expected ':', found "+describe(c))
+		}
+		if pos+1 == len(list) || !isToken(&list[pos+1], Ident) {
+			return nil, syntaxError(c.Span(), CodeInvalidPageSelector, "missing page pseudo-class after ':'")
+		}
+		name := &list[pos+1]
+		pseudo := strings.ToLower(name.Token.Value)
+		switch pseudo {
+		case "first", "left", "right", "blank":
+		default:
+			return nil, syntaxError(name.Span(), CodeInvalidPageSelector, "unknown page pseudo-class :"+name.Token.Value)
+		}
+		sel.Pseudos = append(sel.Pseudos, pseudo)
+		pos += 2
+	}
+	return sel, nil
+}
+
+// Page converts the @page rule r into a PageRule. Margin rules with an
+// unknown name, other at-rules and style rules in the block are ignored.
+// An error is returned if r is not an @page rule.
+func (r *Rule) Page() (*PageRule, error) {
+	if r.AtKeyword == nil || !strings.EqualFold(r.Name(), "page") {
+		return nil, syntaxError(r.Span, CodeInvalidRule, "not an @page rule")
+	}
+	selectors, err := ParsePageSelectorValues(r.Prelude)
+	if err != nil {
+		return nil, err
+	}
+	page := &PageRule{Span: r.Span, Selectors: selectors, Declarations: slices.Clip(r.Declarations)}
+	for _, child := range r.Rules {
+		switch {
+		case child.IsNestedDeclarations():
+			page.Declarations = append(page.Declarations, child.Declarations...)
+		case child.AtKeyword != nil && marginBoxNames[strings.ToLower(child.Name())] && child.Block != nil:
+			decls := slices.Clip(child.Declarations)
+			for _, nested := range child.Rules {
+				if nested.IsNestedDeclarations() {
+					decls = append(decls, nested.Declarations...)
+				}
+			}
+			page.Margins = append(page.Margins, &MarginRule{
+				Span:         child.Span,
+				Name:         strings.ToLower(child.Name()),
+				Declarations: decls,
+			})
+		}
+	}
+	return page, nil
+}
+
+// Margin returns the last margin rule with the given name, or nil.
+func (p *PageRule) Margin(name string) *MarginRule {
+	for i := len(p.Margins) - 1; i >= 0; i-- {
+		if p.Margins[i].Name == name {
+			return p.Margins[i]
+		}
+	}
+	return nil
+}
+
+// Specificity returns the specificity of the page selector: A is 1 if it
+// has a page type, B counts :first and :blank and C counts :left and
+// :right.
+func (s *PageSelector) Specificity() Specificity {
+	var spec Specificity
+	if s.Name != "" {
+		spec.A = 1
+	}
+	for _, pseudo := range s.Pseudos {
+		if pseudo == "first" || pseudo == "blank" {
+			spec.B++
+		} else {
+			spec.C++
+		}
+	}
+	return spec
+}
+
+// Match reports whether the selector matches the page.
+func (s *PageSelector) Match(page PageContext) bool {
+	if s.Name != "" && s.Name != page.Name {
+		return false
+	}
+	for _, pseudo := range s.Pseudos {
+		var ok bool
+		switch pseudo {
+		case "first":
+			ok = page.First
+		case "blank":
+			ok = page.Blank
+		case "left":
+			ok = page.Left
+		case "right":
+			ok = !page.Left
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether the rule applies to the page and returns the
+// highest specificity of the matching selectors. A rule without selectors
+// applies to all pages with specificity zero.
+func (p *PageRule) Match(page PageContext) (Specificity, bool) {
+	if len(p.Selectors) == 0 {
+		return Specificity{}, true
+	}
+	var best Specificity
+	matched := false
+	for _, sel := range p.Selectors {
+		if !sel.Match(page) {
+			continue
+		}
+		if s := sel.Specificity(); !matched || best.Less(s) {
+			best = s
+		}
+		matched = true
+	}
+	return best, matched
+}
+
+// String returns the CSS representation of the page selector.
+func (s *PageSelector) String() string {
+	var sb strings.Builder
+	if s.Name != "" {
+		sb.WriteString(backslashifyIdent(s.Name))
+	}
+	for _, pseudo := range s.Pseudos {
+		sb.WriteString(":" + pseudo)
+	}
+	return sb.String()
+}
@@ -0,0 +1,157 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePageSelectorList(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+		specificity     []Specificity
+	}{
+		{"", "", nil},
+		{":first", ":first", []Specificity{{0, 1, 0}}},
+		{":LEFT", ":left", []Specificity{{0, 0, 1}}},
+		{"chapter", "chapter", []Specificity{{1, 0, 0}}},
+		{"chapter:first:right", "chapter:first:right", []Specificity{{1, 1, 1}}},
+		{":blank:first", ":blank:first", []Specificity{{0, 2, 0}}},
+		{"toc, index:left", "toc,index:left", []Specificity{{1, 0, 0}, {1, 0, 1}}},
+	} {
+		list, err := ParsePageSelectorList(test.input)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		var parts []string
+		for i, sel := range list {
+			parts = append(parts, sel.String())
+			if got := sel.Specificity(); got != test.specificity[i] {
+				t.Errorf("For %q: expected specificity %v, got %v", test.input, test.specificity[i], got)
+			}
+		}
+		if got := strings.Join(parts, ","); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestParsePageSelectorListErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{":middle", "line 1, column 2: error: unknown page pseudo-class :middle"},
+		{"chapter :first", "line 1, column 8: error: unexpected whitespace in page selector"},
+		{": first", "line 1, column 1: error: missing page pseudo-class after ':'"},
+		{"a b", "line 1, column 2: error: unexpected whitespace in page selector"},
+		{".x", "line 1, column 1: error: expected ':', found '.'"},
+		{"a,", "line 1, column 2: error: empty page selector after ','"},
+		{",a", "line 1, column 1: error: empty page selector before ','"},
+	} {
+		_, err := ParsePageSelectorList(test.input)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeInvalidPageSelector {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+}
+
+func TestRulePage(t *testing.T) {
+	sheet, diagnostics := ParseStylesheet(`@page chapter:first {
+		size: A4;
+		@top-center { content: "Chapter" }
+		@BOTTOM-RIGHT-CORNER { content: counter(page); color: gray }
+		@unknown { x: y }
+		div { x: y }
+		margin: 2cm;
+	}`)
+	if len(diagnostics) > 0 {
+		t.Fatalf("Unexpected diagnostics %v", diagnostics)
+	}
+	page, err := sheet.Rules[0].Page()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Selectors) != 1 || page.Selectors[0].String() != "chapter:first" {
+		t.Errorf("Unexpected selectors %v", page.Selectors)
+	}
+	var names []string
+	for _, d := range page.Declarations {
+		names = append(names, d.Name)
+	}
+	if got := strings.Join(names, " "); got != "size margin" {
+		t.Errorf("Unexpected declarations %q", got)
+	}
+	names = nil
+	for _, m := range page.Margins {
+		names = append(names, m.Name)
+	}
+	if got := strings.Join(names, " "); got != "top-center bottom-right-corner" {
+		t.Errorf("Unexpected margin rules %q", got)
+	}
+	m := page.Margin("bottom-right-corner")
+	if m == nil || len(m.Declarations) != 2 || m.Declarations[1].Name != "color" {
+		t.Errorf("Unexpected margin rule %v", m)
+	}
+	if page.Margin("left-top") != nil {
+		t.Error("Unexpected margin rule left-top")
+	}
+	if len(MarginBoxes) != 16 {
+		t.Errorf("Expected 16 margin boxes, got %d", len(MarginBoxes))
+	}
+
+	sheet, _ = ParseStylesheet("@page :unknown { x: y }")
+	if _, err := sheet.Rules[0].Page(); err == nil {
+		t.Error("Expected an error for an invalid page selector")
+	}
+
+	sheet, _ = ParseStylesheet("@media print { a { b: c } } :first { x: y }")
+	for _, r := range sheet.Rules {
+		page, err := r.Page()
+		if page != nil || err == nil || err.(*Diagnostic).Code != CodeInvalidRule {
+			t.Errorf("For %q: expected not an @page rule, got %v %v", r.Prelude, page, err)
+		}
+	}
+}
+
+func TestPageMatch(t *testing.T) {
+	first := PageContext{Name: "chapter", First: true}
+	left := PageContext{Name: "chapter", Left: true}
+	blank := PageContext{Blank: true}
+	for _, test := range []struct {
+		selectors string
+		page      PageContext
+		match     bool
+		spec      Specificity
+	}{
+		{"", first, true, Specificity{}},
+		{":first", first, true, Specificity{0, 1, 0}},
+		{":first", left, false, Specificity{}},
+		{":right", first, true, Specificity{0, 0, 1}},
+		{":left", left, true, Specificity{0, 0, 1}},
+		{"chapter", left, true, Specificity{1, 0, 0}},
+		{"toc", left, false, Specificity{}},
+		{"chapter:first:right", first, true, Specificity{1, 1, 1}},
+		{":blank", blank, true, Specificity{0, 1, 0}},
+		{":right, chapter:first", first, true, Specificity{1, 1, 0}},
+		{"chapter, :left", left, true, Specificity{1, 0, 0}},
+	} {
+		list, err := ParsePageSelectorList(test.selectors)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.selectors, err)
+		}
+		spec, ok := (&PageRule{Selectors: list}).Match(test.page)
+		if ok != test.match || spec != test.spec {
+			t.Errorf("For %q on %+v: expected %v %v, got %v %v", test.selectors, test.page, test.match, test.spec, ok, spec)
+		}
+	}
+}
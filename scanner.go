@@ -6,6 +6,7 @@
 package css
 
 import (
+	"io"
 	"strings"
 	"unicode/utf8"
 )
@@ -32,6 +33,11 @@ func isUpperHex(c byte) bool {
 	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'F')
 }
 
+// isLowerHexLetter returns true for lowercase a-f only.
+func isLowerHexLetter(c byte) bool {
+	return c >= 'a' && c <= 'f'
+}
+
 // startsWithFold checks if s starts with prefix, case-insensitive (ASCII only).
 func startsWithFold(s, prefix string) bool {
 	if len(s) < len(prefix) {
@@ -58,26 +64,163 @@ func startsWithFold(s, prefix string) bool {
 // Scanner
 // --------------------------------------------------------------------
 
+// Mode is a set of flags that control how a Scanner tokenizes its input.
+// The mode must be set before the first call to Next.
+type Mode uint
+
+const (
+	// Lossless makes tokens remember their source text, so that Emit
+	// reproduces the input byte for byte for every token whose Type and
+	// Value have not been changed.
+	Lossless Mode = 1 << iota
+
+	// Syntax3 makes the scanner emit exactly the token set of the CSS
+	// Syntax Module Level 3 tokenizer, with its error recovery rules:
+	//
+	//   - ":", ";", ",", "(", ")", "[", "]", "{" and "}" are returned as
+	//     Colon, Semicolon, Comma, LeftParen, RightParen, LeftBracket,
+	//     RightBracket, LeftBrace and RightBrace instead of Delim.
+	//   - Comments and the byte order mark are skipped.
+	//   - A string that runs into a newline is a BadString, an unquoted
+	//     url() with invalid content a BadURL. Unclosed strings, comments
+	//     and urls end at the end of the input. No Error tokens are
+	//     produced.
+	//   - url() is only a URI token when its argument is not quoted;
+	//     local(), format() and tech() are ordinary functions. There are
+	//     no UnicodeRange, Includes, DashMatch, PrefixMatch, SuffixMatch
+	//     or SubstringMatch tokens; their characters are Delim tokens.
+	//   - Escapes and NUL characters that do not encode a valid code point
+	//     produce U+FFFD.
+	//
+	// The problems are recorded as Diagnostics.
+	Syntax3
+
+	// Recover makes the default tokenizer continue after errors instead
+	// of returning an Error token. A string that runs into a newline
+	// becomes a BadString token that ends before the newline, a string
+	// or comment that is not closed runs to the end of the input. The
+	// problems are recorded as Diagnostics.
+	Recover
+)
+
 // New returns a new CSS scanner for the given input.
 func New(input string) *Scanner {
-	// Normalize newlines. Only allocate if the input contains \r.
-	if strings.ContainsRune(input, '\r') {
-		input = strings.ReplaceAll(input, "\r\n", "\n")
-	}
 	return &Scanner{
 		input: input,
 		row:   1,
 		col:   1,
+		eof:   true,
+	}
+}
+
+// NewReader returns a new CSS scanner that reads its input from r. The
+// input is consumed incrementally through an internal buffer, so the
+// whole stylesheet never needs to be held in memory. The token stream is
+// identical to the one New produces for the same input.
+//
+// Read errors other than io.EOF are reported as an Error token.
+func NewReader(r io.Reader) *Scanner {
+	return &Scanner{
+		r:   r,
+		row: 1,
+		col: 1,
 	}
 }
 
+// readChunkSize is the minimum number of bytes NewReader scanners request
+// from the underlying reader on each refill.
+const readChunkSize = 64 * 1024
+
 // Scanner scans an input and emits tokens following the CSS3 specification.
 type Scanner struct {
+	// Mode controls the tokenization, see the Mode constants.
+	Mode Mode
+
 	input string
 	pos   int
 	row   int
 	col   int
 	err   *Token
+
+	diagnostics []Diagnostic
+
+	// Streaming state, only used by scanners created with NewReader.
+	r     io.Reader
+	buf   []byte
+	base  int  // offset of input[0] in the complete input
+	eof   bool // input holds everything up to the end of the input
+	short bool // the current scan needed bytes beyond the buffered input
+}
+
+// more reports whether pos is inside the buffered input. If it is not and
+// the reader has more data, the current scan is marked as short so that
+// Next can refill the buffer and scan the token again.
+func (s *Scanner) more(pos int) bool {
+	if pos < len(s.input) {
+		return true
+	}
+	if !s.eof {
+		s.short = true
+	}
+	return false
+}
+
+// decodeRune decodes the rune at s.input[pos], marking the scan as short
+// if the buffer ends in the middle of a UTF-8 sequence.
+func (s *Scanner) decodeRune(pos int) (rune, int) {
+	if !s.eof && !utf8.FullRuneInString(s.input[pos:]) {
+		s.short = true
+	}
+	return utf8.DecodeRuneInString(s.input[pos:])
+}
+
+// hasPrefix reports whether the input at s.pos starts with prefix.
+func (s *Scanner) hasPrefix(prefix string) bool {
+	if !s.more(s.pos + len(prefix) - 1) {
+		return false
+	}
+	return strings.HasPrefix(s.input[s.pos:], prefix)
+}
+
+// fill discards the consumed part of the buffer and reads more input from
+// the reader. The unconsumed rest is the start of a token that did not fit,
+// so fill reads at least as many new bytes as the rest is long: the buffered
+// input doubles on every refill, and a token of n bytes is scanned again
+// only O(log n) times.
+func (s *Scanner) fill() error {
+	s.base += s.pos
+	rest := s.input[s.pos:]
+	s.pos = 0
+	need := max(len(rest), 1)
+	if size := max(readChunkSize, need); len(s.buf) < size {
+		s.buf = make([]byte, size)
+	}
+	n := 0
+	for stalled := 0; n < need; {
+		m, err := s.r.Read(s.buf[n:])
+		n += m
+		if err == io.EOF {
+			s.eof = true
+			break
+		}
+		if err != nil {
+			if n > 0 {
+				// Return what has been read, the reader reports the
+				// error again on the next call.
+				break
+			}
+			s.input = rest
+			return err
+		}
+		if m > 0 {
+			stalled = 0
+		} else if stalled++; stalled == 100 {
+			s.input = rest
+			return io.ErrNoProgress
+		}
+	}
+	s.input = rest + string(s.buf[:n])
+	return nil
 }
 
 // --------------------------------------------------------------------
@@ -92,47 +235,71 @@ type Scanner struct {
 // a valid escape.
 func (s *Scanner) scanEscapeLen(offset int) int {
 	pos := s.pos + offset
-	if pos >= len(s.input) || s.input[pos] != '\\' {
+	if !s.more(pos) || s.input[pos] != '\\' {
 		return 0
 	}
 	pos++
-	if pos >= len(s.input) {
+	if !s.more(pos) {
+		if s.Mode&Syntax3 != 0 {
+			return 1 // escapes EOF, which is U+FFFD
+		}
 		return 0 // lone backslash
 	}
 	c := s.input[pos]
 	if isHexChar(c) {
 		// Hex escape: 1-6 hex digits, optional single trailing whitespace.
 		pos++
-		for i := 1; i < 6 && pos < len(s.input) && isHexChar(s.input[pos]); i++ {
-			pos++
-		}
-		if pos < len(s.input) && isWhitespace(s.input[pos]) {
+		for i := 1; i < 6 && s.more(pos) && isHexChar(s.input[pos]); i++ {
 			pos++
 		}
+		pos += s.escapeSpaceLen(pos)
 		return pos - (s.pos + offset)
 	}
 	// Literal escape: any char in U+0020..U+007E or nonascii.
 	if c >= 0x80 {
-		_, w := utf8.DecodeRuneInString(s.input[pos:])
+		_, w := s.decodeRune(pos)
 		return 1 + w
 	}
 	if c >= 0x20 && c <= 0x7e {
 		return 2
 	}
+	// CSS Syntax 3 allows escaping anything but a newline.
+	if s.Mode&Syntax3 != 0 && c != '\n' && c != '\r' && c != '\f' {
+		return 2
+	}
 	return 0
 }
 
+// escapeSpaceLen returns the byte length of the whitespace that may follow
+// a hex escape at s.input[pos]. A CRLF pair counts as a single newline.
+func (s *Scanner) escapeSpaceLen(pos int) int {
+	if !s.more(pos) || !isWhitespace(s.input[pos]) {
+		return 0
+	}
+	if s.input[pos] == '\r' && s.more(pos+1) && s.input[pos+1] == '\n' {
+		return 2
+	}
+	return 1
+}
+
+// isNonASCII reports whether c starts a character that counts as non-ASCII
+// in names. In Syntax3 mode this includes NUL, which the specification
+// replaces with U+FFFD.
+func (s *Scanner) isNonASCII(c byte) bool {
+	return c >= 0x80 || c == 0 && s.Mode&Syntax3 != 0
+}
+
 // scanNameLen returns the byte length of consecutive nmchar characters
 // starting at s.input[s.pos+offset]. nmchar = [a-zA-Z0-9_-] | nonascii | escape.
 func (s *Scanner) scanNameLen(offset int) int {
 	pos := s.pos + offset
 	start := pos
-	for pos < len(s.input) {
+	for s.more(pos) {
 		c := s.input[pos]
 		if isNmCharByte(c) {
 			pos++
-		} else if c >= 0x80 {
-			_, w := utf8.DecodeRuneInString(s.input[pos:])
+		} else if s.isNonASCII(c) {
+			_, w := s.decodeRune(pos)
 			pos += w
 		} else if c == '\\' {
 			n := s.scanEscapeLen(pos - s.pos)
@@ -153,16 +320,17 @@ func (s *Scanner) scanNameLen(offset int) int {
 func (s *Scanner) scanIdentLen(offset int) int {
 	pos := s.pos + offset
 	start := pos
-	if pos >= len(s.input) {
+	if !s.more(pos) {
 		return 0
 	}
 
 	// Case 1: --{nmchar}+ (custom properties, requires at least one nmchar
-	// so that "-->" is not consumed as ident).
-	if pos+1 < len(s.input) && s.input[pos] == '-' && s.input[pos+1] == '-' {
+	// so that "-->" is not consumed as ident). In Syntax3 mode "--" on its
+	// own is an ident; "-->" is handled before idents are scanned.
+	if s.more(pos+1) && s.input[pos] == '-' && s.input[pos+1] == '-' {
 		pos += 2
 		n := s.scanNameLen(pos - s.pos)
-		if n == 0 {
+		if n == 0 && s.Mode&Syntax3 == 0 {
 			return 0
 		}
 		return 2 + n
@@ -171,7 +339,7 @@ func (s *Scanner) scanIdentLen(offset int) int {
 	// Case 2: -?{nmstart}{nmchar}*
 	if s.input[pos] == '-' {
 		pos++
-		if pos >= len(s.input) {
+		if !s.more(pos) {
 			return 0
 		}
 	}
@@ -179,8 +347,8 @@ func (s *Scanner) scanIdentLen(offset int) int {
 	c := s.input[pos]
 	if isNmStartByte(c) {
 		pos++
-	} else if c >= 0x80 {
-		_, w := utf8.DecodeRuneInString(s.input[pos:])
+	} else if s.isNonASCII(c) {
+		_, w := s.decodeRune(pos)
 		pos += w
 	} else if c == '\\' {
 		n := s.scanEscapeLen(pos - s.pos)
@@ -201,7 +369,7 @@ func (s *Scanner) scanIdentLen(offset int) int {
 func (s *Scanner) scanNumLen(offset int) int {
 	pos := s.pos + offset
 	start := pos
-	if pos >= len(s.input) {
+	if !s.more(pos) {
 		return 0
 	}
 
@@ -212,29 +380,37 @@ func (s *Scanner) scanNumLen(offset int) int {
 
 	// Integer part
 	hasDigits := false
-	for pos < len(s.input) && isDigitByte(s.input[pos]) {
+	for s.more(pos) && isDigitByte(s.input[pos]) {
 		pos++
 		hasDigits = true
 	}
 
 	// Decimal part
-	if pos < len(s.input) && s.input[pos] == '.' {
-		if pos+1 < len(s.input) && isDigitByte(s.input[pos+1]) {
-			pos++ // consume dot
-			for pos < len(s.input) && isDigitByte(s.input[pos]) {
-				pos++
-			}
-			return pos - start
-		}
-		if !hasDigits {
-			return 0
+	if s.more(pos+1) && s.input[pos] == '.' && isDigitByte(s.input[pos+1]) {
+		pos++ // consume dot
+		for s.more(pos) && isDigitByte(s.input[pos]) {
+			pos++
 		}
-		return pos - start
+		hasDigits = true
 	}
 
 	if !hasDigits {
 		return 0
 	}
+
+	// Exponent: only if digits follow, so that 1em and 1e-x are dimensions.
+	if s.more(pos) && (s.input[pos] == 'e' || s.input[pos] == 'E') {
+		exp := pos + 1
+		if s.more(exp) && (s.input[exp] == '+' || s.input[exp] == '-') {
+			exp++
+		}
+		if s.more(exp) && isDigitByte(s.input[exp]) {
+			pos = exp
+			for s.more(pos) && isDigitByte(s.input[pos]) {
+				pos++
+			}
+		}
+	}
 	return pos - start
 }
 
@@ -242,7 +418,7 @@ func (s *Scanner) scanNumLen(offset int) int {
 // starting at s.input[s.pos+offset], and whether the scan was successful.
 func (s *Scanner) scanStringLen(offset int) (int, bool) {
 	pos := s.pos + offset
-	if pos >= len(s.input) {
+	if !s.more(pos) {
 		return 0, false
 	}
 	quote := s.input[pos]
@@ -250,35 +426,33 @@ func (s *Scanner) scanStringLen(offset int) (int, bool) {
 		return 0, false
 	}
 	pos++
-	for pos < len(s.input) {
+	for s.more(pos) {
 		c := s.input[pos]
 		if c == quote {
 			return pos + 1 - (s.pos + offset), true
 		}
 		if c == '\\' {
 			pos++
-			if pos >= len(s.input) {
+			if !s.more(pos) {
 				return 0, false
 			}
 			nc := s.input[pos]
 			if isHexChar(nc) {
 				// Hex escape: up to 6 hex digits + optional whitespace.
 				pos++
-				for i := 1; i < 6 && pos < len(s.input) && isHexChar(s.input[pos]); i++ {
-					pos++
-				}
-				if pos < len(s.input) && isWhitespace(s.input[pos]) {
+				for i := 1; i < 6 && s.more(pos) && isHexChar(s.input[pos]); i++ {
 					pos++
 				}
+				pos += s.escapeSpaceLen(pos)
 			} else if nc == '\n' || nc == '\f' {
 				pos++
 			} else if nc == '\r' {
 				pos++
-				if pos < len(s.input) && s.input[pos] == '\n' {
+				if s.more(pos) && s.input[pos] == '\n' {
 					pos++
 				}
 			} else if nc >= 0x80 {
-				_, w := utf8.DecodeRuneInString(s.input[pos:])
+				_, w := s.decodeRune(pos)
 				pos += w
 			} else {
 				pos++
@@ -289,7 +463,7 @@ func (s *Scanner) scanStringLen(offset int) (int, bool) {
 			return 0, false // unescaped newline terminates string (error)
 		}
 		if c >= 0x80 {
-			_, w := utf8.DecodeRuneInString(s.input[pos:])
+			_, w := s.decodeRune(pos)
 			pos += w
 		} else {
 			pos++
@@ -302,11 +476,11 @@ func (s *Scanner) scanStringLen(offset int) (int, bool) {
 // s.pos, and whether the scan was successful.
 func (s *Scanner) scanCommentLen() (int, bool) {
 	pos := s.pos
-	if pos+1 >= len(s.input) || s.input[pos] != '/' || s.input[pos+1] != '*' {
+	if !s.more(pos+1) || s.input[pos] != '/' || s.input[pos+1] != '*' {
 		return 0, false
 	}
 	pos += 2
-	for pos+1 < len(s.input) {
+	for s.more(pos + 1) {
 		if s.input[pos] == '*' && s.input[pos+1] == '/' {
 			return pos + 2 - s.pos, true
 		}
@@ -320,7 +494,7 @@ func (s *Scanner) scanCommentLen() (int, bool) {
 func (s *Scanner) scanWhitespaceLen(offset int) int {
 	pos := s.pos + offset
 	start := pos
-	for pos < len(s.input) && isWhitespace(s.input[pos]) {
+	for s.more(pos) && isWhitespace(s.input[pos]) {
 		pos++
 	}
 	return pos - start
@@ -329,9 +503,16 @@ func (s *Scanner) scanWhitespaceLen(offset int) int {
 // scanUnicodeRangeLen returns the byte length of a unicode range token
 // starting at s.pos. Format: U+hex{1,6}(-hex{1,6})? or U+[hex?]{1,6}.
 // Uses uppercase hex only, matching CSS spec. Returns 0 if invalid.
+//
+// Lowercase hex digits are not accepted, so that selectors such as u+b
+// keep their meaning. A range written with them, as in u+00ff or
+// U+00FF-01ab, is not a UnicodeRange token but is scanned like in CSS
+// Syntax 3, for example as Ident "u" and Dimension "+00ff", instead of
+// being cut at the first lowercase digit. ParseUnicodeRangeValues puts
+// such ranges back together from these tokens.
 func (s *Scanner) scanUnicodeRangeLen() int {
 	pos := s.pos
-	if pos+2 >= len(s.input) {
+	if !s.more(pos + 2) {
 		return 0
 	}
 	if (s.input[pos] != 'U' && s.input[pos] != 'u') || s.input[pos+1] != '+' {
@@ -339,14 +520,14 @@ func (s *Scanner) scanUnicodeRangeLen() int {
 	}
 	pos += 2
 
-	if pos >= len(s.input) || (!isUpperHex(s.input[pos]) && s.input[pos] != '?') {
+	if !s.more(pos) || (!isUpperHex(s.input[pos]) && s.input[pos] != '?') {
 		return 0
 	}
 
 	// Consume hex digits and ? marks (up to 6 total).
 	count := 0
 	hasQuestion := false
-	for count < 6 && pos < len(s.input) {
+	for count < 6 && s.more(pos) {
 		c := s.input[pos]
 		if isUpperHex(c) && !hasQuestion {
 			pos++
@@ -359,6 +540,9 @@ func (s *Scanner) scanUnicodeRangeLen() int {
 			break
 		}
 	}
+	if s.more(pos) && isLowerHexLetter(s.input[pos]) {
+		return 0
+	}
 
 	// If we had question marks, no range suffix allowed.
 	if hasQuestion {
@@ -366,14 +550,17 @@ func (s *Scanner) scanUnicodeRangeLen() int {
 	}
 
 	// Optional range: -hex{1,6}
-	if pos < len(s.input) && s.input[pos] == '-' {
+	if s.more(pos) && s.input[pos] == '-' {
 		rangeStart := pos
 		pos++
 		rangeCount := 0
-		for rangeCount < 6 && pos < len(s.input) && isUpperHex(s.input[pos]) {
+		for rangeCount < 6 && s.more(pos) && isUpperHex(s.input[pos]) {
 			pos++
 			rangeCount++
 		}
+		if s.more(pos) && isLowerHexLetter(s.input[pos]) {
+			return 0
+		}
 		if rangeCount == 0 {
 			pos = rangeStart // no hex digits after -, back up
 		}
@@ -389,10 +576,10 @@ func (s *Scanner) scanFuncBodyLen(prefixLen int) (int, bool) {
 	pos := s.pos + prefixLen
 
 	// Skip leading whitespace.
-	for pos < len(s.input) && isWhitespace(s.input[pos]) {
+	for s.more(pos) && isWhitespace(s.input[pos]) {
 		pos++
 	}
-	if pos >= len(s.input) {
+	if !s.more(pos) {
 		return 0, false
 	}
 
@@ -409,10 +596,10 @@ func (s *Scanner) scanFuncBodyLen(prefixLen int) (int, bool) {
 	if !stringMatched {
 		// Scan unquoted urlchars. Rewind to after prefix + whitespace.
 		pos = s.pos + prefixLen
-		for pos < len(s.input) && isWhitespace(s.input[pos]) {
+		for s.more(pos) && isWhitespace(s.input[pos]) {
 			pos++
 		}
-		for pos < len(s.input) {
+		for s.more(pos) {
 			c := s.input[pos]
 			if c == ')' {
 				break
@@ -438,7 +625,7 @@ func (s *Scanner) scanFuncBodyLen(prefixLen int) (int, bool) {
 			}
 			// Non-ASCII: valid urlchar.
 			if c >= 0x80 {
-				_, w := utf8.DecodeRuneInString(s.input[pos:])
+				_, w := s.decodeRune(pos)
 				pos += w
 				continue
 			}
@@ -447,12 +634,12 @@ func (s *Scanner) scanFuncBodyLen(prefixLen int) (int, bool) {
 	}
 
 	// Skip trailing whitespace.
-	for pos < len(s.input) && isWhitespace(s.input[pos]) {
+	for s.more(pos) && isWhitespace(s.input[pos]) {
 		pos++
 	}
 
 	// Expect closing paren.
-	if pos < len(s.input) && s.input[pos] == ')' {
+	if s.more(pos) && s.input[pos] == ')' {
 		return pos + 1 - s.pos, true
 	}
 	return 0, false
@@ -467,18 +654,43 @@ func (s *Scanner) scanFuncBodyLen(prefixLen int) (int, bool) {
 // At the end of the input the token type is EOF.
 //
 // If the input can't be tokenized the token type is Error. This occurs
-// in case of unclosed quotation marks or comments.
+// in case of unclosed quotation marks or comments, unless the scanner is
+// in Recover or Syntax3 mode.
 func (s *Scanner) Next() *Token {
-	if s.err != nil {
-		return s.err
+	for {
+		if s.err != nil {
+			return s.err
+		}
+		pos, row, col, ndiag := s.pos, s.row, s.col, len(s.diagnostics)
+		s.short = false
+		var token *Token
+		if s.Mode&Syntax3 != 0 {
+			token = s.next3()
+		} else {
+			token = s.next()
+		}
+		if !s.short {
+			return token
+		}
+		// The token may continue beyond the buffered input: rewind, read
+		// more and scan it again.
+		s.pos, s.row, s.col, s.err = pos, row, col, nil
+		s.diagnostics = s.diagnostics[:ndiag]
+		if err := s.fill(); err != nil {
+			s.err = s.emptyToken(Error, err.Error())
+		}
 	}
-	if s.pos >= len(s.input) {
-		s.err = &Token{EOF, "", s.row, s.col}
+}
+
+// next scans a single token from the buffered input.
+func (s *Scanner) next() *Token {
+	if !s.more(s.pos) {
+		s.err = s.emptyToken(EOF, "")
 		return s.err
 	}
-	if s.pos == 0 {
+	if s.base+s.pos == 0 {
 		// Test BOM only once, at the beginning of the file.
-		if strings.HasPrefix(s.input, "\uFEFF") {
+		if s.hasPrefix("\uFEFF") {
 			return s.emitSimple(BOM, "\uFEFF")
 		}
 	}
@@ -494,7 +706,11 @@ func (s *Scanner) Next() *Token {
 		if ok {
 			return s.emitToken(String, input[:n])
 		}
-		s.err = &Token{Error, "unclosed quotation mark", s.row, s.col}
+		if s.Mode&Recover != 0 {
+			return s.recoverString()
+		}
+		s.err = s.emptyToken(Error, "unclosed quotation mark")
+		s.reportToken(s.err, SeverityError, CodeUnclosedString, "unclosed quotation mark")
 		return s.err
 
 	case '#':
@@ -505,7 +721,7 @@ func (s *Scanner) Next() *Token {
 		return s.emitSimple(Delim, "#")
 
 	case '.':
-		if len(input) > 1 && isDigitByte(input[1]) {
+		if s.digitAt(1) {
 			return s.scanNumericToken()
 		}
 		return s.emitSimple(Delim, ".")
@@ -513,29 +729,23 @@ func (s *Scanner) Next() *Token {
 	case '@':
 		n := s.scanIdentLen(1)
 		if n > 0 {
-			return s.emitSimple(AtKeyword, input[:1+n])
+			return s.emitToken(AtKeyword, input[:1+n])
 		}
 		return s.emitSimple(Delim, "@")
 
 	case '+':
-		if len(input) > 1 && isDigitByte(input[1]) {
-			return s.scanNumericToken()
-		}
-		if len(input) > 2 && input[1] == '.' && isDigitByte(input[2]) {
+		if s.digitAt(1) || s.byteAt(1) == '.' && s.digitAt(2) {
 			return s.scanNumericToken()
 		}
 		return s.emitSimple(Delim, "+")
 
 	case '-':
 		// Negative number: -42, -.5
-		if len(input) > 1 && isDigitByte(input[1]) {
-			return s.scanNumericToken()
-		}
-		if len(input) > 2 && input[1] == '.' && isDigitByte(input[2]) {
+		if s.digitAt(1) || s.byteAt(1) == '.' && s.digitAt(2) {
 			return s.scanNumericToken()
 		}
 		// CDC: -->
-		if len(input) >= 3 && input[1] == '-' && input[2] == '>' {
+		if s.hasPrefix("-->") {
 			return s.emitSimple(CDC, "-->")
 		}
 		// Ident or custom property: -webkit, --my-var
@@ -546,12 +756,18 @@ func (s *Scanner) Next() *Token {
 		return s.emitSimple(Delim, "-")
 
 	case '/':
-		if len(input) > 1 && input[1] == '*' {
+		if s.byteAt(1) == '*' {
 			n, ok := s.scanCommentLen()
 			if ok {
 				return s.emitToken(Comment, input[:n])
 			}
-			s.err = &Token{Error, "unclosed comment", s.row, s.col}
+			if s.Mode&Recover != 0 {
+				token := s.emitValue(Comment, input, normalizeNewlines(input[2:]))
+				s.reportToken(token, SeverityError, CodeUnclosedComment, "unclosed comment")
+				return token
+			}
+			s.err = s.emptyToken(Error, "unclosed comment")
+			s.reportToken(s.err, SeverityError, CodeUnclosedComment, "unclosed comment")
 			return s.err
 		}
 		return s.emitSimple(Delim, "/")
@@ -564,7 +780,7 @@ func (s *Scanner) Next() *Token {
 				return s.scanIdentLikeToken(n)
 			}
 		}
-		return s.emitSimple(Delim, "\\")
+		return s.emitRune(Delim)
 
 	case '~':
 		return s.emitPrefixOrChar(Includes, "~=")
@@ -591,8 +807,8 @@ func (s *Scanner) Next() *Token {
 	}
 
 	// Unicode range: U+xxxx (uppercase hex only per spec).
-	if (c == 'U' || c == 'u') && len(input) > 2 && input[1] == '+' &&
-		(isUpperHex(input[2]) || input[2] == '?') {
+	if (c == 'U' || c == 'u') && s.byteAt(1) == '+' &&
+		(isUpperHex(s.byteAt(2)) || s.byteAt(2) == '?') {
 		n := s.scanUnicodeRangeLen()
 		if n > 0 {
 			return s.emitToken(UnicodeRange, input[:n])
@@ -608,38 +824,79 @@ func (s *Scanner) Next() *Token {
 	}
 
 	// Fallback: single-character delimiter.
-	r, width := utf8.DecodeRuneInString(input)
-	token := &Token{Delim, string(r), s.row, s.col}
-	s.col += width
-	s.pos += width
+	return s.emitRune(Delim)
+}
+
+// recoverString scans a string that is not properly closed in Recover
+// mode, producing a BadString token if it runs into a newline and a
+// String token if it runs to the end of the input.
+func (s *Scanner) recoverString() *Token {
+	input := s.input[s.pos:]
+	n, _, bad := s.scanString3Len()
+	if bad {
+		token := s.emitValue(BadString, input[:n], "")
+		s.reportToken(token, SeverityError, CodeNewlineInString, "unescaped newline in string")
+		return token
+	}
+	content := normalizeNewlines(input[1:n])
+	if trailing := len(content) - len(strings.TrimRight(content, "\\")); trailing%2 == 1 {
+		// A backslash at the end of the input is ignored.
+		content = content[:len(content)-1]
+	}
+	token := s.emitValue(String, input[:n], unbackslash(content, true))
+	s.reportToken(token, SeverityError, CodeUnclosedString, "unclosed quotation mark")
 	return token
 }
 
+// byteAt returns the byte at s.input[s.pos+offset], or 0 if that is past
+// the end of the input.
+func (s *Scanner) byteAt(offset int) byte {
+	if !s.more(s.pos + offset) {
+		return 0
+	}
+	return s.input[s.pos+offset]
+}
+
+// digitAt reports whether s.input[s.pos+offset] is a decimal digit.
+func (s *Scanner) digitAt(offset int) bool {
+	return isDigitByte(s.byteAt(offset))
+}
+
 // scanNumericToken scans a Number, Percentage, or Dimension token.
 func (s *Scanner) scanNumericToken() *Token {
 	input := s.input[s.pos:]
 	numLen := s.scanNumLen(0)
 	if numLen == 0 {
 		// Shouldn't happen if called correctly; emit as delimiter.
-		r, width := utf8.DecodeRuneInString(input)
-		token := &Token{Delim, string(r), s.row, s.col}
-		s.col += width
-		s.pos += width
-		return token
+		return s.emitRune(Delim)
 	}
 
 	// Check for percentage.
-	if s.pos+numLen < len(s.input) && s.input[s.pos+numLen] == '%' {
-		return s.emitToken(Percentage, input[:numLen+1])
+	if s.byteAt(numLen) == '%' {
+		token := s.emitToken(Percentage, input[:numLen+1])
+		token.setNumber(input[:numLen])
+		return token
 	}
 
 	// Check for dimension (number followed by ident unit).
 	identLen := s.scanIdentLen(numLen)
 	if identLen > 0 {
-		return s.emitToken(Dimension, input[:numLen+identLen])
+		unit := input[numLen : numLen+identLen]
+		token := s.emitToken(Dimension, input[:numLen+identLen])
+		if s.Mode&Syntax3 != 0 {
+			token.Unit = unescape3(unit, false)
+			token.Value = input[:numLen] + token.Unit
+			s.keepRaw(token)
+		} else {
+			token.Unit = unbackslash(normalizeNewlines(unit), false)
+		}
+		token.setNumber(input[:numLen])
+		return token
 	}
 
-	return s.emitToken(Number, input[:numLen])
+	token := s.emitToken(Number, input[:numLen])
+	token.setNumber(input[:numLen])
+	return token
 }
 
 // scanIdentLikeToken scans an Ident, Function, URI, Local, Format, or Tech
@@ -648,7 +905,7 @@ func (s *Scanner) scanIdentLikeToken(identLen int) *Token {
 	input := s.input[s.pos:]
 
 	// Check if followed by '(' → function or special function.
-	if s.pos+identLen < len(s.input) && s.input[s.pos+identLen] == '(' {
+	if s.byteAt(identLen) == '(' {
 		name := input[:identLen]
 		prefixLen := identLen + 1 // ident + opening paren
 
@@ -657,6 +914,9 @@ func (s *Scanner) scanIdentLikeToken(identLen int) *Token {
 			if n, ok := s.scanFuncBodyLen(prefixLen); ok {
 				return s.emitToken(URI, input[:n])
 			}
+			token := s.emitToken(Function, input[:prefixLen])
+			s.reportToken(token, SeverityWarning, CodeBadURL, "invalid url(), scanned as function")
+			return token
 		}
 		if identLen == 5 && startsWithFold(name, "local") {
 			if n, ok := s.scanFuncBodyLen(prefixLen); ok {
@@ -698,11 +958,56 @@ func (s *Scanner) updatePosition(text string) {
 	s.pos += len(text) // while col is a rune index, pos is a byte index
 }
 
+// startToken returns a Token of type t whose source text raw starts at the
+// current position.
+func (s *Scanner) startToken(t Type, raw string) *Token {
+	return &Token{
+		Type:   t,
+		Value:  raw,
+		Line:   s.row,
+		Column: s.col,
+		Offset: s.base + s.pos,
+		raw:    raw,
+	}
+}
+
+// endToken records the current position as the end of token.
+func (s *Scanner) endToken(token *Token) {
+	token.EndOffset = s.base + s.pos
+	token.EndLine = s.row
+	token.EndColumn = s.col
+}
+
+// emptyToken returns a Token of type t that covers no input, such as EOF
+// or Error.
+func (s *Scanner) emptyToken(t Type, v string) *Token {
+	token := s.startToken(t, "")
+	token.Value = v
+	s.endToken(token)
+	return token
+}
+
 // emitToken returns a Token for the string v and updates the scanner position.
 func (s *Scanner) emitToken(t Type, v string) *Token {
-	token := &Token{t, v, s.row, s.col}
+	token := s.startToken(t, v)
+	token.Value = normalizeNewlines(v)
 	s.updatePosition(v)
+	s.endToken(token)
 	token.normalize()
+	s.keepRaw(token)
+	s.checkRaw(token)
+	return token
+}
+
+// emitValue returns a Token of type t for the source text raw with the
+// given value, and updates the scanner position.
+func (s *Scanner) emitValue(t Type, raw, value string) *Token {
+	token := s.startToken(t, raw)
+	token.Value = value
+	s.updatePosition(raw)
+	s.endToken(token)
+	s.keepRaw(token)
+	s.checkRaw(token)
 	return token
 }
 
@@ -711,21 +1016,56 @@ func (s *Scanner) emitToken(t Type, v string) *Token {
 //
 // The string is known to have only ASCII characters and to not have a newline.
 func (s *Scanner) emitSimple(t Type, v string) *Token {
-	token := &Token{t, v, s.row, s.col}
+	token := s.startToken(t, v)
 	s.col += len(v)
 	s.pos += len(v)
+	s.endToken(token)
 	token.normalize()
+	s.keepRaw(token)
 	return token
 }
 
+// emitRune returns a Token of type t for the single rune at the current
+// position.
+func (s *Scanner) emitRune(t Type) *Token {
+	r, width := s.decodeRune(s.pos)
+	token := s.startToken(t, s.input[s.pos:s.pos+width])
+	token.Value = string(r)
+	s.col++
+	s.pos += width
+	s.endToken(token)
+	s.keepRaw(token)
+	s.checkRaw(token)
+	return token
+}
+
+// keepRaw remembers the scanned type and value of token in Lossless mode,
+// so that Emit can tell whether the token has been modified.
+func (s *Scanner) keepRaw(token *Token) {
+	if s.Mode&Lossless != 0 {
+		token.lossless = true
+		token.rawType = token.Type
+		token.rawValue = token.Value
+	}
+}
+
 // emitPrefixOrChar returns a Token for type t if the current position
 // matches the given prefix. Otherwise it returns a Char token using the
 // first character from the prefix.
 //
 // The prefix is known to have only ASCII characters and to not have a newline.
 func (s *Scanner) emitPrefixOrChar(t Type, prefix string) *Token {
-	if strings.HasPrefix(s.input[s.pos:], prefix) {
+	if s.hasPrefix(prefix) {
 		return s.emitSimple(t, prefix)
 	}
 	return s.emitSimple(Delim, string(prefix[0]))
 }
+
+// normalizeNewlines replaces CRLF pairs with a single LF. Only allocates
+// if the text contains \r.
+func normalizeNewlines(text string) string {
+	if strings.IndexByte(text, '\r') < 0 {
+		return text
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
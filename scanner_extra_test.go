@@ -2,8 +2,12 @@ package css
 
 import (
 	"bytes"
+	"io"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 // ---------------------------------------------------------------------------
@@ -331,6 +335,75 @@ func TestDimensionUnits(t *testing.T) {
 	}
 }
 
+func TestLowerUnit(t *testing.T) {
+	for input, want := range map[string]string{
+		"1PX":      "px",
+		"2kHz":     "khz",
+		"3\\45 m":  "em",
+		"4\u00C9X": "\u00C9x",
+		"5":        "",
+	} {
+		if got := New(input).Next().LowerUnit(); got != want {
+			t.Errorf("For %q: expected %q, got %q", input, want, got)
+		}
+	}
+}
+
+func TestNumericValues(t *testing.T) {
+	for _, test := range []struct {
+		input   string
+		typ     Type
+		num     float64
+		integer bool
+		signed  bool
+		unit    string
+	}{
+		{"42", Number, 42, true, false, ""},
+		{"-7", Number, -7, true, true, ""},
+		{"+.5", Number, 0.5, false, true, ""},
+		{"3.0", Number, 3, false, false, ""},
+		{"50%", Percentage, 50, true, false, ""},
+		{"-12.5%", Percentage, -12.5, false, true, ""},
+		{"10px", Dimension, 10, true, false, "px"},
+		{"1.5EM", Dimension, 1.5, false, false, "EM"},
+		{`+2\70 x`, Dimension, 2, true, true, "px"},
+		{"1e3", Number, 1000, false, false, ""},
+		{"1.5E-2px", Dimension, 0.015, false, false, "px"},
+		{"2e+1%", Percentage, 20, false, false, ""},
+		{"1em", Dimension, 1, true, false, "em"},
+		{"1e-x", Dimension, 1, true, false, "e-x"},
+		{"1E+", Dimension, 1, true, false, "E"},
+	} {
+		for _, mode := range []Mode{0, Syntax3} {
+			s := New(test.input)
+			s.Mode = mode
+			tok := s.Next()
+			if tok.Type != test.typ || tok.Num != test.num || tok.Integer != test.integer ||
+				tok.Signed != test.signed || tok.Unit != test.unit {
+				t.Errorf("For %q: got %s %v integer=%v signed=%v unit=%q",
+					test.input, tok.Type, tok.Num, tok.Integer, tok.Signed, tok.Unit)
+			}
+		}
+	}
+}
+
+func TestExponentUnitEmit(t *testing.T) {
+	for _, input := range []string{`1\65 3`, `1\45-3`, `1\65 2px`} {
+		tok := New(input).Next()
+		if tok.Type != Dimension {
+			t.Fatalf("For %q: expected a Dimension, got %v", input, tok)
+		}
+		var buf bytes.Buffer
+		if err := tok.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+		again, err := parse(buf.String())
+		if err != nil || len(again) != 1 || again[0].Type != Dimension || again[0].Value != tok.Value {
+			t.Errorf("For %q: emitted %q scans as %v", input, buf.String(), again)
+		}
+	}
+}
+
 func TestBOMHandling(t *testing.T) {
 	// BOM at start
 	tokens, err := parse("\uFEFF body { }")
@@ -568,3 +641,387 @@ func BenchmarkNewlineNormalization(b *testing.B) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Streaming input
+// ---------------------------------------------------------------------------
+
+var readerInputs = []string{
+	"",
+	"\uFEFF body { }",
+	benchmarkCSS,
+	"a\r\nb\r\n\r\nc",
+	"\\41\r\nx",
+	"'a\\\r\nb'",
+	"/* comment\r\n */ url( 'x' ) url(a b) local(Foo) u+00?? U+0100-01FF",
+	"<!-- --> ~= |= ^= $= *= <! -- -",
+	"-42px +.5em 4.2% .5 1.",
+	"日本語 \"ü\" #ß é(",
+	`url('http://`,
+	"moo /* unclosed comment",
+	`"never closed`,
+}
+
+func TestNewReader(t *testing.T) {
+	readers := map[string]func(string) io.Reader{
+		"plain":   func(s string) io.Reader { return strings.NewReader(s) },
+		"onebyte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+		"half":    func(s string) io.Reader { return iotest.HalfReader(strings.NewReader(s)) },
+		"dataerr": func(s string) io.Reader { return iotest.DataErrReader(strings.NewReader(s)) },
+	}
+	for _, input := range readerInputs {
+		var want []Token
+		s := New(input)
+		for {
+			tok := s.Next()
+			want = append(want, *tok)
+			if tok.Type == EOF || tok.Type == Error {
+				break
+			}
+		}
+		for name, mk := range readers {
+			var got []Token
+			s := NewReader(mk(input))
+			for {
+				tok := s.Next()
+				got = append(got, *tok)
+				if tok.Type == EOF || tok.Type == Error {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s reader, input %q:\nexpected %v\ngot      %v", name, input, want, got)
+			}
+		}
+	}
+}
+
+func TestNewReaderLargeInput(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 5000; i++ {
+		sb.WriteString(".class-")
+		sb.WriteString(strings.Repeat("a", 50))
+		sb.WriteString(" {\r\n  color: #fff; /* ")
+		sb.WriteString(strings.Repeat("*", i%100))
+		sb.WriteString(" */ font-size: 12px; }\n")
+	}
+	input := sb.String()
+	a := New(input)
+	b := NewReader(strings.NewReader(input))
+	for {
+		ta, tb := a.Next(), b.Next()
+		if *ta != *tb {
+			t.Fatalf("mismatch: expected %v, got %v", ta, tb)
+		}
+		if ta.Type == EOF {
+			break
+		}
+	}
+}
+
+func TestNewReaderHugeToken(t *testing.T) {
+	const size = 4 << 20
+	for _, input := range []string{
+		"a /*" + strings.Repeat("x", size) + "*/ b",
+		"a '" + strings.Repeat("x", size) + "' b",
+		"a " + strings.Repeat("x", size) + " b",
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		s := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+		var got []Token
+		for {
+			tok := s.Next()
+			if tok.Type == EOF || tok.Type == Error {
+				break
+			}
+			got = append(got, *tok)
+		}
+		runtime.ReadMemStats(&after)
+		if len(got) != 5 || got[2].EndOffset-got[2].Offset != len(input)-4 {
+			t.Fatalf("%.10q: expected 5 tokens with the huge one in the middle, got %d", input, len(got))
+		}
+		// Rescanning the token after every read would allocate a
+		// multiple of size for each refill.
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 16*size {
+			t.Errorf("%.10q: scanning allocated %d bytes", input, alloc)
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	s := NewReader(iotest.TimeoutReader(strings.NewReader(strings.Repeat("a ", readChunkSize))))
+	for {
+		tok := s.Next()
+		if tok.Type == EOF {
+			t.Fatal("expected read error, got EOF")
+		}
+		if tok.Type == Error {
+			if tok.Value != iotest.ErrTimeout.Error() {
+				t.Fatalf("unexpected error value %q", tok.Value)
+			}
+			break
+		}
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	s := New("a\r\n  b\r\n\\41\r\nc")
+	for _, want := range []struct {
+		ty     Type
+		value  string
+		line   int
+		column int
+	}{
+		{Ident, "a", 1, 1},
+		{S, "\n  ", 1, 2},
+		{Ident, "b", 2, 3},
+		{S, "\n", 2, 4},
+		{Ident, "Ac", 3, 1},
+	} {
+		tok := s.Next()
+		if tok.Type != want.ty || tok.Value != want.value || tok.Line != want.line || tok.Column != want.column {
+			t.Fatalf("expected %s %q at %d:%d, got %v", want.ty, want.value, want.line, want.column, tok)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Positions and raw text
+// ---------------------------------------------------------------------------
+
+func TestTokenPositions(t *testing.T) {
+	for _, input := range readerInputs {
+		s := New(input)
+		offset, line, column := 0, 1, 1
+		for {
+			tok := s.Next()
+			if tok.Offset != offset || tok.Line != line || tok.Column != column {
+				t.Fatalf("For %q: token %v starts at %d (%d:%d), expected %d (%d:%d)",
+					input, tok, tok.Offset, tok.Line, tok.Column, offset, line, column)
+			}
+			if tok.Type == EOF || tok.Type == Error {
+				if tok.EndOffset != tok.Offset || tok.Raw() != "" {
+					t.Fatalf("For %q: %v should not cover any input", input, tok)
+				}
+				break
+			}
+			if tok.Raw() != input[tok.Offset:tok.EndOffset] {
+				t.Fatalf("For %q: raw text %q of %v does not match input[%d:%d]",
+					input, tok.Raw(), tok, tok.Offset, tok.EndOffset)
+			}
+			offset, line, column = tok.EndOffset, tok.EndLine, tok.EndColumn
+		}
+	}
+}
+
+func TestTokenEndPosition(t *testing.T) {
+	s := New("a { content: 'x\\\ny' }\n/* é\n */ b")
+	for _, want := range []struct {
+		raw                           string
+		offset, line, column          int
+		endOffset, endLine, endColumn int
+	}{
+		{"a", 0, 1, 1, 1, 1, 2},
+		{" ", 1, 1, 2, 2, 1, 3},
+		{"{", 2, 1, 3, 3, 1, 4},
+		{" ", 3, 1, 4, 4, 1, 5},
+		{"content", 4, 1, 5, 11, 1, 12},
+		{":", 11, 1, 12, 12, 1, 13},
+		{" ", 12, 1, 13, 13, 1, 14},
+		{"'x\\\ny'", 13, 1, 14, 19, 2, 3},
+		{" ", 19, 2, 3, 20, 2, 4},
+		{"}", 20, 2, 4, 21, 2, 5},
+		{"\n", 21, 2, 5, 22, 3, 1},
+		{"/* é\n */", 22, 3, 1, 31, 4, 4},
+		{" ", 31, 4, 4, 32, 4, 5},
+		{"b", 32, 4, 5, 33, 4, 6},
+	} {
+		tok := s.Next()
+		got := []int{tok.Offset, tok.Line, tok.Column, tok.EndOffset, tok.EndLine, tok.EndColumn}
+		expected := []int{want.offset, want.line, want.column, want.endOffset, want.endLine, want.endColumn}
+		if tok.Raw() != want.raw || !reflect.DeepEqual(got, expected) {
+			t.Fatalf("For %q: expected positions %v, got %q %v", want.raw, expected, tok.Raw(), got)
+		}
+	}
+}
+
+func TestNonASCIIDelimColumns(t *testing.T) {
+	// Non-ASCII characters start identifiers, so the scanner does not
+	// produce such delimiters by itself; emit them directly.
+	s := New("\u00a7\u2192b")
+	for _, want := range []struct {
+		typ               Type
+		value             string
+		column, endColumn int
+		offset, endOffset int
+	}{
+		{Delim, "\u00a7", 1, 2, 0, 2},
+		{Delim, "\u2192", 2, 3, 2, 5},
+		{Ident, "b", 3, 4, 5, 6},
+	} {
+		var tok *Token
+		if want.typ == Delim {
+			tok = s.emitRune(Delim)
+		} else {
+			tok = s.Next()
+		}
+		got := []int{tok.Column, tok.EndColumn, tok.Offset, tok.EndOffset}
+		expected := []int{want.column, want.endColumn, want.offset, want.endOffset}
+		if tok.Type != want.typ || tok.Value != want.value || !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Expected %v %q at %v, got %v %q at %v", want.typ, want.value, expected, tok.Type, tok.Value, got)
+		}
+	}
+}
+
+func TestLosslessEmit(t *testing.T) {
+	inputs := []string{
+		`a{background:url( "x.png" );content:"\26 B";font:12\70 x}`,
+		"#\\66oo .b\\61r local( Font ) format(\"woff2\") -\\31 x",
+	}
+	// The last entries of readerInputs do not tokenize without errors.
+	inputs = append(inputs, readerInputs[:len(readerInputs)-3]...)
+	for _, input := range inputs {
+		s := New(input)
+		s.Mode = Lossless
+		var buf bytes.Buffer
+		for {
+			tok := s.Next()
+			if tok.Type == EOF {
+				break
+			}
+			if err := tok.Emit(&buf); err != nil {
+				t.Fatalf("For %q: emit failed: %v", input, err)
+			}
+		}
+		if buf.String() != input {
+			t.Fatalf("Lossless round trip failed:\n  input:   %q\n  emitted: %q", input, buf.String())
+		}
+	}
+}
+
+func TestLosslessModifiedToken(t *testing.T) {
+	s := New("url( \"x.png\" )")
+	s.Mode = Lossless
+	tok := s.Next()
+	tok.Value = "y.png"
+	var buf bytes.Buffer
+	if err := tok.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "url('y.png')" {
+		t.Fatalf("Expected canonical form for modified token, got %q", buf.String())
+	}
+	// Without Lossless mode, the canonical form is always emitted.
+	tok = New("url( \"x.png\" )").Next()
+	buf.Reset()
+	if err := tok.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "url('x.png')" {
+		t.Fatalf("Expected canonical form, got %q", buf.String())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Error recovery
+// ---------------------------------------------------------------------------
+
+func TestRecover(t *testing.T) {
+	for _, test := range []struct {
+		input       string
+		tokens      []Token
+		diagnostics []string
+	}{
+		{`"never closed`, []Token{T(String, "never closed")}, []string{"unclosed quotation mark"}},
+		{`'ends\`, []Token{T(String, "ends")}, []string{"unclosed quotation mark", "lone backslash"}},
+		{"a { content: 'x\n; color: red }", []Token{
+			T(Ident, "a"),
+			T(S, " "),
+			T(Delim, "{"),
+			T(S, " "),
+			T(Ident, "content"),
+			T(Delim, ":"),
+			T(S, " "),
+			T(BadString, ""),
+			T(S, "\n"),
+			T(Delim, ";"),
+			T(S, " "),
+			T(Ident, "color"),
+			T(Delim, ":"),
+			T(S, " "),
+			T(Ident, "red"),
+			T(S, " "),
+			T(Delim, "}"),
+		}, []string{"unescaped newline in string"}},
+		{"moo /* unclosed\r\ncomment", []Token{
+			T(Ident, "moo"),
+			T(S, " "),
+			T(Comment, " unclosed\ncomment"),
+		}, []string{"unclosed comment"}},
+		{"url('http://", []Token{
+			T(Function, "url"),
+			T(String, "http://"),
+		}, []string{"invalid url(), scanned as function", "unclosed quotation mark"}},
+		{`"a\	b" 'c`, []Token{
+			T(String, "a\tb"),
+			T(S, " "),
+			T(String, "c"),
+		}, []string{"unclosed quotation mark"}},
+	} {
+		for _, s := range []*Scanner{
+			New(test.input),
+			NewReader(iotest.OneByteReader(strings.NewReader(test.input))),
+		} {
+			s.Mode = Recover
+			var tokens []Token
+			for {
+				tok := s.Next()
+				if tok.Type == Error {
+					t.Fatalf("For %q: unexpected error %v", test.input, tok)
+				}
+				if tok.Type == EOF {
+					break
+				}
+				tokens = append(tokens, Token{Type: tok.Type, Value: tok.Value})
+			}
+			if !reflect.DeepEqual(tokens, test.tokens) {
+				t.Fatalf("For %q: expected\n%v\ngot\n%v", test.input, test.tokens, tokens)
+			}
+			var diagnostics []string
+			for _, d := range s.Diagnostics() {
+				diagnostics = append(diagnostics, d.Message)
+			}
+			if !reflect.DeepEqual(diagnostics, test.diagnostics) {
+				t.Fatalf("For %q: expected diagnostics %q, got %q", test.input, test.diagnostics, diagnostics)
+			}
+		}
+	}
+}
+
+func TestRecoverPosition(t *testing.T) {
+	s := New("a\n  'b\nc")
+	s.Mode = Recover | Lossless
+	var buf bytes.Buffer
+	for {
+		tok := s.Next()
+		if tok.Type == EOF {
+			break
+		}
+		if err := tok.Emit(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.String() != "a\n  'b\nc" {
+		t.Fatalf("Lossless round trip failed: %q", buf.String())
+	}
+	d := s.Diagnostics()
+	if len(d) != 1 || d[0].Line != 2 || d[0].Column != 3 || d[0].Offset != 4 ||
+		d[0].EndLine != 2 || d[0].EndColumn != 5 || d[0].EndOffset != 6 ||
+		d[0].Severity != SeverityError || d[0].Code != CodeNewlineInString {
+		t.Fatalf("Unexpected diagnostics %v", d)
+	}
+	if d[0].String() != "line 2, column 3: error: unescaped newline in string" {
+		t.Fatalf("Unexpected string representation %q", d[0].String())
+	}
+}
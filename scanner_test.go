@@ -14,7 +14,7 @@ import (
 )
 
 func T(ty Type, v string) Token {
-	return Token{ty, v, 0, 0}
+	return Token{Type: ty, Value: v}
 }
 
 func parse(input string) ([]Token, error) {
@@ -32,9 +32,7 @@ func parse(input string) ([]Token, error) {
 		if tok.Type == EOF {
 			break
 		}
-		tok.Line = 0
-		tok.Column = 0
-		tokens = append(tokens, *tok)
+		tokens = append(tokens, Token{Type: tok.Type, Value: tok.Value})
 	}
 	return tokens, nil
 }
@@ -57,6 +55,11 @@ func TestSuccessfulScan(t *testing.T) {
 		{"42px", []Token{T(Dimension, "42px")}},
 		{"url('http://www.google.com/')", []Token{T(URI, "http://www.google.com/")}},
 		{"U+0042", []Token{T(UnicodeRange, "U+0042")}},
+		// Lowercase hex digits do not make a UnicodeRange token.
+		{"u+00ff", []Token{T(Ident, "u"), T(Dimension, "+00ff")}},
+		{"U+00FF-01ab", []Token{T(Ident, "U"), T(Dimension, "+00FF-01ab")}},
+		{"u+e0-ff", []Token{T(Ident, "u"), T(Delim, "+"), T(Ident, "e0-ff")}},
+		{"U+1e3", []Token{T(Ident, "U"), T(Number, "+1e3")}},
 		{"<!--", []Token{T(CDO, "")}},
 		{"-->", []Token{T(CDC, "")}},
 		{"   \n   \t   \n", []Token{T(S, "   \n   \t   \n")}},
@@ -407,7 +410,7 @@ func TestCoverage(t *testing.T) {
 	if unbackslash("\\\rx", true) != "x" {
 		t.Fatal("Incorrect handling of backslash-CR-(not LF)")
 	}
-	tok := &Token{Error, "", 0, 0}
+	tok := &Token{Type: Error}
 	if tok.Emit(io.Discard) == nil {
 		t.Fatal("Can emit an error???")
 	}
@@ -0,0 +1,628 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SelectorList is a comma separated list of complex selectors.
+type SelectorList []*ComplexSelector
+
+// ComplexSelector is a sequence of compound selectors joined by
+// combinators, such as "ul > li.item a".
+type ComplexSelector struct {
+	Span
+	Compounds []*CompoundSelector
+}
+
+// Combinator is the relation between two compound selectors.
+type Combinator int
+
+const (
+	// NoCombinator is the combinator of the first compound selector of a
+	// complex selector.
+	NoCombinator Combinator = iota
+	// Descendant is the whitespace combinator.
+	Descendant
+	// Child is the > combinator.
+	Child
+	// NextSibling is the + combinator.
+	NextSibling
+	// SubsequentSibling is the ~ combinator.
+	SubsequentSibling
+)
+
+// String returns the CSS representation of the combinator.
+func (c Combinator) String() string {
+	return [...]string{"", " ", ">", "+", "~"}[c]
+}
+
+// CompoundSelector is a sequence of simple selectors that are not
+// separated by a combinator, such as "li.item:hover".
+type CompoundSelector struct {
+	Span
+	// Combinator is the combinator between the previous compound selector
+	// and this one. It is NoCombinator for the first compound selector,
+	// except in the relative selectors of :has(), where it is Descendant
+	// if no other combinator is given.
+	Combinator Combinator
+	// Selectors are the simple selectors. A type or universal selector is
+	// always the first one.
+	Selectors []*SimpleSelector
+}
+
+// SelectorKind identifies the kind of a SimpleSelector.
+type SelectorKind int
+
+const (
+	// TypeSelector is an element name such as div.
+	TypeSelector SelectorKind = iota
+	// UniversalSelector is *.
+	UniversalSelector
+	// IDSelector is #name.
+	IDSelector
+	// ClassSelector is .name.
+	ClassSelector
+	// AttributeSelector is [name], [name=value] and the like.
+	AttributeSelector
+	// PseudoClassSelector is :name or :name(arguments).
+	PseudoClassSelector
+	// PseudoElementSelector is ::name or ::name(arguments), and the
+	// legacy :before, :after, :first-line and :first-letter.
+	PseudoElementSelector
+	// NestingSelector is &.
+	NestingSelector
+)
+
+// SimpleSelector is a single simple selector.
+type SimpleSelector struct {
+	Span
+	Kind SelectorKind
+	// Namespace is the namespace prefix of a type, universal or attribute
+	// selector if HasNamespace is set: "*" for any namespace and "" for
+	// no namespace, as in |name.
+	Namespace    string
+	HasNamespace bool
+	// Name is the element name, id, class or attribute name, or the name
+	// of a pseudo-class or pseudo-element. Names of pseudo-classes and
+	// pseudo-elements are lowercased.
+	Name string
+	// Operator is the operator of an attribute selector: "=", "~=", "|=",
+	// "^=", "$=" or "*=", or "" if the selector only tests for presence.
+	// Value is the value it compares with, and Modifier is "i" or "s" if
+	// the selector has a case-sensitivity modifier.
+	Operator string
+	Value    string
+	Modifier string
+	// Function is set for a functional pseudo-class or pseudo-element, with
+	// the arguments in Arguments.
+	Function  bool
+	Arguments []ComponentValue
+	// A and B are the values of the An+B argument of :nth-child(),
+	// :nth-last-child(), :nth-of-type() and :nth-last-of-type().
+	A, B int
+	// Selectors is the parsed selector argument of :is(), :where(),
+	// :not(), :has(), :host(), :host-context(), ::slotted() and ::cue(),
+	// and the selector list after "of" in :nth-child() and
+	// :nth-last-child().
+	Selectors SelectorList
+}
+
+// legacyPseudoElements can be written with a single colon.
+var legacyPseudoElements = map[string]bool{
+	"before":       true,
+	"after":        true,
+	"first-line":   true,
+	"first-letter": true,
+}
+
+// ParseSelectorList parses input as a selector list.
+func ParseSelectorList(input string) (SelectorList, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return nil, &diagnostics[i]
+		}
+	}
+	return ParseSelectorValues(list)
+}
+
+// ParseSelectorValues parses a selector list from component values such
+// as the prelude of a style rule.
+func ParseSelectorValues(list []ComponentValue) (SelectorList, error) {
+	return parseSelectorList(list, false, false)
+}
+
+// Selectors parses the prelude of a style rule as a selector list. The
+// prelude of a nested rule can be relative to its parent and should be
+// resolved with ResolveSelector and parsed with ParseSelectorValues.
+func (r *Rule) Selectors() (SelectorList, error) {
+	return ParseSelectorValues(r.Prelude)
+}
+
+// parseSelectorList parses the comma separated list of selectors in list.
+// In a forgiving list, invalid selectors are dropped.
+func parseSelectorList(list []ComponentValue, relative, forgiving bool) (SelectorList, error) {
+	var res SelectorList
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !list[i].IsDelim(',') {
+			continue
+		}
+		item := list[start:i]
+		if skipSpace(item, 0) == len(item) {
+			if forgiving {
+				start = i + 1
+				continue
+			}
+			switch {
+			case i < len(list):
+				return nil, syntaxError(list[i].Span(), CodeInvalidSelector, "empty selector before ','")
+			case i > 0:
+				return nil, syntaxError(list[i-1].Span(), CodeInvalidSelector, "empty selector after ','")
+			}
+			return nil, &Diagnostic{Severity: SeverityError, Code: CodeInvalidSelector, Message: "empty selector"}
+		}
+		sel, err := parseComplexSelector(item, relative)
+		if err != nil && !forgiving {
+			return nil, err
+		}
+		if err == nil {
+			res = append(res, sel)
+		}
+		start = i + 1
+	}
+	return res, nil
+}
+
+// parseComplexSelector parses a single complex selector from list, which
+// is not empty. A relative selector may start with a combinator.
+func parseComplexSelector(list []ComponentValue, relative bool) (*ComplexSelector, error) {
+	for len(list) > 0 && list[0].IsWhitespace() {
+		list = list[1:]
+	}
+	list = trimWhitespace(list)
+	sel := &ComplexSelector{}
+	combinator := NoCombinator
+	if relative {
+		combinator = Descendant
+	}
+	pos := 0
+	if relative {
+		if c := combinatorOf(&list[0]); c != NoCombinator {
+			combinator = c
+			pos = skipSpace(list, 1)
+		}
+	}
+	for {
+		compound, next, err := parseCompoundSelector(list, pos)
+		if err != nil {
+			return nil, err
+		}
+		compound.Combinator = combinator
+		sel.Compounds = append(sel.Compounds, compound)
+		if next == len(list) {
+			break
+		}
+		pos = skipSpace(list, next)
+		combinator = Descendant
+		if c := combinatorOf(&list[pos]); c != NoCombinator {
+			combinator = c
+			pos = skipSpace(list, pos+1)
+		}
+		if pos == len(list) {
+			return nil, syntaxError(list[pos-1].Span(), CodeInvalidSelector, "missing selector after combinator")
+		}
+	}
+	sel.Span = spanOf(list[0].Token, list[len(list)-1].lastToken())
+	return sel, nil
+}
+
+// combinatorOf returns the combinator c stands for, or NoCombinator.
+func combinatorOf(c *ComponentValue) Combinator {
+	switch {
+	case c.IsDelim('>'):
+		return Child
+	case c.IsDelim('+'):
+		return NextSibling
+	case c.IsDelim('~'):
+		return SubsequentSibling
+	}
+	return NoCombinator
+}
+
+// skipSpace returns the index of the first non-whitespace value in list at
+// or after pos.
+func skipSpace(list []ComponentValue, pos int) int {
+	for pos < len(list) && list[pos].IsWhitespace() {
+		pos++
+	}
+	return pos
+}
+
+// describe returns a short description of c for error messages.
+func describe(c *ComponentValue) string {
+	switch c.Kind {
+	case FunctionBlock:
+		return "function " + c.Name() + "()"
+	case SimpleBlock:
+		return c.Token.Value + " block"
+	}
+	if c.Token.Type == Delim {
+		return "'" + c.Token.Value + "'"
+	}
+	return strings.ToLower(c.Token.Type.String())
+}
+
+// isSelectorEnd reports whether c ends a compound selector.
+func isSelectorEnd(c *ComponentValue) bool {
+	return c.IsWhitespace() || combinatorOf(c) != NoCombinator
+}
+
+// parseCompoundSelector parses the compound selector that starts at
+// list[pos] and returns it together with the index after it.
+func parseCompoundSelector(list []ComponentValue, pos int) (*CompoundSelector, int, error) {
+	compound := &CompoundSelector{}
+	start := pos
+	if s, next := parseTypeSelector(list, pos); s != nil {
+		compound.Selectors = append(compound.Selectors, s)
+		pos = next
+	}
+	for pos < len(list) && !isSelectorEnd(&list[pos]) {
+		c := &list[pos]
+		s := &SimpleSelector{}
+		first := c.Token
+		switch {
+		case isToken(c, Hash) && isIdentValue(c.Token.Value):
+			s.Kind, s.Name = IDSelector, c.Token.Value
+			pos++
+		case c.IsDelim('.') && pos+1 < len(list) && isToken(&list[pos+1], Ident):
+			s.Kind, s.Name = ClassSelector, list[pos+1].Token.Value
+			pos += 2
+		case c.IsBlock('['):
+			if err := parseAttributeSelector(c, s); err != nil {
+				return nil, 0, err
+			}
+			pos++
+		case c.IsDelim(':'):
+			next, err := parsePseudo(list, pos, s)
+			if err != nil {
+				return nil, 0, err
+			}
+			pos = next
+		case c.IsDelim('&'):
+			s.Kind = NestingSelector
+			pos++
+		default:
+			msg := "unexpected " + describe(c)
+			if pos == start {
+				msg = "expected selector, found " + describe(c)
+			}
+			return nil, 0, syntaxError(c.Span(), CodeInvalidSelector, msg)
+		}
+		s.Span = spanOf(first, list[pos-1].lastToken())
+		compound.Selectors = append(compound.Selectors, s)
+	}
+	if pos == start {
+		if pos < len(list) {
+			return nil, 0, syntaxError(list[pos].Span(), CodeInvalidSelector, "expected selector, found "+describe(&list[pos]))
+		}
+		return nil, 0, syntaxError(list[pos-1].Span(), CodeInvalidSelector, "expected selector")
+	}
+	compound.Span = spanOf(list[start].Token, list[pos-1].lastToken())
+	return compound, pos, nil
+}
+
+// parseTypeSelector parses an optional type or universal selector with
+// an optional namespace prefix at list[pos].
+func parseTypeSelector(list []ComponentValue, pos int) (*SimpleSelector, int) {
+	name := func(i int) (string, bool) {
+		if i >= len(list) {
+			return "", false
+		}
+		if isToken(&list[i], Ident) {
+			return list[i].Token.Value, true
+		}
+		if list[i].IsDelim('*') {
+			return "*", true
+		}
+		return "", false
+	}
+	s := &SimpleSelector{}
+	end := pos
+	if first, ok := name(pos); ok {
+		if pos+1 < len(list) && list[pos+1].IsDelim('|') {
+			if local, ok := name(pos + 2); ok {
+				s.Namespace, s.HasNamespace, s.Name = first, true, local
+				end = pos + 3
+			}
+		}
+		if end == pos {
+			s.Name = first
+			end = pos + 1
+		}
+	} else if pos < len(list) && list[pos].IsDelim('|') {
+		if local, ok := name(pos + 1); ok {
+			s.HasNamespace, s.Name = true, local
+			end = pos + 2
+		}
+	}
+	if end == pos {
+		return nil, pos
+	}
+	s.Kind = TypeSelector
+	if s.Name == "*" {
+		s.Kind, s.Name = UniversalSelector, ""
+	}
+	s.Span = spanOf(list[pos].Token, list[end-1].Token)
+	return s, end
+}
+
+// parseAttributeSelector parses the [] block c into s.
+func parseAttributeSelector(c *ComponentValue, s *SimpleSelector) error {
+	s.Kind = AttributeSelector
+	list := c.Values
+	invalid := func(i int) error {
+		if i < len(list) {
+			return syntaxError(list[i].Span(), CodeInvalidSelector, "unexpected "+describe(&list[i])+" in attribute selector")
+		}
+		return syntaxError(c.Span(), CodeInvalidSelector, "invalid attribute selector")
+	}
+	pos := skipSpace(list, 0)
+	switch {
+	case pos+2 < len(list) && (isToken(&list[pos], Ident) || list[pos].IsDelim('*')) &&
+		list[pos+1].IsDelim('|') && isToken(&list[pos+2], Ident):
+		s.Namespace, s.HasNamespace, s.Name = list[pos].Token.Value, true, list[pos+2].Token.Value
+		pos += 3
+	case pos+1 < len(list) && list[pos].IsDelim('|') && isToken(&list[pos+1], Ident):
+		s.HasNamespace, s.Name = true, list[pos+1].Token.Value
+		pos += 2
+	case pos < len(list) && isToken(&list[pos], Ident):
+		s.Name = list[pos].Token.Value
+		pos++
+	default:
+		return invalid(pos)
+	}
+	pos = skipSpace(list, pos)
+	if pos == len(list) {
+		return nil
+	}
+	switch t := list[pos].Token; {
+	case list[pos].IsDelim('='):
+		s.Operator = "="
+		pos++
+	case t.Type == Includes:
+		s.Operator = "~="
+		pos++
+	case t.Type == DashMatch:
+		s.Operator = "|="
+		pos++
+	case t.Type == PrefixMatch:
+		s.Operator = "^="
+		pos++
+	case t.Type == SuffixMatch:
+		s.Operator = "$="
+		pos++
+	case t.Type == SubstringMatch:
+		s.Operator = "*="
+		pos++
+	case t.Type == Delim && strings.Contains("~|^$*", t.Value) && pos+1 < len(list) && list[pos+1].IsDelim('='):
+		// Syntax3 mode has no match tokens.
+		s.Operator = t.Value + "="
+		pos += 2
+	default:
+		return invalid(pos)
+	}
+	pos = skipSpace(list, pos)
+	if pos == len(list) || !isToken(&list[pos], Ident) && !isToken(&list[pos], String) {
+		return invalid(pos)
+	}
+	s.Value = list[pos].Token.Value
+	pos = skipSpace(list, pos+1)
+	if pos < len(list) && isToken(&list[pos], Ident) {
+		switch m := strings.ToLower(list[pos].Token.Value); m {
+		case "i", "s":
+			s.Modifier = m
+			pos = skipSpace(list, pos+1)
+		}
+	}
+	if pos < len(list) {
+		return invalid(pos)
+	}
+	return nil
+}
+
+// parsePseudo parses the pseudo-class or pseudo-element that starts with
+// the colon at list[pos] into s and returns the index after it.
+func parsePseudo(list []ComponentValue, pos int, s *SimpleSelector) (int, error) {
+	colon := &list[pos]
+	s.Kind = PseudoClassSelector
+	pos++
+	if pos < len(list) && list[pos].IsDelim(':') {
+		s.Kind = PseudoElementSelector
+		pos++
+	}
+	if pos == len(list) {
+		return 0, syntaxError(colon.Span(), CodeInvalidSelector, "missing pseudo-class name")
+	}
+	c := &list[pos]
+	switch {
+	case isToken(c, Ident):
+		s.Name = strings.ToLower(c.Token.Value)
+		if s.Kind == PseudoClassSelector && legacyPseudoElements[s.Name] {
+			s.Kind = PseudoElementSelector
+		}
+	case c.Kind == FunctionBlock:
+		s.Name = strings.ToLower(c.Name())
+		s.Function = true
+		s.Arguments = c.Values
+		var err error
+		if s.Selectors, err = parsePseudoArguments(s.Name, c.Values); err != nil {
+			return 0, err
+		}
+		if s.Kind == PseudoClassSelector && nthPseudoClasses[s.Name] {
+			anb, _ := splitOf(s.Name, c.Values)
+			if s.A, s.B, err = ParseAnPlusBValues(anb); err != nil {
+				if len(anb) == 0 {
+					err = syntaxError(c.Span(), CodeInvalidAnPlusB, "missing An+B in :"+s.Name+"()")
+				}
+				return 0, err
+			}
+		}
+	default:
+		return 0, syntaxError(c.Span(), CodeInvalidSelector, "expected pseudo-class name, found "+describe(c))
+	}
+	return pos + 1, nil
+}
+
+// parsePseudoArguments parses the selector argument of the functional
+// pseudo-class or pseudo-element name.
+func parsePseudoArguments(name string, args []ComponentValue) (SelectorList, error) {
+	switch name {
+	case "is", "where", "matches", "-webkit-any", "-moz-any":
+		return parseSelectorList(args, false, true)
+	case "not", "host", "host-context", "slotted", "cue":
+		return parseSelectorList(args, false, false)
+	case "has":
+		return parseSelectorList(args, true, false)
+	case "nth-child", "nth-last-child":
+		if _, of := splitOf(name, args); of != nil {
+			return parseSelectorList(of, false, false)
+		}
+	}
+	return nil, nil
+}
+
+// nthPseudoClasses are the pseudo-classes with an An+B argument.
+var nthPseudoClasses = map[string]bool{
+	"nth-child":        true,
+	"nth-last-child":   true,
+	"nth-of-type":      true,
+	"nth-last-of-type": true,
+}
+
+// splitOf splits the arguments of :nth-child() and :nth-last-child() into
+// the An+B part and the selector list after "of". of is nil if there is no
+// "of" or the pseudo-class is a different one.
+func splitOf(name string, args []ComponentValue) (anb, of []ComponentValue) {
+	if name == "nth-child" || name == "nth-last-child" {
+		for i := range args {
+			if isToken(&args[i], Ident) && strings.EqualFold(args[i].Token.Value, "of") {
+				return args[:i], args[i+1:]
+			}
+		}
+	}
+	return args, nil
+}
+
+// isIdentValue reports whether the unescaped value s is a valid
+// identifier, as required for an id selector.
+func isIdentValue(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+		if strings.HasPrefix(s, "-") {
+			return true
+		}
+	}
+	if s == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || r >= 0x80 || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// String returns the CSS representation of the selector list.
+func (l SelectorList) String() string {
+	var sb strings.Builder
+	for i, sel := range l {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(sel.String())
+	}
+	return sb.String()
+}
+
+// String returns the CSS representation of the complex selector.
+func (c *ComplexSelector) String() string {
+	var sb strings.Builder
+	for i, compound := range c.Compounds {
+		switch {
+		case compound.Combinator == Descendant && i > 0:
+			sb.WriteByte(' ')
+		case compound.Combinator > Descendant:
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(compound.Combinator.String())
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(compound.String())
+	}
+	return sb.String()
+}
+
+// String returns the CSS representation of the compound selector, without
+// its combinator.
+func (c *CompoundSelector) String() string {
+	var sb strings.Builder
+	for _, s := range c.Selectors {
+		sb.WriteString(s.String())
+	}
+	return sb.String()
+}
+
+// String returns the CSS representation of the simple selector.
+func (s *SimpleSelector) String() string {
+	var sb strings.Builder
+	switch s.Kind {
+	case TypeSelector:
+		sb.WriteString(s.namespacePrefix() + backslashifyIdent(s.Name))
+	case UniversalSelector:
+		sb.WriteString(s.namespacePrefix() + "*")
+	case IDSelector:
+		sb.WriteString("#" + backslashifyIdent(s.Name))
+	case ClassSelector:
+		sb.WriteString("." + backslashifyIdent(s.Name))
+	case AttributeSelector:
+		sb.WriteString("[" + s.namespacePrefix() + backslashifyIdent(s.Name))
+		if s.Operator != "" {
+			sb.WriteString(s.Operator + "\"" + backslashifyString(s.Value) + "\"")
+		}
+		if s.Modifier != "" {
+			sb.WriteString(" " + s.Modifier)
+		}
+		sb.WriteByte(']')
+	case PseudoClassSelector, PseudoElementSelector:
+		sb.WriteByte(':')
+		if s.Kind == PseudoElementSelector {
+			sb.WriteByte(':')
+		}
+		sb.WriteString(backslashifyIdent(s.Name))
+		if s.Function {
+			sb.WriteByte('(')
+			_ = emitValues(&sb, s.Arguments)
+			sb.WriteByte(')')
+		}
+	case NestingSelector:
+		sb.WriteByte('&')
+	}
+	return sb.String()
+}
+
+// namespacePrefix returns the CSS representation of the namespace prefix
+// of s including the |, or "".
+func (s *SimpleSelector) namespacePrefix() string {
+	switch {
+	case !s.HasNamespace:
+		return ""
+	case s.Namespace == "*":
+		return "*|"
+	}
+	return backslashifyIdent(s.Namespace) + "|"
+}
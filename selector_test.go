@@ -0,0 +1,139 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSelectorList(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"a", "a"},
+		{"*", "*"},
+		{"div.item#main", "div.item#main"},
+		{"ul  >  li + li ~ p a", "ul > li + li ~ p a"},
+		{"a>b", "a > b"},
+		{"a , b,c", "a, b, c"},
+		{"svg|rect, *|a, |b, ns|*", "svg|rect, *|a, |b, ns|*"},
+		{"[href]", "[href]"},
+		{"[ lang |= en ]", `[lang|="en"]`},
+		{`a[title="x y" i][data-x~=y s]`, `a[title="x\ y" i][data-x~="y" s]`},
+		{"[ns|a^='b'][|c$=d][*|e*=f]", `[ns|a^="b"][|c$="d"][*|e*="f"]`},
+		{"a:hover::before", "a:hover::before"},
+		{"p:first-line, q:AFTER", "p::first-line, q::after"},
+		{":not(.a, .b):is(h1, 5, h2)", ":not(.a, .b):is(h1, 5, h2)"},
+		{"li:nth-child(2n+1 of .x)", "li:nth-child(2n+1 of .x)"},
+		{":has(> img, + p)", ":has(> img, + p)"},
+		{"& .child, &:hover", "& .child, &:hover"},
+		{`.\31 23`, `.\31 23`},
+		{"#-a", "#-a"},
+		// Lowercase hex after u+ is not a unicode range.
+		{"u+a", "u + a"},
+		{"u+b", "u + b"},
+		{"u+abbr", "u + abbr"},
+	} {
+		list, err := ParseSelectorList(test.input)
+		if err != nil {
+			t.Errorf("For %q: unexpected error %v", test.input, err)
+			continue
+		}
+		if got := list.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestParseSelectorListErrors(t *testing.T) {
+	for _, test := range []struct {
+		input   string
+		message string
+		column  int
+	}{
+		{"", "empty selector", 0},
+		{"a,", "empty selector after ','", 2},
+		{", a", "empty selector before ','", 1},
+		{"a >", "missing selector after combinator", 3},
+		{"> a", "expected selector, found '>'", 1},
+		{".a*", "unexpected '*'", 3},
+		{"#1a", "expected selector, found hash", 1},
+		{"a:", "missing pseudo-class name", 2},
+		{"a::1", "expected pseudo-class name, found number", 4},
+		{"[a=]", "invalid attribute selector", 1},
+		{"[a=b c]", "unexpected ident in attribute selector", 6},
+		{"[1]", "unexpected number in attribute selector", 2},
+		{":not(a,)", "empty selector after ','", 7},
+		{":has(a >)", "missing selector after combinator", 8},
+		{"a {", "unclosed block", 3},
+	} {
+		_, err := ParseSelectorList(test.input)
+		d, ok := err.(*Diagnostic)
+		if !ok || !strings.Contains(d.Message, test.message) || d.Column != test.column {
+			t.Errorf("For %q: expected %q at column %d, got %v", test.input, test.message, test.column, err)
+		}
+	}
+}
+
+func TestSelectorAST(t *testing.T) {
+	list, err := ParseSelectorList("svg|a.b[x|y='z' I]:not(p) > :has(+ q)::part(label)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := list[0]
+	if len(sel.Compounds) != 2 || sel.Compounds[1].Combinator != Child {
+		t.Fatalf("Unexpected compounds %v", sel)
+	}
+	first := sel.Compounds[0].Selectors
+	if len(first) != 4 {
+		t.Fatalf("Expected 4 simple selectors, got %d", len(first))
+	}
+	if s := first[0]; s.Kind != TypeSelector || !s.HasNamespace || s.Namespace != "svg" || s.Name != "a" ||
+		s.Column != 1 || s.EndColumn != 6 {
+		t.Errorf("Unexpected type selector %+v", s)
+	}
+	if s := first[1]; s.Kind != ClassSelector || s.Name != "b" || s.Column != 6 || s.EndColumn != 8 {
+		t.Errorf("Unexpected class selector %+v", s)
+	}
+	if s := first[2]; s.Kind != AttributeSelector || s.Namespace != "x" || s.Name != "y" ||
+		s.Operator != "=" || s.Value != "z" || s.Modifier != "i" {
+		t.Errorf("Unexpected attribute selector %+v", s)
+	}
+	if s := first[3]; s.Kind != PseudoClassSelector || s.Name != "not" || !s.Function ||
+		len(s.Selectors) != 1 || s.Selectors[0].String() != "p" {
+		t.Errorf("Unexpected pseudo-class %+v", s)
+	}
+	second := sel.Compounds[1].Selectors
+	has := second[0].Selectors[0]
+	if has.Compounds[0].Combinator != NextSibling || has.String() != "+ q" {
+		t.Errorf("Unexpected relative selector %v", has)
+	}
+	if s := second[1]; s.Kind != PseudoElementSelector || s.Name != "part" || valuesString(t, s.Arguments) != "label" {
+		t.Errorf("Unexpected pseudo-element %+v", s)
+	}
+	if sel.Column != 1 || sel.EndColumn != 51 {
+		t.Errorf("Unexpected span %+v", sel.Span)
+	}
+}
+
+func TestRuleSelectors(t *testing.T) {
+	sheet, _ := ParseStylesheet("h1, .title > a:hover { x: y }")
+	list, err := sheet.Rules[0].Selectors()
+	if err != nil || len(list) != 2 || list.String() != "h1, .title > a:hover" {
+		t.Errorf("Unexpected selectors %v, %v", list, err)
+	}
+}
+
+func TestRuleSelectorsUnicodeRangeLookalike(t *testing.T) {
+	for _, input := range []string{"u+b { color: red }", "u+a { color: red }"} {
+		sheet, _ := ParseStylesheet(input)
+		list, err := sheet.Rules[0].Selectors()
+		if err != nil || list.String() != input[:1]+" + "+input[2:3] {
+			t.Errorf("For %q: unexpected selectors %v, %v", input, list, err)
+		}
+	}
+}
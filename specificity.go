@@ -0,0 +1,92 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "fmt"
+
+// Specificity is the specificity of a selector as defined by Selectors
+// Level 4: A counts id selectors, B class selectors, attribute selectors
+// and pseudo-classes, and C type selectors and pseudo-elements.
+type Specificity struct {
+	A, B, C int
+}
+
+// Less reports whether s is lower than o.
+func (s Specificity) Less(o Specificity) bool {
+	if s.A != o.A {
+		return s.A < o.A
+	}
+	if s.B != o.B {
+		return s.B < o.B
+	}
+	return s.C < o.C
+}
+
+// Add returns the sum of s and o.
+func (s Specificity) Add(o Specificity) Specificity {
+	return Specificity{s.A + o.A, s.B + o.B, s.C + o.C}
+}
+
+// String returns the specificity in the usual notation, such as (1,2,0).
+func (s Specificity) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", s.A, s.B, s.C)
+}
+
+// Specificity returns the largest specificity of the selectors in l, which
+// is the specificity that :is(l) has.
+func (l SelectorList) Specificity() Specificity {
+	var max Specificity
+	for _, sel := range l {
+		if s := sel.Specificity(); max.Less(s) {
+			max = s
+		}
+	}
+	return max
+}
+
+// Specificity returns the specificity of the complex selector.
+//
+// The nesting selector & counts as zero, since its specificity depends on
+// the parent rule. Resolve nested selectors with ResolveSelector first.
+func (c *ComplexSelector) Specificity() Specificity {
+	var sum Specificity
+	for _, compound := range c.Compounds {
+		for _, s := range compound.Selectors {
+			sum = sum.Add(s.Specificity())
+		}
+	}
+	return sum
+}
+
+// Specificity returns the specificity of the simple selector, including
+// the specificity of its selector arguments: :is(), :not() and :has()
+// count as their most specific argument, :where() as zero, and
+// :nth-child(An+B of S) as a pseudo-class plus the most specific
+// selector in S.
+func (s *SimpleSelector) Specificity() Specificity {
+	switch s.Kind {
+	case IDSelector:
+		return Specificity{A: 1}
+	case ClassSelector, AttributeSelector:
+		return Specificity{B: 1}
+	case TypeSelector:
+		return Specificity{C: 1}
+	case PseudoElementSelector:
+		// ::slotted() and ::cue() add their argument.
+		return Specificity{C: 1}.Add(s.Selectors.Specificity())
+	case PseudoClassSelector:
+		switch s.Name {
+		case "where":
+			return Specificity{}
+		case "is", "matches", "-webkit-any", "-moz-any", "not", "has":
+			return s.Selectors.Specificity()
+		}
+		// This includes :nth-child(An+B of S), :host() and
+		// :host-context().
+		return Specificity{B: 1}.Add(s.Selectors.Specificity())
+	}
+	return Specificity{}
+}
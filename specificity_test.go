@@ -0,0 +1,62 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "testing"
+
+func TestSpecificity(t *testing.T) {
+	for _, test := range []struct {
+		selector string
+		expected Specificity
+	}{
+		{"*", Specificity{0, 0, 0}},
+		{"li", Specificity{0, 0, 1}},
+		{"ul li", Specificity{0, 0, 2}},
+		{"ul ol+li", Specificity{0, 0, 3}},
+		{"h1 + *[rel=up]", Specificity{0, 1, 1}},
+		{"ul ol li.red", Specificity{0, 1, 3}},
+		{"li.red.level", Specificity{0, 2, 1}},
+		{"#x34y", Specificity{1, 0, 0}},
+		{"#s12:not(FOO)", Specificity{1, 0, 1}},
+		{".foo :is(.bar, #baz)", Specificity{1, 1, 0}},
+		{":where(#a, .b) p", Specificity{0, 0, 1}},
+		{"p::before", Specificity{0, 0, 2}},
+		{"p:before", Specificity{0, 0, 2}},
+		{"a:hover", Specificity{0, 1, 1}},
+		{":not(.a, #b span)", Specificity{1, 0, 1}},
+		{":has(> img.x, + p)", Specificity{0, 1, 1}},
+		{":nth-child(2n+1)", Specificity{0, 1, 0}},
+		{":nth-child(2n+1 of li.important, #x)", Specificity{1, 1, 0}},
+		{":nth-last-child(even of .a)", Specificity{0, 2, 0}},
+		{"::slotted(span.x)", Specificity{0, 1, 2}},
+		{":host(.dark)", Specificity{0, 2, 0}},
+		{"svg|*", Specificity{0, 0, 0}},
+		{"& .a", Specificity{0, 1, 0}},
+	} {
+		list, err := ParseSelectorList(test.selector)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.selector, err)
+		}
+		if got := list[0].Specificity(); got != test.expected {
+			t.Errorf("For %q: expected %v, got %v", test.selector, test.expected, got)
+		}
+	}
+}
+
+func TestSpecificityLess(t *testing.T) {
+	ordered := []Specificity{{0, 0, 0}, {0, 0, 9}, {0, 1, 0}, {0, 1, 1}, {1, 0, 0}}
+	for i := range ordered {
+		for j := range ordered {
+			if got := ordered[i].Less(ordered[j]); got != (i < j) {
+				t.Errorf("%v.Less(%v) = %v", ordered[i], ordered[j], got)
+			}
+		}
+	}
+	list, _ := ParseSelectorList("a, .b, #c d")
+	if got := list.Specificity(); got != (Specificity{1, 0, 1}) {
+		t.Errorf("Unexpected list specificity %v", got)
+	}
+}
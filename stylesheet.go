@@ -0,0 +1,421 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"io"
+	"strings"
+)
+
+// Stylesheet is a parsed style sheet.
+type Stylesheet struct {
+	Rules []*Rule
+}
+
+// Rule is a qualified rule, such as a style rule, or an at-rule.
+//
+// The contents of the block are parsed following CSS Syntax Module Level 3
+// for every rule: Declarations are the declarations at the start of the
+// block, Rules are the nested rules. Declarations that follow a nested rule
+// are kept in order as a nested declarations rule, which has neither an
+// at-keyword, a prelude nor a block.
+type Rule struct {
+	Span
+	// AtKeyword is the at-keyword token of an at-rule. It is nil for a
+	// qualified rule.
+	AtKeyword *Token
+	// Prelude is the part of the rule before the block or the semicolon,
+	// including whitespace.
+	Prelude []ComponentValue
+	// Block is the {} block of the rule. It is nil for an at-rule that ends
+	// with a semicolon or at the end of the input.
+	Block        *ComponentValue
+	Declarations []*Declaration
+	Rules        []*Rule
+}
+
+// Declaration is a property or descriptor declaration such as
+// "color: red !important".
+type Declaration struct {
+	Span
+	// Name is the name of the property with escapes resolved.
+	Name string
+	// Value is the value without leading and trailing whitespace and
+	// without the !important flag.
+	Value     []ComponentValue
+	Important bool
+}
+
+// Name returns the name of an at-rule without the @, or "" for a
+// qualified rule.
+func (r *Rule) Name() string {
+	if r.AtKeyword == nil {
+		return ""
+	}
+	return r.AtKeyword.Value
+}
+
+// IsNestedDeclarations reports whether r is a nested declarations rule,
+// which holds the declarations that follow a nested rule.
+func (r *Rule) IsNestedDeclarations() bool {
+	return r.AtKeyword == nil && r.Block == nil
+}
+
+// Emit writes the CSS representation of the style sheet to w.
+func (sh *Stylesheet) Emit(w io.Writer) error {
+	for _, r := range sh.Rules {
+		if err := r.Emit(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Emit writes the CSS representation of the rule to w. The block is
+// written from the parsed declarations and rules.
+func (r *Rule) Emit(w io.Writer) error {
+	if r.IsNestedDeclarations() {
+		return emitDeclarations(w, r.Declarations)
+	}
+	if r.AtKeyword != nil {
+		if err := r.AtKeyword.Emit(w); err != nil {
+			return err
+		}
+	}
+	if err := emitValues(w, r.Prelude); err != nil {
+		return err
+	}
+	if r.Block == nil {
+		return wr(w, ";")
+	}
+	if err := wr(w, "{"); err != nil {
+		return err
+	}
+	if err := emitDeclarations(w, r.Declarations); err != nil {
+		return err
+	}
+	for _, child := range r.Rules {
+		if err := child.Emit(w); err != nil {
+			return err
+		}
+	}
+	return wr(w, "}")
+}
+
+// Emit writes the CSS representation of the declaration to w, without a
+// trailing semicolon.
+func (d *Declaration) Emit(w io.Writer) error {
+	if err := wr(w, backslashifyIdent(d.Name), ":"); err != nil {
+		return err
+	}
+	if err := emitValues(w, d.Value); err != nil {
+		return err
+	}
+	if d.Important {
+		return wr(w, "!important")
+	}
+	return nil
+}
+
+func emitDeclarations(w io.Writer, decls []*Declaration) error {
+	for _, d := range decls {
+		if err := d.Emit(w); err != nil {
+			return err
+		}
+		if err := wr(w, ";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func emitValues(w io.Writer, values []ComponentValue) error {
+	for i := range values {
+		if err := values[i].Emit(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseStylesheet parses input as a style sheet. The scanner runs in
+// Recover mode; the problems in the input are returned as diagnostics.
+func ParseStylesheet(input string) (*Stylesheet, []Diagnostic) {
+	s := New(input)
+	s.Mode = Recover
+	sheet := s.Stylesheet()
+	return sheet, s.Diagnostics()
+}
+
+// Stylesheet consumes the remaining input of s and parses it as a style
+// sheet. Parse errors are added to the diagnostics of s.
+func (s *Scanner) Stylesheet() *Stylesheet {
+	p := ruleParser{s: s, list: s.ComponentValues()}
+	return &Stylesheet{Rules: p.parseRuleList()}
+}
+
+// ParseDeclarationList parses input as the contents of a declaration
+// block, such as the value of an HTML style attribute. It returns the
+// declarations in order and the rules (usually at-rules) between them,
+// with positions relative to the start of input. The scanner runs in
+// Recover mode; the problems in the input are returned as diagnostics.
+func ParseDeclarationList(input string) ([]*Declaration, []*Rule, []Diagnostic) {
+	s := New(input)
+	s.Mode = Recover
+	decls, rules := s.DeclarationList()
+	return decls, rules, s.Diagnostics()
+}
+
+// DeclarationList consumes the remaining input of s and parses it as the
+// contents of a declaration block. Unlike in Rule, declarations that follow
+// a nested rule are returned with the other declarations. Parse errors are
+// added to the diagnostics of s.
+func (s *Scanner) DeclarationList() ([]*Declaration, []*Rule) {
+	p := ruleParser{s: s, list: s.ComponentValues()}
+	decls, nested := p.parseBlockContents()
+	var rules []*Rule
+	for _, r := range nested {
+		if r.IsNestedDeclarations() {
+			decls = append(decls, r.Declarations...)
+		} else {
+			rules = append(rules, r)
+		}
+	}
+	return decls, rules
+}
+
+// ruleParser parses rules and declarations from a list of component
+// values.
+type ruleParser struct {
+	s    *Scanner // receives the diagnostics
+	list []ComponentValue
+	pos  int
+}
+
+// parseBlock parses the contents of a {} block.
+func (s *Scanner) parseBlock(block *ComponentValue) ([]*Declaration, []*Rule) {
+	p := ruleParser{s: s, list: block.Values}
+	return p.parseBlockContents()
+}
+
+// isToken reports whether c is a preserved token of type t.
+func isToken(c *ComponentValue, t Type) bool {
+	return c.Kind == PreservedToken && c.Token.Type == t
+}
+
+// parseRuleList parses the top level of a style sheet.
+func (p *ruleParser) parseRuleList() []*Rule {
+	var rules []*Rule
+	for p.pos < len(p.list) {
+		c := &p.list[p.pos]
+		switch {
+		case c.IsWhitespace(), isToken(c, CDO), isToken(c, CDC):
+			p.pos++
+		case isToken(c, AtKeyword):
+			rules = append(rules, p.parseAtRule())
+		default:
+			if r := p.parseQualifiedRule(false); r != nil {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules
+}
+
+// parseBlockContents parses declarations and rules until the end of the
+// list.
+func (p *ruleParser) parseBlockContents() ([]*Declaration, []*Rule) {
+	var decls, pending []*Declaration
+	var rules []*Rule
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if len(rules) == 0 {
+			decls = append(decls, pending...)
+		} else {
+			r := &Rule{Declarations: pending}
+			r.Span = pending[0].Span
+			last := pending[len(pending)-1]
+			r.EndLine, r.EndColumn, r.EndOffset = last.EndLine, last.EndColumn, last.EndOffset
+			rules = append(rules, r)
+		}
+		pending = nil
+	}
+	for p.pos < len(p.list) {
+		c := &p.list[p.pos]
+		switch {
+		case c.IsWhitespace(), c.IsDelim(';'):
+			p.pos++
+		case isToken(c, AtKeyword):
+			flush()
+			rules = append(rules, p.parseAtRule())
+		default:
+			mark := p.pos
+			if d := p.parseDeclaration(); d != nil {
+				pending = append(pending, d)
+				continue
+			}
+			p.pos = mark
+			if r := p.parseQualifiedRule(true); r != nil {
+				flush()
+				rules = append(rules, r)
+			}
+		}
+	}
+	flush()
+	return decls, rules
+}
+
+// parseAtRule parses an at-rule. The current component value is the
+// at-keyword.
+func (p *ruleParser) parseAtRule() *Rule {
+	r := &Rule{AtKeyword: p.list[p.pos].Token}
+	last := r.AtKeyword
+	p.pos++
+	for p.pos < len(p.list) {
+		c := &p.list[p.pos]
+		p.pos++
+		if c.IsDelim(';') {
+			last = c.Token
+			break
+		}
+		if c.IsBlock('{') {
+			r.Block = c
+			r.Declarations, r.Rules = p.s.parseBlock(c)
+			last = c.lastToken()
+			break
+		}
+		r.Prelude = append(r.Prelude, *c)
+		last = c.lastToken()
+	}
+	r.Span = spanOf(r.AtKeyword, last)
+	return r
+}
+
+// parseQualifiedRule parses a qualified rule starting at the current
+// component value. In a block (nested), a semicolon ends the rule
+// without consuming it. It returns nil if there is no valid rule.
+func (p *ruleParser) parseQualifiedRule(nested bool) *Rule {
+	start := p.pos
+	r := &Rule{}
+	for p.pos < len(p.list) {
+		c := &p.list[p.pos]
+		if nested && c.IsDelim(';') {
+			p.reportInvalid(start, CodeInvalidDeclaration, "invalid declaration")
+			return nil
+		}
+		p.pos++
+		if !c.IsBlock('{') {
+			r.Prelude = append(r.Prelude, *c)
+			continue
+		}
+		if startsCustomProperty(r.Prelude) {
+			// Something like --x:a{} is an invalid custom property, not a
+			// rule.
+			if nested {
+				for p.pos < len(p.list) && !p.list[p.pos].IsDelim(';') {
+					p.pos++
+				}
+			}
+			p.reportInvalid(start, CodeInvalidDeclaration, "invalid declaration")
+			return nil
+		}
+		r.Block = c
+		r.Declarations, r.Rules = p.s.parseBlock(c)
+		r.Span = spanOf(p.list[start].Token, c.lastToken())
+		return r
+	}
+	if nested {
+		p.reportInvalid(start, CodeInvalidDeclaration, "invalid declaration")
+	} else {
+		p.reportInvalid(start, CodeInvalidRule, "rule without a block")
+	}
+	return nil
+}
+
+// reportInvalid reports the component values from start to the current
+// position.
+func (p *ruleParser) reportInvalid(start int, code Code, msg string) {
+	end := max(p.pos, start+1)
+	span := spanOf(p.list[start].Token, p.list[end-1].lastToken())
+	p.s.reportSpan(span, SeverityError, code, msg)
+}
+
+// startsCustomProperty reports whether the first two non-whitespace
+// values of list are a custom property name and a colon.
+func startsCustomProperty(list []ComponentValue) bool {
+	var first []*ComponentValue
+	for i := range list {
+		if !list[i].IsWhitespace() {
+			first = append(first, &list[i])
+			if len(first) == 2 {
+				break
+			}
+		}
+	}
+	return len(first) == 2 && isToken(first[0], Ident) &&
+		strings.HasPrefix(first[0].Token.Value, "--") && first[1].IsDelim(':')
+}
+
+// skipWhitespace advances past whitespace tokens.
+func (p *ruleParser) skipWhitespace() {
+	for p.pos < len(p.list) && p.list[p.pos].IsWhitespace() {
+		p.pos++
+	}
+}
+
+// parseDeclaration parses a declaration starting at the current component
+// value, up to but not including the next semicolon. It returns nil if
+// there is no valid declaration.
+func (p *ruleParser) parseDeclaration() *Declaration {
+	nameValue := &p.list[p.pos]
+	if !isToken(nameValue, Ident) {
+		return nil
+	}
+	p.pos++
+	p.skipWhitespace()
+	if p.pos == len(p.list) || !p.list[p.pos].IsDelim(':') {
+		return nil
+	}
+	p.pos++
+	p.skipWhitespace()
+	start := p.pos
+	for p.pos < len(p.list) && !p.list[p.pos].IsDelim(';') {
+		p.pos++
+	}
+	d := &Declaration{Name: nameValue.Token.Value}
+	value := trimWhitespace(p.list[start:p.pos])
+	last := nameValue.Token
+	if len(value) > 0 {
+		last = value[len(value)-1].lastToken()
+	}
+	if n := len(value); n >= 2 && isToken(&value[n-1], Ident) &&
+		strings.EqualFold(value[n-1].Token.Value, "important") {
+		if rest := trimWhitespace(value[:n-1]); len(rest) > 0 && rest[len(rest)-1].IsDelim('!') {
+			value = trimWhitespace(rest[:len(rest)-1])
+			d.Important = true
+		}
+	}
+	if !strings.HasPrefix(d.Name, "--") && len(value) > 1 {
+		for i := range value {
+			if value[i].IsBlock('{') {
+				return nil
+			}
+		}
+	}
+	d.Value = value
+	d.Span = spanOf(nameValue.Token, last)
+	return d
+}
+
+// trimWhitespace returns list without trailing whitespace.
+func trimWhitespace(list []ComponentValue) []ComponentValue {
+	for len(list) > 0 && list[len(list)-1].IsWhitespace() {
+		list = list[:len(list)-1]
+	}
+	return list
+}
@@ -0,0 +1,168 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// emitString returns the output of e.Emit.
+func emitString(t *testing.T, e interface{ Emit(io.Writer) error }) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := e.Emit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// valuesString returns the CSS representation of list.
+func valuesString(t *testing.T, list []ComponentValue) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := emitValues(&buf, list); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func diagnosticCodes(diagnostics []Diagnostic) []Code {
+	var codes []Code
+	for _, d := range diagnostics {
+		codes = append(codes, d.Code)
+	}
+	return codes
+}
+
+func TestParseStylesheet(t *testing.T) {
+	sheet, diagnostics := ParseStylesheet(`<!-- @charset "utf-8";
+a, b { color: red; margin : 0 auto ! IMPORTANT }
+@media print { p { x: y } }
+@import url(x.css); --> c{}`)
+	if len(diagnostics) != 0 {
+		t.Fatalf("Unexpected diagnostics %v", diagnostics)
+	}
+	if len(sheet.Rules) != 5 {
+		t.Fatalf("Expected 5 rules, got %d", len(sheet.Rules))
+	}
+	charset := sheet.Rules[0]
+	if charset.Name() != "charset" || valuesString(t, charset.Prelude) != ` "utf-8"` || charset.Block != nil {
+		t.Errorf("Unexpected @charset rule %q", emitString(t, charset))
+	}
+	style := sheet.Rules[1]
+	if style.AtKeyword != nil || valuesString(t, style.Prelude) != "a, b " || len(style.Declarations) != 2 {
+		t.Fatalf("Unexpected style rule %q", emitString(t, style))
+	}
+	margin := style.Declarations[1]
+	if margin.Name != "margin" || valuesString(t, margin.Value) != "0 auto" || !margin.Important {
+		t.Errorf("Unexpected declaration %q", emitString(t, margin))
+	}
+	if margin.Line != 2 || margin.Column != 20 || margin.EndColumn != 47 {
+		t.Errorf("Unexpected declaration position %+v", margin.Span)
+	}
+	media := sheet.Rules[2]
+	if media.Name() != "media" || len(media.Declarations) != 0 || len(media.Rules) != 1 ||
+		media.Rules[0].Declarations[0].Name != "x" {
+		t.Errorf("Unexpected @media rule %q", emitString(t, media))
+	}
+	imp := sheet.Rules[3]
+	if imp.Name() != "import" || len(imp.Prelude) != 2 || imp.Prelude[1].Token.Type != URI {
+		t.Errorf("Unexpected @import rule %q", emitString(t, imp))
+	}
+	if got := emitString(t, sheet); got != `@charset "utf-8";a, b {color:red;margin:0 auto!important;}@media print {p {x:y;}}@import url('x.css');c{}` {
+		t.Errorf("Unexpected serialization %q", got)
+	}
+}
+
+func TestParseStylesheetRecovery(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		rules []string
+		codes []Code
+	}{
+		{"a { color red; b: c }", []string{"a {b:c;}"}, []Code{CodeInvalidDeclaration}},
+		{"a { b: c; 42; d: e }", []string{"a {b:c;d:e;}"}, []Code{CodeInvalidDeclaration}},
+		{"a {} b", []string{"a {}"}, []Code{CodeInvalidRule}},
+		{"a { b: c", []string{"a {b:c;}"}, []Code{CodeUnclosedBlock}},
+		{"a { b: c {d: e} }", []string{"a {b: c {d:e;}}"}, nil},
+		{"a { b: {c} d; e: {f} }", []string{"a {b: {}e:{f};}"}, []Code{CodeInvalidDeclaration, CodeInvalidDeclaration}},
+		{"a { --x: {c} d; --y:a{} }", []string{"a {--x:{c} d;--y:a{};}"}, nil},
+		{"--x:a{} b{}", []string{"b{}"}, []Code{CodeInvalidDeclaration}},
+		{"a { 'b }\n; c: d }", []string{"a {c:d;}"}, []Code{CodeNewlineInString, CodeInvalidDeclaration}},
+		{"@a b; @c", []string{"@a b;", "@c;"}, nil},
+		{") a {}", []string{") a {}"}, nil},
+	} {
+		sheet, diagnostics := ParseStylesheet(test.input)
+		var rules []string
+		for _, r := range sheet.Rules {
+			rules = append(rules, emitString(t, r))
+		}
+		if !reflect.DeepEqual(rules, test.rules) {
+			t.Errorf("For %q: expected rules %q, got %q", test.input, test.rules, rules)
+		}
+		if codes := diagnosticCodes(diagnostics); !reflect.DeepEqual(codes, test.codes) {
+			t.Errorf("For %q: expected diagnostics %v, got %v", test.input, test.codes, diagnostics)
+		}
+	}
+}
+
+func TestParseStylesheetNestedDeclarations(t *testing.T) {
+	sheet, _ := ParseStylesheet(".card { color: red; &:hover { color: blue } .title { x: y } z: w; }")
+	card := sheet.Rules[0]
+	if len(card.Declarations) != 1 || len(card.Rules) != 3 {
+		t.Fatalf("Unexpected rule %q", emitString(t, card))
+	}
+	if got := valuesString(t, card.Rules[0].Prelude); got != "&:hover " {
+		t.Errorf("Unexpected nested rule prelude %q", got)
+	}
+	if d := card.Rules[2]; !d.IsNestedDeclarations() || len(d.Declarations) != 1 || d.Column != 61 || d.EndColumn != 65 {
+		t.Errorf("Expected nested declarations for z, got %q %+v", emitString(t, d), d.Span)
+	}
+}
+
+func TestParseStylesheetDiagnosticPosition(t *testing.T) {
+	_, diagnostics := ParseStylesheet("a {\n  color red;\n}")
+	if len(diagnostics) != 1 {
+		t.Fatalf("Unexpected diagnostics %v", diagnostics)
+	}
+	d := diagnostics[0]
+	if d.Line != 2 || d.Column != 3 || d.EndLine != 2 || d.EndColumn != 12 || d.Severity != SeverityError {
+		t.Errorf("Unexpected diagnostic %#v", d)
+	}
+}
+
+func TestParseDeclarationList(t *testing.T) {
+	decls, rules, diagnostics := ParseDeclarationList("color: red; font-size : 12pt!important;\n  @media print { x: y } margin:0; oops; --v: {a}")
+	var names []string
+	for _, d := range decls {
+		names = append(names, emitString(t, d))
+	}
+	if want := []string{"color:red", "font-size:12pt!important", "margin:0", "--v:{a}"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected declarations %q, got %q", want, names)
+	}
+	if len(rules) != 1 || rules[0].Name() != "media" || rules[0].Line != 2 || rules[0].Column != 3 {
+		t.Errorf("Unexpected rules %v", rules)
+	}
+	if d := decls[2]; d.Line != 2 || d.Column != 25 || d.Offset != 64 || d.EndOffset != 72 {
+		t.Errorf("Unexpected position %+v", d.Span)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Code != CodeInvalidDeclaration ||
+		diagnostics[0].Column != 35 || diagnostics[0].EndColumn != 39 {
+		t.Errorf("Unexpected diagnostics %v", diagnostics)
+	}
+}
+
+func TestParseDeclarationListEmpty(t *testing.T) {
+	for _, input := range []string{"", "  ", ";;", "/* x */"} {
+		decls, rules, diagnostics := ParseDeclarationList(input)
+		if len(decls) != 0 || len(rules) != 0 || len(diagnostics) != 0 {
+			t.Errorf("For %q: expected nothing, got %v %v %v", input, decls, rules, diagnostics)
+		}
+	}
+}
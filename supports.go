@@ -0,0 +1,291 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "strings"
+
+// --------------------------------------------------------------------
+// CSS Conditional Rules: @supports
+//
+// A supports condition is parsed into a tree. The leaves are declarations,
+// selector(), font-tech() and font-format() tests, which are answered by
+// the callbacks of a SupportsEnvironment.
+// --------------------------------------------------------------------
+
+// SupportsConditionKind identifies the kind of a SupportsCondition.
+type SupportsConditionKind int
+
+const (
+	// SupportsDeclaration tests a declaration such as (display: grid).
+	SupportsDeclaration SupportsConditionKind = iota
+	// SupportsSelector tests a selector with selector().
+	SupportsSelector
+	// SupportsFontTech tests a font technology with font-tech().
+	SupportsFontTech
+	// SupportsFontFormat tests a font format with font-format().
+	SupportsFontFormat
+	// SupportsNot negates its single condition.
+	SupportsNot
+	// SupportsAnd is true if all of its conditions are true.
+	SupportsAnd
+	// SupportsOr is true if any of its conditions is true.
+	SupportsOr
+	// SupportsGeneralEnclosed is a function or a () block that is none of
+	// the above. It is reserved for future extensions and is false.
+	SupportsGeneralEnclosed
+)
+
+// SupportsCondition is a node in the condition tree of an @supports rule.
+type SupportsCondition struct {
+	Span
+	Kind SupportsConditionKind
+	// Declaration is the declaration of a SupportsDeclaration.
+	Declaration *Declaration
+	// Selector is the selector of a SupportsSelector.
+	Selector *ComplexSelector
+	// Keyword is the lowercased argument of SupportsFontTech and
+	// SupportsFontFormat.
+	Keyword string
+	// Conditions are the operands of SupportsNot, SupportsAnd and
+	// SupportsOr.
+	Conditions []*SupportsCondition
+	// Enclosed is the function of a SupportsSelector, SupportsFontTech
+	// or SupportsFontFormat, or the function or block of a
+	// SupportsGeneralEnclosed.
+	Enclosed *ComponentValue
+}
+
+// SupportsEnvironment answers the feature tests of supports conditions. A
+// nil callback supports nothing.
+type SupportsEnvironment struct {
+	// Declaration reports whether the property and value of d are
+	// supported. Declarations of custom properties are always supported
+	// and not passed to it.
+	Declaration func(d *Declaration) bool
+	// Selector reports whether the selector is supported. It is only
+	// called for selectors that can be parsed.
+	Selector func(sel *ComplexSelector) bool
+	// FontTech and FontFormat report whether a font technology such as
+	// color-colrv1 or a font format such as woff2 is supported.
+	FontTech   func(tech string) bool
+	FontFormat func(format string) bool
+}
+
+// ParseSupportsCondition parses input as the condition of an @supports
+// rule.
+func ParseSupportsCondition(input string) (*SupportsCondition, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return nil, &diagnostics[i]
+		}
+	}
+	return ParseSupportsValues(list)
+}
+
+// ParseSupportsValues parses a supports condition from component values
+// such as the prelude of an @supports rule.
+func ParseSupportsValues(list []ComponentValue) (*SupportsCondition, error) {
+	list = trimWhitespace(list)
+	list = list[skipSpace(list, 0):]
+	if len(list) == 0 {
+		return nil, &Diagnostic{Severity: SeverityError, Code: CodeInvalidSupports, Message: "empty supports condition"}
+	}
+	cond, err := parseSupportsCondition(list)
+	if err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+// SupportsCondition parses the prelude of an @supports rule.
+func (r *Rule) SupportsCondition() (*SupportsCondition, error) {
+	return ParseSupportsValues(r.Prelude)
+}
+
+// parseSupportsCondition parses list, which is not empty and has no
+// leading or trailing whitespace.
+func parseSupportsCondition(list []ComponentValue) (*SupportsCondition, *Diagnostic) {
+	span := spanOf(list[0].Token, list[len(list)-1].lastToken())
+	if isIdent(&list[0], "not") {
+		pos := skipSpace(list, 1)
+		if pos == 1 || pos == len(list) {
+			return nil, syntaxError(list[0].Span(), CodeInvalidSupports, "missing condition after 'not'")
+		}
+		operand, err := parseSupportsInParens(&list[pos])
+		if err != nil {
+			return nil, err
+		}
+		if next := skipSpace(list, pos+1); next < len(list) {
+			return nil, syntaxError(list[next].Span(), CodeInvalidSupports, "unexpected "+describe(&list[next])+" after 'not' condition")
+		}
+		return &SupportsCondition{Span: span, Kind: SupportsNot, Conditions: []*SupportsCondition{operand}}, nil
+	}
+	var operands []*SupportsCondition
+	op := ""
+	pos := 0
+	for {
+		operand, err := parseSupportsInParens(&list[pos])
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		next := skipSpace(list, pos+1)
+		if next == len(list) {
+			break
+		}
+		c := &list[next]
+		word := ""
+		if isToken(c, Ident) {
+			word = strings.ToLower(c.Token.Value)
+		}
+		switch {
+		case word != "and" && word != "or":
+			return nil, syntaxError(c.Span(), CodeInvalidSupports, "expected 'and' or 'or', found "+describe(c))
+		case next == pos+1:
+			return nil, syntaxError(c.Span(), CodeInvalidSupports, "missing whitespace before '"+word+"'")
+		case op != "" && word != op:
+			return nil, syntaxError(c.Span(), CodeInvalidSupports, "'and' and 'or' can not be mixed without parentheses")
+		}
+		op = word
+		pos = skipSpace(list, next+1)
+		if pos == next+1 || pos == len(list) {
+			return nil, syntaxError(c.Span(), CodeInvalidSupports, "missing condition after '"+op+"'")
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	kind := SupportsAnd
+	if op == "or" {
+		kind = SupportsOr
+	}
+	return &SupportsCondition{Span: span, Kind: kind, Conditions: operands}, nil
+}
+
+// parseSupportsInParens parses a condition or declaration in parentheses,
+// a supports function or general enclosed content.
+func parseSupportsInParens(c *ComponentValue) (*SupportsCondition, *Diagnostic) {
+	res := &SupportsCondition{Span: c.Span(), Kind: SupportsGeneralEnclosed, Enclosed: c}
+	if c.Kind == FunctionBlock {
+		args := trimWhitespace(c.Values)
+		args = args[skipSpace(args, 0):]
+		switch strings.ToLower(c.Name()) {
+		case "selector":
+			if list, err := parseSelectorList(args, false, false); err == nil && len(list) == 1 {
+				res.Kind = SupportsSelector
+				res.Selector = list[0]
+			}
+		case "font-tech", "font-format":
+			if len(args) == 1 && isToken(&args[0], Ident) {
+				res.Kind = SupportsFontTech
+				if strings.EqualFold(c.Name(), "font-format") {
+					res.Kind = SupportsFontFormat
+				}
+				res.Keyword = strings.ToLower(args[0].Token.Value)
+			}
+		}
+		return res, nil
+	}
+	if !c.IsBlock('(') {
+		return nil, syntaxError(c.Span(), CodeInvalidSupports, "expected '(', found "+describe(c))
+	}
+	inner := trimWhitespace(c.Values)
+	inner = inner[skipSpace(inner, 0):]
+	if len(inner) == 0 {
+		return res, nil
+	}
+	if isToken(&inner[0], Ident) && !isIdent(&inner[0], "not") {
+		p := ruleParser{list: inner}
+		if d := p.parseDeclaration(); d != nil && p.pos == len(inner) {
+			return &SupportsCondition{Span: c.Span(), Kind: SupportsDeclaration, Declaration: d}, nil
+		}
+		return res, nil
+	}
+	if cond, err := parseSupportsCondition(inner); err == nil && cond.Kind != SupportsGeneralEnclosed {
+		cond.Span = c.Span()
+		return cond, nil
+	}
+	return res, nil
+}
+
+// String returns the CSS representation of the condition.
+func (c *SupportsCondition) String() string {
+	switch c.Kind {
+	case SupportsDeclaration:
+		var sb strings.Builder
+		sb.WriteString("(" + c.Declaration.Name + ": ")
+		_ = emitValues(&sb, c.Declaration.Value)
+		if c.Declaration.Important {
+			sb.WriteString(" !important")
+		}
+		sb.WriteString(")")
+		return sb.String()
+	case SupportsSelector:
+		return "selector(" + c.Selector.String() + ")"
+	case SupportsFontTech:
+		return "font-tech(" + c.Keyword + ")"
+	case SupportsFontFormat:
+		return "font-format(" + c.Keyword + ")"
+	case SupportsNot:
+		return "not " + c.Conditions[0].parenthesized()
+	case SupportsAnd, SupportsOr:
+		op := " and "
+		if c.Kind == SupportsOr {
+			op = " or "
+		}
+		parts := make([]string, len(c.Conditions))
+		for i, operand := range c.Conditions {
+			parts[i] = operand.parenthesized()
+		}
+		return strings.Join(parts, op)
+	}
+	var sb strings.Builder
+	_ = c.Enclosed.Emit(&sb)
+	return sb.String()
+}
+
+// parenthesized returns the condition as an operand of not, and or or.
+func (c *SupportsCondition) parenthesized() string {
+	switch c.Kind {
+	case SupportsNot, SupportsAnd, SupportsOr:
+		return "(" + c.String() + ")"
+	}
+	return c.String()
+}
+
+// Match evaluates the condition with the callbacks of env.
+func (c *SupportsCondition) Match(env *SupportsEnvironment) bool {
+	switch c.Kind {
+	case SupportsDeclaration:
+		if strings.HasPrefix(c.Declaration.Name, "--") {
+			return true
+		}
+		return env.Declaration != nil && env.Declaration(c.Declaration)
+	case SupportsSelector:
+		return env.Selector != nil && env.Selector(c.Selector)
+	case SupportsFontTech:
+		return env.FontTech != nil && env.FontTech(c.Keyword)
+	case SupportsFontFormat:
+		return env.FontFormat != nil && env.FontFormat(c.Keyword)
+	case SupportsNot:
+		return !c.Conditions[0].Match(env)
+	case SupportsAnd:
+		for _, operand := range c.Conditions {
+			if !operand.Match(env) {
+				return false
+			}
+		}
+		return true
+	case SupportsOr:
+		for _, operand := range c.Conditions {
+			if operand.Match(env) {
+				return true
+			}
+		}
+	}
+	return false
+}
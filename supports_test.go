@@ -0,0 +1,143 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSupportsCondition(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"(display: grid)", "(display: grid)"},
+		{"( display:grid )", "(display: grid)"},
+		{"(color: red !important)", "(color: red !important)"},
+		{"not (display: grid)", "not (display: grid)"},
+		{"(display: grid) and (not selector(:has(a)))", "(display: grid) and (not selector(:has(a)))"},
+		{"(a: b) or (c: d) or (e: f)", "(a: b) or (c: d) or (e: f)"},
+		{"((a: b) and (c: d)) or (e: f)", "((a: b) and (c: d)) or (e: f)"},
+		{"selector(ul>li)", "selector(ul > li)"},
+		{"selector(a, b)", "selector(a, b)"},
+		{"font-tech(COLOR-COLRv1)", "font-tech(color-colrv1)"},
+		{"font-format(woff2)", "font-format(woff2)"},
+		{"foo(bar)", "foo(bar)"},
+		{"(foo bar)", "(foo bar)"},
+		{"(--x: {a})", "(--x: {a})"},
+		{"(--y:)", "(--y: )"},
+	} {
+		cond, err := ParseSupportsCondition(test.input)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if got := cond.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestParseSupportsConditionErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"", "line 0, column 0: error: empty supports condition"},
+		{"display: grid", "line 1, column 1: error: expected '(', found ident"},
+		{"not", "line 1, column 1: error: missing condition after 'not'"},
+		{"(a: b) and (c: d) or (e: f)", "line 1, column 19: error: 'and' and 'or' can not be mixed without parentheses"},
+		{"(a: b) and", "line 1, column 8: error: missing condition after 'and'"},
+		{"(a: b) (c: d)", "line 1, column 8: error: expected 'and' or 'or', found ( block"},
+		{"not (a: b) and (c: d)", "line 1, column 12: error: unexpected ident after 'not' condition"},
+	} {
+		_, err := ParseSupportsCondition(test.input)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		if got := err.(*Diagnostic).String(); got != test.expected {
+			t.Errorf("For %q:\nexpected %q\ngot      %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestSupportsMatch(t *testing.T) {
+	env := &SupportsEnvironment{
+		Declaration: func(d *Declaration) bool {
+			var sb strings.Builder
+			_ = emitValues(&sb, d.Value)
+			switch d.Name {
+			case "display":
+				return sb.String() == "block" || sb.String() == "grid"
+			case "color":
+				return true
+			}
+			return false
+		},
+		Selector: func(sel *ComplexSelector) bool {
+			for _, compound := range sel.Compounds {
+				for _, s := range compound.Selectors {
+					if s.Kind == PseudoClassSelector && s.Name == "has" {
+						return false
+					}
+				}
+			}
+			return true
+		},
+		FontFormat: func(format string) bool { return format == "woff2" },
+	}
+	for _, test := range []struct {
+		condition string
+		expected  bool
+	}{
+		{"(display: grid)", true},
+		{"(display: flex)", false},
+		{"(DISPLAY: grid)", false},
+		{"(float: left)", false},
+		{"not (float: left)", true},
+		{"(display: grid) and (not selector(:has(a)))", true},
+		{"(display: grid) and selector(:has(a))", false},
+		{"(float: left) or (color: red)", true},
+		{"(float: left) or (display: flex)", false},
+		{"((display: grid) and (color: red)) or (float: left)", true},
+		{"selector(a > b)", true},
+		{"selector(a, b)", false},
+		{"selector(a >)", false},
+		{"font-format(woff2)", true},
+		{"font-format(truetype)", false},
+		{"font-tech(variations)", false},
+		{"(--anything: { x })", true},
+		{"foo(bar)", false},
+		{"not foo(bar)", true},
+		{"(foo bar)", false},
+	} {
+		cond, err := ParseSupportsCondition(test.condition)
+		if err != nil {
+			t.Fatalf("For %q: %v", test.condition, err)
+		}
+		if got := cond.Match(env); got != test.expected {
+			t.Errorf("For %q: expected %v, got %v", test.condition, test.expected, got)
+		}
+	}
+	cond, _ := ParseSupportsCondition("(display: grid)")
+	if cond.Match(&SupportsEnvironment{}) {
+		t.Error("An empty environment should support nothing")
+	}
+}
+
+func TestRuleSupportsCondition(t *testing.T) {
+	sheet, _ := ParseStylesheet("@supports (display: grid) { a { b: c } }")
+	cond, err := sheet.Rules[0].SupportsCondition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond.Kind != SupportsDeclaration || cond.Declaration.Name != "display" {
+		t.Errorf("Unexpected condition %v", cond)
+	}
+	if cond.Offset != 10 || cond.EndOffset != 25 {
+		t.Errorf("Unexpected span %d-%d", cond.Offset, cond.EndOffset)
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import "strings"
+
+// --------------------------------------------------------------------
+// CSS Syntax Module Level 3 tokenizer
+//
+// These functions implement the Syntax3 mode. They share the scan length
+// helpers with the default tokenizer, which check s.Mode where the two
+// differ.
+// --------------------------------------------------------------------
+
+// next3 scans a single token from the buffered input following the
+// tokenizer of CSS Syntax Module Level 3.
+func (s *Scanner) next3() *Token {
+	for {
+		if !s.more(s.pos) {
+			s.err = s.emptyToken(EOF, "")
+			return s.err
+		}
+		if s.base+s.pos == 0 && s.hasPrefix("\uFEFF") {
+			// The byte order mark belongs to the encoding, not the token
+			// stream.
+			s.updatePosition("\uFEFF")
+			continue
+		}
+		if s.hasPrefix("/*") {
+			// Comments are consumed without producing a token. An unclosed
+			// comment runs to the end of the input.
+			n, ok := s.scanCommentLen()
+			if !ok {
+				n = len(s.input) - s.pos
+			}
+			comment := s.startToken(Comment, s.input[s.pos:s.pos+n])
+			s.updatePosition(comment.raw)
+			s.endToken(comment)
+			s.checkRaw(comment)
+			if !ok {
+				s.reportToken(comment, SeverityError, CodeUnclosedComment, "unclosed comment")
+			}
+			continue
+		}
+		break
+	}
+
+	input := s.input[s.pos:]
+	c := input[0]
+	switch c {
+	case '\t', '\n', '\f', '\r', ' ':
+		n := s.scanWhitespaceLen(0)
+		return s.emitToken(S, input[:n])
+
+	case '"', '\'':
+		n, closed, bad := s.scanString3Len()
+		if bad {
+			token := s.emitValue(BadString, input[:n], "")
+			s.reportToken(token, SeverityError, CodeNewlineInString, "unescaped newline in string")
+			return token
+		}
+		if closed {
+			return s.emitValue(String, input[:n], unescape3(input[1:n-1], true))
+		}
+		token := s.emitValue(String, input[:n], unescape3(input[1:n], true))
+		s.reportToken(token, SeverityError, CodeUnclosedString, "unclosed quotation mark")
+		return token
+
+	case '#':
+		n := s.scanNameLen(1)
+		if n > 0 {
+			return s.emitValue(Hash, input[:1+n], unescape3(input[1:1+n], false))
+		}
+
+	case '(':
+		return s.emitSimple(LeftParen, "(")
+	case ')':
+		return s.emitSimple(RightParen, ")")
+	case '[':
+		return s.emitSimple(LeftBracket, "[")
+	case ']':
+		return s.emitSimple(RightBracket, "]")
+	case '{':
+		return s.emitSimple(LeftBrace, "{")
+	case '}':
+		return s.emitSimple(RightBrace, "}")
+	case ',':
+		return s.emitSimple(Comma, ",")
+	case ':':
+		return s.emitSimple(Colon, ":")
+	case ';':
+		return s.emitSimple(Semicolon, ";")
+
+	case '+', '.':
+		if s.startsNumber() {
+			return s.scanNumericToken()
+		}
+
+	case '-':
+		if s.startsNumber() {
+			return s.scanNumericToken()
+		}
+		if s.hasPrefix("-->") {
+			return s.emitSimple(CDC, "-->")
+		}
+		n := s.scanIdentLen(0)
+		if n > 0 {
+			return s.scanIdentLike3(n)
+		}
+
+	case '<':
+		if s.hasPrefix("<!--") {
+			return s.emitSimple(CDO, "<!--")
+		}
+
+	case '@':
+		n := s.scanIdentLen(1)
+		if n > 0 {
+			return s.emitValue(AtKeyword, input[:1+n], unescape3(input[1:1+n], false))
+		}
+
+	case '\\':
+		n := s.scanIdentLen(0)
+		if n > 0 {
+			return s.scanIdentLike3(n)
+		}
+
+	default:
+		if isDigitByte(c) {
+			return s.scanNumericToken()
+		}
+		if isNmStartByte(c) || s.isNonASCII(c) {
+			n := s.scanIdentLen(0)
+			if n > 0 {
+				return s.scanIdentLike3(n)
+			}
+		}
+	}
+
+	return s.emitRune(Delim)
+}
+
+// startsNumber reports whether the input at s.pos starts a number.
+func (s *Scanner) startsNumber() bool {
+	switch c := s.byteAt(0); c {
+	case '+', '-':
+		return s.digitAt(1) || s.byteAt(1) == '.' && s.digitAt(2)
+	case '.':
+		return s.digitAt(1)
+	default:
+		return isDigitByte(c)
+	}
+}
+
+// scanIdentLike3 scans an Ident, Function, URI or BadURL token.
+// identLen is the pre-computed byte length of the identifier portion.
+func (s *Scanner) scanIdentLike3(identLen int) *Token {
+	input := s.input[s.pos:]
+	name := unescape3(input[:identLen], false)
+	if s.byteAt(identLen) != '(' {
+		return s.emitValue(Ident, input[:identLen], name)
+	}
+
+	// url( followed by a quoted string is an ordinary function, anything
+	// else is a url token.
+	if len(name) == 3 && startsWithFold(name, "url") {
+		pos := identLen + 1
+		for isWhitespace(s.byteAt(pos)) {
+			pos++
+		}
+		if c := s.byteAt(pos); c != '"' && c != '\'' {
+			n, start, end, closed, bad := s.scanURL3Len(identLen + 1)
+			if bad {
+				token := s.emitValue(BadURL, input[:n], "")
+				s.reportToken(token, SeverityError, CodeBadURL, "invalid url")
+				return token
+			}
+			token := s.emitValue(URI, input[:n], unescape3(input[start:end], false))
+			if !closed {
+				s.reportToken(token, SeverityError, CodeUnclosedURL, "unclosed url")
+			}
+			return token
+		}
+	}
+	return s.emitValue(Function, input[:identLen+1], name)
+}
+
+// scanString3Len returns the byte length of a string token starting at
+// s.pos. closed reports whether the string ends with its closing quote
+// rather than at the end of the input. bad reports that the string ran
+// into an unescaped newline, which is not part of the token.
+func (s *Scanner) scanString3Len() (n int, closed, bad bool) {
+	quote := s.input[s.pos]
+	pos := s.pos + 1
+	for s.more(pos) {
+		c := s.input[pos]
+		switch {
+		case c == quote:
+			return pos + 1 - s.pos, true, false
+		case c == '\n' || c == '\r' || c == '\f':
+			return pos - s.pos, false, true
+		case c == '\\':
+			if !s.more(pos + 1) {
+				pos++ // a backslash at the end of the input is ignored
+				continue
+			}
+			switch s.input[pos+1] {
+			case '\n', '\f':
+				pos += 2
+			case '\r':
+				pos += 2
+				if s.more(pos) && s.input[pos] == '\n' {
+					pos++
+				}
+			default:
+				// Outside of Syntax3 mode, escaped control characters are
+				// not valid escapes but still belong to the string.
+				pos += max(s.scanEscapeLen(pos-s.pos), 2)
+			}
+		case c >= 0x80:
+			_, w := s.decodeRune(pos)
+			pos += w
+		default:
+			pos++
+		}
+	}
+	return pos - s.pos, false, false
+}
+
+// scanURL3Len returns the byte length of a url token starting at s.pos.
+// prefixLen is the byte length of the url( prefix. The url's content is
+// s.input[start:end], relative to s.pos. closed reports whether the url
+// ends with a closing paren rather than at the end of the input. bad
+// reports a bad url, whose remnants up to the closing paren are included
+// in the length.
+func (s *Scanner) scanURL3Len(prefixLen int) (n, start, end int, closed, bad bool) {
+	pos := s.pos + prefixLen
+	for s.more(pos) && isWhitespace(s.input[pos]) {
+		pos++
+	}
+	start = pos - s.pos
+	for {
+		if !s.more(pos) {
+			return pos - s.pos, start, pos - s.pos, false, false
+		}
+		c := s.input[pos]
+		switch {
+		case c == ')':
+			return pos + 1 - s.pos, start, pos - s.pos, true, false
+		case isWhitespace(c):
+			end = pos - s.pos
+			for s.more(pos) && isWhitespace(s.input[pos]) {
+				pos++
+			}
+			if !s.more(pos) {
+				return pos - s.pos, start, end, false, false
+			}
+			if s.input[pos] == ')' {
+				return pos + 1 - s.pos, start, end, true, false
+			}
+			return s.scanBadURLLen(pos), 0, 0, false, true
+		case c == '"' || c == '\'' || c == '(' || isNonPrintable(c):
+			return s.scanBadURLLen(pos), 0, 0, false, true
+		case c == '\\':
+			n := s.scanEscapeLen(pos - s.pos)
+			if n == 0 {
+				return s.scanBadURLLen(pos), 0, 0, false, true
+			}
+			pos += n
+		case c >= 0x80:
+			_, w := s.decodeRune(pos)
+			pos += w
+		default:
+			pos++
+		}
+	}
+}
+
+// scanBadURLLen consumes the remnants of a bad url from s.input[pos] up to
+// and including the closing paren, and returns the byte length of the
+// whole token starting at s.pos.
+func (s *Scanner) scanBadURLLen(pos int) int {
+	for s.more(pos) {
+		c := s.input[pos]
+		if c == ')' {
+			return pos + 1 - s.pos
+		}
+		if c == '\\' {
+			if n := s.scanEscapeLen(pos - s.pos); n > 0 {
+				pos += n
+				continue
+			}
+		}
+		pos++
+	}
+	return pos - s.pos
+}
+
+// isNonPrintable reports whether c is a non-printable code point as
+// defined by CSS Syntax 3. NUL is not included since the specification
+// replaces it with U+FFFD before tokenizing.
+func isNonPrintable(c byte) bool {
+	return c >= 0x01 && c <= 0x08 || c == 0x0b || c >= 0x0e && c <= 0x1f || c == 0x7f
+}
+
+var preprocessReplacer = strings.NewReplacer(
+	"\r\n", "\n",
+	"\r", "\n",
+	"\f", "\n",
+	"\x00", "\uFFFD",
+)
+
+// eatSixDigitSpace removes the whitespace character that follows an escape
+// of six hex digits. CSS Syntax 3 consumes it as part of the escape, while
+// unbackslash keeps it as CSS 2.1 does.
+func eatSixDigitSpace(s string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			continue
+		}
+		n := 0
+		for n < 6 && i+1+n < len(s) && isHexChar(s[i+1+n]) {
+			n++
+		}
+		if n == 0 {
+			// Skip the escaped character, which may be a backslash.
+			i++
+			continue
+		}
+		i += n
+		if n == 6 && i+1 < len(s) && isWhitespace(s[i+1]) {
+			b.WriteString(s[last : i+1])
+			last = i + 2
+			i++
+		}
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// unescape3 returns the value of the source text s following CSS Syntax 3.
+// Newlines are normalized to LF, NUL and escapes of invalid code points
+// become U+FFFD, and a backslash at the end of the input is ignored in
+// strings and turns into U+FFFD elsewhere.
+func unescape3(s string, isString bool) string {
+	if strings.ContainsAny(s, "\r\f\x00") {
+		s = preprocessReplacer.Replace(s)
+	}
+	trailing := false
+	if n := len(s) - len(strings.TrimRight(s, "\\")); n%2 == 1 {
+		s = s[:len(s)-1]
+		trailing = true
+	}
+	s = unbackslash(eatSixDigitSpace(s), isString)
+	if strings.IndexByte(s, 0) >= 0 {
+		s = strings.ReplaceAll(s, "\x00", "\uFFFD")
+	}
+	if trailing && !isString {
+		s += "\uFFFD"
+	}
+	return s
+}
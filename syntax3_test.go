@@ -0,0 +1,182 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// loadParsingTests reads a test file in the format of the css-parsing-tests
+// corpus: a JSON array of alternating inputs and expected results.
+//
+// testdata/tokens.json uses the corpus' token representation, but lists
+// the tokens flat since there is no block structure at the token level:
+// brackets are written as "(", ")", "[", "]", "{" and "}".
+func loadParsingTests(t *testing.T, name string) [][2]any {
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []any
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if len(list)%2 != 0 {
+		t.Fatalf("%s: odd number of entries", name)
+	}
+	var tests [][2]any
+	for i := 0; i < len(list); i += 2 {
+		tests = append(tests, [2]any{list[i], list[i+1]})
+	}
+	return tests
+}
+
+// tokenJSON returns the css-parsing-tests representation of tok.
+func tokenJSON(tok *Token) any {
+	switch tok.Type {
+	case Ident:
+		return []any{"ident", tok.Value}
+	case AtKeyword:
+		return []any{"at-keyword", tok.Value}
+	case Hash:
+		if startsIdent(tok.Raw()[1:]) {
+			return []any{"hash", tok.Value, "id"}
+		}
+		return []any{"hash", tok.Value, "unrestricted"}
+	case String:
+		return []any{"string", tok.Value}
+	case BadString:
+		return []any{"error", "bad-string"}
+	case URI:
+		return []any{"url", tok.Value}
+	case BadURL:
+		return []any{"error", "bad-url"}
+	case Function:
+		return []any{"function", tok.Value}
+	case Number:
+		return append([]any{"number"}, numberJSON(tok.Value, tok)...)
+	case Percentage:
+		return append([]any{"percentage"}, numberJSON(tok.Value, tok)...)
+	case Dimension:
+		repr := tok.Value[:len(tok.Value)-len(tok.Unit)]
+		return append(append([]any{"dimension"}, numberJSON(repr, tok)...), tok.Unit)
+	case S:
+		return " "
+	case CDO:
+		return "<!--"
+	case CDC:
+		return "-->"
+	}
+	return tok.Value
+}
+
+func numberJSON(repr string, tok *Token) []any {
+	if tok.Integer {
+		return []any{repr, tok.Num, "integer"}
+	}
+	return []any{repr, tok.Num, "number"}
+}
+
+// startsIdent reports whether s starts with an identifier as defined by
+// CSS Syntax 3.
+func startsIdent(s string) bool {
+	isStart := func(s string) bool {
+		if s == "" {
+			return false
+		}
+		c := s[0]
+		if isNmStartByte(c) || c >= 0x80 || c == 0 {
+			return true
+		}
+		return c == '\\' && (len(s) == 1 || !strings.ContainsRune("\n\r\f", rune(s[1])))
+	}
+	if strings.HasPrefix(s, "-") {
+		return strings.HasPrefix(s, "--") || isStart(s[1:])
+	}
+	return isStart(s)
+}
+
+func TestSyntax3Tokens(t *testing.T) {
+	for _, test := range loadParsingTests(t, "tokens.json") {
+		input := test[0].(string)
+		s := New(input)
+		s.Mode = Syntax3
+		got := []any{}
+		for {
+			tok := s.Next()
+			if tok.Type == EOF {
+				break
+			}
+			if tok.Type == Error {
+				t.Fatalf("For %q: unexpected error token %v", input, tok)
+			}
+			got = append(got, tokenJSON(tok))
+		}
+		checkParsingTest(t, input, got, test[1])
+	}
+}
+
+// checkParsingTest compares got with the expected result of a
+// css-parsing-tests case.
+func checkParsingTest(t *testing.T, input string, got, expected any) {
+	t.Helper()
+	// Round trip through JSON so numbers and slices compare equal.
+	data, _ := json.Marshal(got)
+	var normalized any
+	_ = json.Unmarshal(data, &normalized)
+	if !reflect.DeepEqual(normalized, expected) {
+		want, _ := json.Marshal(expected)
+		t.Errorf("For %q:\nexpected %s\ngot      %s", input, want, data)
+	}
+}
+
+func TestSyntax3Reader(t *testing.T) {
+	for _, test := range loadParsingTests(t, "tokens.json") {
+		input := test[0].(string)
+		a := New(input)
+		a.Mode = Syntax3
+		b := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+		b.Mode = Syntax3
+		for {
+			ta, tb := a.Next(), b.Next()
+			if !reflect.DeepEqual(ta, tb) {
+				t.Fatalf("For %q: expected %v, got %v", input, ta, tb)
+			}
+			if ta.Type == EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestSyntax3Diagnostics(t *testing.T) {
+	for _, test := range []struct {
+		input       string
+		diagnostics []string
+	}{
+		{"a b", nil},
+		{"'a\nb'", []string{"unescaped newline in string", "unclosed quotation mark"}},
+		{"url(a b) url(x", []string{"invalid url", "unclosed url"}},
+		{"a /* b", []string{"unclosed comment"}},
+	} {
+		s := New(test.input)
+		s.Mode = Syntax3
+		for s.Next().Type != EOF {
+		}
+		var diagnostics []string
+		for _, d := range s.Diagnostics() {
+			diagnostics = append(diagnostics, d.Message)
+		}
+		if !reflect.DeepEqual(diagnostics, test.diagnostics) {
+			t.Errorf("For %q: expected diagnostics %q, got %q", test.input, test.diagnostics, diagnostics)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -32,11 +33,60 @@ func (t Type) GoString() string {
 }
 
 // Token represents a token and the corresponding string.
+//
+// Line and Column give the position of the first character of the token,
+// EndLine and EndColumn the position just after its last character. Both
+// are 1-based and count runes. Offset and EndOffset are the corresponding
+// 0-based byte offsets into the input, so that the source text of the
+// token is input[Offset:EndOffset].
 type Token struct {
-	Type   Type
-	Value  string
-	Line   int
-	Column int
+	Type      Type
+	Value     string
+	Line      int
+	Column    int
+	Offset    int
+	EndOffset int
+	EndLine   int
+	EndColumn int
+
+	// Num is the numeric value of a Number, Percentage or Dimension token.
+	// For a percentage it is the number before the %, so 50% has Num 50.
+	Num float64
+	// Integer reports whether the number has the "integer" type of CSS
+	// Syntax 3, that is, it was written without a decimal point or exponent.
+	Integer bool
+	// Signed reports whether the number was written with an explicit
+	// + or - sign.
+	Signed bool
+	// Unit is the unit of a Dimension token, with escapes resolved, in
+	// the case of the source: 1PX has the unit "PX". Units are ASCII
+	// case-insensitive, use LowerUnit to compare them with unit names.
+	Unit string
+
+	raw string
+
+	// Set in Lossless mode: the type and value of the token as scanned.
+	lossless bool
+	rawType  Type
+	rawValue string
+}
+
+// Raw returns the source text of the token exactly as it appeared in the
+// input, before escapes, quotes and delimiters were processed. It is empty
+// for tokens that were not produced by a Scanner.
+func (t *Token) Raw() string {
+	return t.raw
+}
+
+// LowerUnit returns the unit of a Dimension token in ASCII lower case, the
+// form to compare with unit names such as "px" or "em".
+func (t *Token) LowerUnit() string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, t.Unit)
 }
 
 // String returns a string representation of the token.
@@ -49,6 +99,16 @@ func (t *Token) String() string {
 		t.Type, t.Line, t.Column, t.Value)
 }
 
+// setNumber sets the numeric fields of t from repr, the number part of a
+// Number, Percentage or Dimension token.
+func (t *Token) setNumber(repr string) {
+	// The scanner only accepts valid numbers; out of range values become
+	// infinite.
+	t.Num, _ = strconv.ParseFloat(repr, 64)
+	t.Integer = !strings.ContainsAny(repr, ".eE")
+	t.Signed = repr[0] == '+' || repr[0] == '-'
+}
+
 // For those types of tokens that need to have their representation
 // normalized to contain the semantic contents of the token, rather than
 // the literal contents of the token, this performs that act.
@@ -174,7 +234,13 @@ func wr(w io.Writer, strs ...string) (err error) {
 // Emit assumes you have not set the token's .Value to an invalid value for
 // many of these; for instance, if you manually take a Number token and set
 // its .Value to "sometext", you will emit something that is not a number.
+//
+// Tokens scanned in Lossless mode are written exactly as they appeared in
+// the input, unless their Type or Value have been changed since.
 func (t *Token) Emit(w io.Writer) (err error) {
+	if t.lossless && t.Type == t.rawType && t.Value == t.rawValue {
+		return wr(w, t.raw)
+	}
 	switch t.Type {
 	case Error:
 		return errors.New("can not emit an error token")
@@ -193,7 +259,12 @@ func (t *Token) Emit(w io.Writer) (err error) {
 	case Percentage:
 		err = wr(w, t.Value, "%")
 	case Dimension:
-		err = wr(w, t.Value)
+		n := len(t.Value) - len(t.Unit)
+		if t.Unit == "" || !strings.HasSuffix(t.Value, t.Unit) {
+			// A token built by hand: the unit follows the number.
+			n = New(t.Value).scanNumLen(0)
+		}
+		err = wr(w, t.Value[:n], backslashifyUnit(t.Value[n:]))
 	case URI:
 		err = wr(w, "url('", backslashifyString(t.Value), "')")
 	case Local:
@@ -228,6 +299,27 @@ func (t *Token) Emit(w io.Writer) (err error) {
 		err = wr(w, t.Value)
 	case BOM:
 		err = wr(w, "\ufeff")
+	case Colon:
+		err = wr(w, ":")
+	case Semicolon:
+		err = wr(w, ";")
+	case Comma:
+		err = wr(w, ",")
+	case LeftBracket:
+		err = wr(w, "[")
+	case RightBracket:
+		err = wr(w, "]")
+	case LeftParen:
+		err = wr(w, "(")
+	case RightParen:
+		err = wr(w, ")")
+	case LeftBrace:
+		err = wr(w, "{")
+	case RightBrace:
+		err = wr(w, "}")
+	case BadString, BadURL:
+		// There is no canonical form for these, write them as they were.
+		err = wr(w, t.raw)
 	}
 
 	return
@@ -389,6 +481,23 @@ func backslashifyIdent(s string) string {
 	return res.String()
 }
 
+// backslashifyUnit escapes the unit of a dimension. A unit that looks like
+// an exponent, such as e3 in 1\65 3, gets its e escaped so that it is not
+// read back as part of the number.
+func backslashifyUnit(s string) string {
+	u := backslashifyIdent(s)
+	if len(u) > 1 && (u[0] == 'e' || u[0] == 'E') {
+		rest := strings.TrimLeft(u[1:2], "+-") + u[2:]
+		if rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			var buf bytes.Buffer
+			_, _ = buf.WriteRune('\\')
+			_ = cssEncodeHex(&buf, u[0])
+			return buf.String() + u[1:]
+		}
+	}
+	return u
+}
+
 func backslashifyHash(s string) string {
 	res := bytes.NewBuffer(make([]byte, 0, len(s)+32))
 	b := []byte(s)
@@ -0,0 +1,214 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Fonts Module Level 4: unicode-range
+// --------------------------------------------------------------------
+
+// RuneRange is an interval of code points from Lo to Hi, inclusive.
+type RuneRange struct {
+	Lo, Hi rune
+}
+
+// UnicodeRangeSet is a set of code points stored as sorted intervals that
+// neither overlap nor touch. The zero value is the empty set.
+type UnicodeRangeSet []RuneRange
+
+// unicodeMax is the largest code point.
+const unicodeMax = 0x10FFFF
+
+// NewUnicodeRangeSet returns the set of code points in ranges. The ranges
+// may be given in any order and may overlap; ranges with Lo > Hi are
+// ignored.
+func NewUnicodeRangeSet(ranges ...RuneRange) UnicodeRangeSet {
+	sorted := make([]RuneRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Lo <= r.Hi {
+			sorted = append(sorted, r)
+		}
+	}
+	slices.SortFunc(sorted, func(a, b RuneRange) int { return int(a.Lo - b.Lo) })
+	var set UnicodeRangeSet
+	for _, r := range sorted {
+		if n := len(set); n > 0 && r.Lo <= set[n-1].Hi+1 {
+			set[n-1].Hi = max(set[n-1].Hi, r.Hi)
+			continue
+		}
+		set = append(set, r)
+	}
+	return set
+}
+
+// ParseUnicodeRange parses input as a comma separated list of unicode
+// ranges such as "U+0025-00FF, u+4??".
+func ParseUnicodeRange(input string) (UnicodeRangeSet, error) {
+	list, diagnostics := ParseComponentValueList(input)
+	for i := range diagnostics {
+		if diagnostics[i].Severity == SeverityError {
+			return nil, &diagnostics[i]
+		}
+	}
+	return ParseUnicodeRangeValues(list)
+}
+
+// ParseUnicodeRangeValues parses a comma separated list of unicode ranges
+// such as the value of the unicode-range descriptor. Question marks are
+// expanded to the range of hex digits they stand for.
+//
+// The scanner only produces UnicodeRange tokens for uppercase hex digits,
+// so a lowercase range such as u+e0-ff arrives as adjacent ident, number,
+// dimension and delim tokens. Their text is joined and parsed as well.
+func ParseUnicodeRangeValues(list []ComponentValue) (UnicodeRangeSet, error) {
+	list = trimWhitespace(list)
+	list = list[skipSpace(list, 0):]
+	if len(list) == 0 {
+		return nil, syntaxError(Span{}, CodeInvalidUnicodeRange, "empty unicode range list")
+	}
+	var ranges []RuneRange
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !list[i].IsDelim(',') {
+			continue
+		}
+		item := trimWhitespace(list[start:i])
+		item = item[skipSpace(item, 0):]
+		switch {
+		case len(item) == 0 && i < len(list):
+			return nil, syntaxError(list[i].Span(), CodeInvalidUnicodeRange, "empty unicode range before ','")
+		case len(item) == 0:
+			return nil, syntaxError(list[i-1].Span(), CodeInvalidUnicodeRange, "empty unicode range after ','")
+		case !isToken(&item[0], UnicodeRange) && !isIdent(&item[0], "u"):
+			return nil, syntaxError(item[0].Span(), CodeInvalidUnicodeRange, "expected unicode range, found "+describe(&item[0]))
+		}
+		text, n := unicodeRangeText(item)
+		if rest := significant(item[n:]); len(rest) > 0 {
+			return nil, syntaxError(rest[0].Span(), CodeInvalidUnicodeRange, "unexpected "+describe(rest[0])+" after unicode range")
+		}
+		r, err := parseRuneRange(text)
+		if err != nil {
+			return nil, syntaxError(spanOf(item[0].Token, item[n-1].Token), CodeInvalidUnicodeRange, err.Error())
+		}
+		ranges = append(ranges, r)
+		start = i + 1
+	}
+	return NewUnicodeRangeSet(ranges...), nil
+}
+
+// unicodeRangeText returns the source text of the adjacent tokens at the
+// start of list that make up a unicode range, and their number.
+func unicodeRangeText(list []ComponentValue) (string, int) {
+	var sb strings.Builder
+	n := 0
+	for ; n < len(list) && list[n].Kind == PreservedToken; n++ {
+		t := list[n].Token
+		if t.Type != UnicodeRange && t.Type != Ident && t.Type != Number && t.Type != Dimension && t.Type != Delim {
+			break
+		}
+		if raw := t.Raw(); raw != "" {
+			sb.WriteString(raw)
+		} else {
+			sb.WriteString(t.Value)
+		}
+	}
+	return sb.String(), n
+}
+
+// parseRuneRange parses the text of a UnicodeRange token such as U+4??
+// or U+0025-00FF. The range must not be empty and must not exceed
+// U+10FFFF.
+func parseRuneRange(s string) (RuneRange, error) {
+	if len(s) < 3 || s[0] != 'U' && s[0] != 'u' || s[1] != '+' {
+		return RuneRange{}, fmt.Errorf("invalid unicode range %s", s)
+	}
+	text := s
+	s = s[2:]
+	var lo, hi string
+	switch {
+	case strings.Contains(s, "?"):
+		if strings.Contains(strings.TrimRight(s, "?"), "?") || strings.Contains(s, "-") {
+			return RuneRange{}, fmt.Errorf("invalid unicode range %s", text)
+		}
+		lo = strings.ReplaceAll(s, "?", "0")
+		hi = strings.ReplaceAll(s, "?", "F")
+	case strings.Contains(s, "-"):
+		lo, hi, _ = strings.Cut(s, "-")
+	default:
+		lo, hi = s, s
+	}
+	if len(lo) > 6 || len(hi) > 6 {
+		return RuneRange{}, fmt.Errorf("invalid unicode range %s", text)
+	}
+	start, err1 := strconv.ParseUint(lo, 16, 32)
+	end, err2 := strconv.ParseUint(hi, 16, 32)
+	switch {
+	case err1 != nil || err2 != nil:
+		return RuneRange{}, fmt.Errorf("invalid unicode range %s", text)
+	case end > unicodeMax:
+		return RuneRange{}, fmt.Errorf("unicode range %s exceeds U+10FFFF", text)
+	case start > end:
+		return RuneRange{}, fmt.Errorf("start of unicode range %s is after its end", text)
+	}
+	return RuneRange{rune(start), rune(end)}, nil
+}
+
+// Contains reports whether r is in the set.
+func (s UnicodeRangeSet) Contains(r rune) bool {
+	_, found := slices.BinarySearchFunc(s, r, func(rr RuneRange, r rune) int {
+		switch {
+		case rr.Hi < r:
+			return -1
+		case rr.Lo > r:
+			return 1
+		}
+		return 0
+	})
+	return found
+}
+
+// Union returns the code points that are in s or in o.
+func (s UnicodeRangeSet) Union(o UnicodeRangeSet) UnicodeRangeSet {
+	return NewUnicodeRangeSet(append(slices.Clone(s), o...)...)
+}
+
+// Intersect returns the code points that are in both s and o.
+func (s UnicodeRangeSet) Intersect(o UnicodeRangeSet) UnicodeRangeSet {
+	var res UnicodeRangeSet
+	for i, j := 0, 0; i < len(s) && j < len(o); {
+		lo, hi := max(s[i].Lo, o[j].Lo), min(s[i].Hi, o[j].Hi)
+		if lo <= hi {
+			res = append(res, RuneRange{lo, hi})
+		}
+		if s[i].Hi < o[j].Hi {
+			i++
+		} else {
+			j++
+		}
+	}
+	return res
+}
+
+// String returns the canonical CSS representation of the set: the
+// intervals in ascending order with uppercase hex digits without leading
+// zeros, such as "U+0-7F, U+131, U+400-4FF". The empty set is "".
+func (s UnicodeRangeSet) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		if r.Lo == r.Hi {
+			parts[i] = fmt.Sprintf("U+%X", r.Lo)
+		} else {
+			parts[i] = fmt.Sprintf("U+%X-%X", r.Lo, r.Hi)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
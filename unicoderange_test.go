@@ -0,0 +1,112 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"testing"
+)
+
+func TestParseUnicodeRange(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"U+0042", "U+42"},
+		{"u+00ff", "U+FF"},
+		{"U+0025-00FF", "U+25-FF"},
+		{"u+0025-00ff", "U+25-FF"},
+		{"u+a", "U+A"},
+		{"u+e0-ff", "U+E0-FF"},
+		{"u+0-7f", "U+0-7F"},
+		{"u+1e3", "U+1E3"},
+		{"u+abc-def, U+4??", "U+400-4FF, U+ABC-DEF"},
+		{"U+4??", "U+400-4FF"},
+		{"U+10????", "U+100000-10FFFF"},
+		{"U+0-7F, U+0131, U+4??", "U+0-7F, U+131, U+400-4FF"},
+		{"U+4??, U+0-7F", "U+0-7F, U+400-4FF"},
+		{"U+0-7F, U+80-FF, U+40-50", "U+0-FF"},
+		{"U+0-10FFFF", "U+0-10FFFF"},
+	} {
+		set, err := ParseUnicodeRange(test.input)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		if got := set.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestParseUnicodeRangeErrors(t *testing.T) {
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"", "line 0, column 0: error: empty unicode range list"},
+		{"U+00FF-0000", "line 1, column 1: error: start of unicode range U+00FF-0000 is after its end"},
+		{"U+110000", "line 1, column 1: error: unicode range U+110000 exceeds U+10FFFF"},
+		{"U+1?????", "line 1, column 1: error: unicode range U+1????? exceeds U+10FFFF"},
+		{"U+??????", "line 1, column 1: error: unicode range U+?????? exceeds U+10FFFF"},
+		{"U+0-7F x", "line 1, column 8: error: unexpected ident after unicode range"},
+		{"u+ab cd", "line 1, column 6: error: unexpected ident after unicode range"},
+		{"u+xyz", "line 1, column 1: error: invalid unicode range u+xyz"},
+		{"latin", "line 1, column 1: error: expected unicode range, found ident"},
+		{"U+0,", "line 1, column 4: error: empty unicode range after ','"},
+		{", U+0", "line 1, column 1: error: empty unicode range before ','"},
+	} {
+		_, err := ParseUnicodeRange(test.input)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeInvalidUnicodeRange {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+}
+
+func TestUnicodeRangeSet(t *testing.T) {
+	latin, _ := ParseUnicodeRange("U+0-FF, U+131, U+152-153")
+	cyrillic, _ := ParseUnicodeRange("U+400-45F, U+490-491")
+	for r, expected := range map[rune]bool{
+		0: true, 'A': true, 0xFF: true, 0x100: false, 0x131: true,
+		0x130: false, 0x152: true, 0x153: true, 0x154: false, 0x10FFFF: false,
+	} {
+		if got := latin.Contains(r); got != expected {
+			t.Errorf("Contains(U+%X): expected %v, got %v", r, expected, got)
+		}
+	}
+	if (UnicodeRangeSet{}).Contains(0) {
+		t.Error("The empty set should contain nothing")
+	}
+	if got := latin.Union(cyrillic).String(); got != "U+0-FF, U+131, U+152-153, U+400-45F, U+490-491" {
+		t.Errorf("Unexpected union %q", got)
+	}
+	if got := latin.Union(NewUnicodeRangeSet(RuneRange{0x100, 0x130})).String(); got != "U+0-131, U+152-153" {
+		t.Errorf("Unexpected union %q", got)
+	}
+	if got := latin.Intersect(cyrillic); len(got) != 0 {
+		t.Errorf("Unexpected intersection %q", got)
+	}
+	other := NewUnicodeRangeSet(RuneRange{0x150, 0x500}, RuneRange{0x41, 0x5A}, RuneRange{0x61, 0x7A})
+	if got := latin.Intersect(other).String(); got != "U+41-5A, U+61-7A, U+152-153" {
+		t.Errorf("Unexpected intersection %q", got)
+	}
+	if got := NewUnicodeRangeSet(RuneRange{5, 1}, RuneRange{3, 3}, RuneRange{1, 2}).String(); got != "U+1-3" {
+		t.Errorf("Unexpected set %q", got)
+	}
+}
+
+func TestFontFaceCovers(t *testing.T) {
+	face, _ := ParseFontFace("font-family: a; src: url(a)")
+	if !face.Covers(0x4E00) {
+		t.Error("A face without unicode-range should cover everything")
+	}
+	face, _ = ParseFontFace("font-family: a; src: url(a); unicode-range: u+0-7f, u+4??")
+	if !face.Covers('a') || !face.Covers(0x4FF) || face.Covers(0x80) {
+		t.Errorf("Unexpected coverage of %v", face.UnicodeRange)
+	}
+}
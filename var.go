@@ -0,0 +1,122 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// CSS Custom Properties Level 1: var()
+// --------------------------------------------------------------------
+
+// SubstituteVars returns list with every var() function replaced by the
+// value of its custom property, which lookup returns for names such as
+// "--brand", or by its fallback if lookup does not know the property.
+// References in the values and fallbacks are substituted as well. An
+// error is returned for a malformed var(), for an unknown property without
+// a fallback, for a property that refers to itself and for a substitution
+// that grows too large. The error is reported at the var() function in
+// list that led to it.
+//
+// The values are inserted as they are, so the result can be passed to
+// functions such as ParseColorValues.
+func SubstituteVars(list []ComponentValue, lookup func(name string) ([]ComponentValue, bool)) ([]ComponentValue, error) {
+	s := varSubstitution{lookup: lookup, active: map[string]bool{}}
+	res, err := s.substitute(list)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// maxVarValues limits the number of component values the var() functions
+// may produce, counted at every level of nested references. Custom
+// properties that each refer to the next one several times grow
+// exponentially, and CSS Variables requires implementations to guard
+// against that.
+const maxVarValues = 1 << 16
+
+// varSubstitution keeps the custom properties that are being substituted
+// to detect cycles.
+type varSubstitution struct {
+	lookup func(name string) ([]ComponentValue, bool)
+	active map[string]bool
+	// outer is the var() function of the caller's list that is being
+	// substituted, diagnostics are reported at its position.
+	outer *ComponentValue
+	// size counts the component values the var() functions produced.
+	size int
+}
+
+// substitute replaces the var() functions in list, including those in
+// functions and blocks.
+func (s *varSubstitution) substitute(list []ComponentValue) ([]ComponentValue, *Diagnostic) {
+	res := make([]ComponentValue, 0, len(list))
+	for i := range list {
+		c := list[i]
+		switch {
+		case c.Kind == FunctionBlock && strings.EqualFold(c.Name(), "var"):
+			outer := s.outer
+			if outer == nil {
+				s.outer = &list[i]
+			}
+			values, err := s.reference(&list[i])
+			if s.size += len(values); err == nil && s.size > maxVarValues {
+				err = s.error("custom property substitution exceeds " + strconv.Itoa(maxVarValues) + " component values")
+			}
+			s.outer = outer
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, values...)
+			continue
+		case c.Kind != PreservedToken:
+			values, err := s.substitute(c.Values)
+			if err != nil {
+				return nil, err
+			}
+			c.Values = values
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// error returns a diagnostic at the outermost var() function.
+func (s *varSubstitution) error(msg string) *Diagnostic {
+	return syntaxError(s.outer.Span(), CodeInvalidVar, msg)
+}
+
+// reference returns the substituted value of the var() function fn.
+func (s *varSubstitution) reference(fn *ComponentValue) ([]ComponentValue, *Diagnostic) {
+	name, fallback, hasFallback := fn.Values, []ComponentValue(nil), false
+	for i := range fn.Values {
+		if fn.Values[i].IsDelim(',') {
+			name, fallback, hasFallback = fn.Values[:i], fn.Values[i+1:], true
+			break
+		}
+	}
+	values := significant(name)
+	if len(values) != 1 || !isToken(values[0], Ident) || !strings.HasPrefix(values[0].Token.Value, "--") {
+		return nil, s.error("expected custom property name in var()")
+	}
+	prop := values[0].Token.Value
+	if s.active[prop] {
+		return nil, s.error("cyclic reference to custom property " + prop)
+	}
+	value, ok := s.lookup(prop)
+	if !ok {
+		if !hasFallback {
+			return nil, s.error("undefined custom property " + prop)
+		}
+		return s.substitute(fallback)
+	}
+	s.active[prop] = true
+	defer delete(s.active, prop)
+	return s.substitute(value)
+}
@@ -0,0 +1,114 @@
+// Copyright as given in CONTRIBUTORS
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// customProperties returns a lookup function for SubstituteVars.
+func customProperties(props map[string]string) func(string) ([]ComponentValue, bool) {
+	return func(name string) ([]ComponentValue, bool) {
+		value, ok := props[name]
+		if !ok {
+			return nil, false
+		}
+		list, _ := ParseComponentValueList(value)
+		return list, true
+	}
+}
+
+func TestSubstituteVars(t *testing.T) {
+	lookup := customProperties(map[string]string{
+		"--brand":  "#336699",
+		"--accent": "var(--brand)",
+		"--width":  "2px",
+		"--a":      "var(--b)",
+		"--b":      "var(--a)",
+		"--deep":   "1px var(--nope)",
+	})
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"var(--brand)", "#336699"},
+		{"1px solid var(--accent)", "1px solid #336699"},
+		{"rgb(from var(--brand) r g calc(b * 0.5))", "rgb(from #336699 r g calc(b * 0.5))"},
+		{"var(--missing, var(--width))", " 2px"},
+		{"var(--missing,)", ""},
+		{"var( --width , 1px)", "2px"},
+		{"[var(--width)]", "[2px]"},
+	} {
+		list, _ := ParseComponentValueList(test.input)
+		res, err := SubstituteVars(list, lookup)
+		if err != nil {
+			t.Errorf("For %q: %v", test.input, err)
+			continue
+		}
+		var sb strings.Builder
+		if err := emitValues(&sb, res); err != nil {
+			t.Fatal(err)
+		}
+		if got := sb.String(); got != test.expected {
+			t.Errorf("For %q: expected %q, got %q", test.input, test.expected, got)
+		}
+	}
+	for _, test := range []struct {
+		input, expected string
+	}{
+		{"var(--missing)", "line 1, column 1: error: undefined custom property --missing"},
+		{"var(brand)", "line 1, column 1: error: expected custom property name in var()"},
+		{"var()", "line 1, column 1: error: expected custom property name in var()"},
+		{"calc(var(--a) * 2)", "line 1, column 6: error: cyclic reference to custom property --a"},
+		{"var(--width) var(--deep)", "line 1, column 14: error: undefined custom property --nope"},
+		{"1px\n  var(--x, var(--deep))", "line 2, column 3: error: undefined custom property --nope"},
+	} {
+		list, _ := ParseComponentValueList(test.input)
+		_, err := SubstituteVars(list, lookup)
+		if err == nil {
+			t.Errorf("For %q: expected an error", test.input)
+			continue
+		}
+		d := err.(*Diagnostic)
+		if got := d.String(); got != test.expected || d.Code != CodeInvalidVar {
+			t.Errorf("For %q:\nexpected %q\ngot      %q (%v)", test.input, test.expected, got, d.Code)
+		}
+	}
+
+	list, _ := ParseComponentValueList("rgb(from var(--brand) r g calc(b * 0.5))")
+	res, _ := SubstituteVars(list, lookup)
+	col, err := ParseColorValues(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := col.String(), "rgb(51, 102, 77)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestSubstituteVarsLimit(t *testing.T) {
+	// Every property refers to the next one twice, so --p0 expands to
+	// 2^30 values.
+	props := map[string]string{"--p30": "x"}
+	for i := 0; i < 30; i++ {
+		props[fmt.Sprintf("--p%d", i)] = fmt.Sprintf("var(--p%d) var(--p%d)", i+1, i+1)
+	}
+	list, _ := ParseComponentValueList("a var(--p0)")
+	_, err := SubstituteVars(list, customProperties(props))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "line 1, column 3: error: custom property substitution exceeds 65536 component values"
+	if got := err.Error(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	list, _ = ParseComponentValueList("var(--p20)")
+	if _, err := SubstituteVars(list, customProperties(props)); err != nil {
+		t.Errorf("var(--p20): %v", err)
+	}
+}